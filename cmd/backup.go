@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var backupKeep int
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [path]",
+	Short: "Back up the database",
+	Long: `Write a consistent snapshot of the database, safe to take while MCP servers
+are running. Without a path, the backup is written to ~/.clauder/backups and
+only the newest --keep backups there are kept.
+
+The MCP server also takes a rotating backup there once a day.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBackup,
+}
+
+func init() {
+	backupCmd.Flags().IntVar(&backupKeep, "keep", store.DefaultBackupRetention, "Number of rotating backups to keep")
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if len(args) == 1 {
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid path %s: %w", args[0], err)
+		}
+		if err := s.Backup(ctx, path); err != nil {
+			return fmt.Errorf("failed to back up database: %w", err)
+		}
+		fmt.Printf("Backed up to %s\n", path)
+		return nil
+	}
+
+	path, err := s.BackupRotating(ctx, backupDir(dataDir), backupKeep)
+	if err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	fmt.Printf("Backed up to %s\n", path)
+	return nil
+}
+
+// backupDir is where rotating backups of the database in dataDir are kept
+func backupDir(dataDir string) string {
+	return filepath.Join(dataDir, "backups")
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbMigrateStatus bool
+	dbKeySource     string
+	dbForce         bool
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Database maintenance",
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the database to the current schema",
+	Long: `Apply pending schema migrations. Migrations also run automatically whenever
+the database is opened; the database is backed up to ~/.clauder/backups first.`,
+	Args: cobra.NoArgs,
+	RunE: runDBMigrate,
+}
+
+var dbEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt fact and message content at rest",
+	Long: `Encrypt the content of facts, fact revisions, semantic search vectors,
+messages and session summaries with AES-256-GCM. Tags, directories and timestamps stay in plaintext
+so filters keep working; full-text search uses an index rebuilt in memory.
+
+The key is read from $CLAUDER_ENCRYPTION_KEY, the key file
+(~/.clauder/clauder.key or $CLAUDER_KEY_FILE) or the OS keyring, in that order.
+If none has a key, a new one is generated and saved to --key-source:
+  file     the key file (default)
+  keyring  macOS Keychain, or the Secret Service via secret-tool
+  env      printed once; export it as CLAUDER_ENCRYPTION_KEY
+
+Existing backups are not encrypted; delete them once you have verified the
+encrypted database.`,
+	Args: cobra.NoArgs,
+	RunE: runDBEncrypt,
+}
+
+var dbRotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Re-encrypt content with a new key",
+	Long: `Generate a new encryption key, re-encrypt all content with it and save it
+where the current key was found, or to --key-source. The old key is only
+replaced once every row is re-encrypted; if the rotation fails, the database
+still opens with the old key.`,
+	Args: cobra.NoArgs,
+	RunE: runDBRotateKey,
+}
+
+func init() {
+	dbMigrateCmd.Flags().BoolVar(&dbMigrateStatus, "status", false, "Show the schema version and pending migrations without migrating")
+	for _, c := range []*cobra.Command{dbEncryptCmd, dbRotateKeyCmd} {
+		c.Flags().StringVar(&dbKeySource, "key-source", "", "Where to save a new key: file, keyring or env")
+		c.Flags().BoolVar(&dbForce, "force", false, "Run even while instances are running")
+	}
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbEncryptCmd)
+	dbCmd.AddCommand(dbRotateKeyCmd)
+}
+
+func runDBMigrate(cmd *cobra.Command, args []string) error {
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	version, err := store.ReadSchemaVersion(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	latest := store.LatestSchemaVersion()
+
+	if dbMigrateStatus {
+		fmt.Printf("Schema version: %d (latest: %d)\n\n", version, latest)
+		for _, m := range store.Migrations() {
+			state := "applied"
+			if m.Version > version {
+				state = "pending"
+			}
+			fmt.Printf("  %3d  %-8s %s\n", m.Version, state, m.Description)
+		}
+		if version > latest {
+			fmt.Println("\nThe database was written by a newer version of clauder; upgrade clauder.")
+		}
+		return nil
+	}
+
+	if version == latest {
+		fmt.Printf("Schema is up to date (version %d)\n", version)
+		return nil
+	}
+
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	fmt.Printf("Migrated schema from version %d to %d\n", version, latest)
+	return nil
+}
+
+func runDBEncrypt(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	source, err := store.ParseKeySource(dbKeySource)
+	if err != nil {
+		return err
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if s.Encrypted() {
+		return fmt.Errorf("database is already encrypted; use 'clauder db rotate-key' to change the key")
+	}
+	if err := requireNoInstances(ctx, s, dbForce); err != nil {
+		return err
+	}
+
+	key, existing, err := store.LoadKey(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	var saveKey store.KeySaver
+	if key != nil {
+		source = existing
+	} else {
+		if key, err = store.GenerateKey(); err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+		saveKey = keySaver(dataDir, key, source)
+	}
+
+	if err := s.SetEncryptionKey(ctx, key, saveKey); err != nil {
+		return fmt.Errorf("failed to encrypt database: %w", err)
+	}
+
+	fmt.Printf("Encrypted database (key from %s)\n", describeKeySource(dataDir, source))
+	if existing != source {
+		printEnvKey(key, source)
+	}
+	if entries, _ := os.ReadDir(backupDir(dataDir)); len(entries) > 0 {
+		fmt.Printf("Backups in %s were written before encryption; delete them once you have checked the database.\n", backupDir(dataDir))
+	}
+	return nil
+}
+
+func runDBRotateKey(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if !s.Encrypted() {
+		return fmt.Errorf("database is not encrypted; use 'clauder db encrypt' first")
+	}
+	if err := requireNoInstances(ctx, s, dbForce); err != nil {
+		return err
+	}
+
+	_, source, err := store.LoadKey(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	if dbKeySource != "" {
+		if source, err = store.ParseKeySource(dbKeySource); err != nil {
+			return err
+		}
+	}
+
+	key, err := store.GenerateKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	if err := s.SetEncryptionKey(ctx, key, keySaver(dataDir, key, source)); err != nil {
+		return fmt.Errorf("failed to rotate key: %w", err)
+	}
+
+	fmt.Printf("Rotated encryption key (saved to %s)\n", describeKeySource(dataDir, source))
+	printEnvKey(key, source)
+	return nil
+}
+
+// keySaver saves key to source. Keys for the environment are only printed
+// once the database is encrypted.
+func keySaver(dataDir string, key []byte, source store.KeySource) store.KeySaver {
+	if source == store.KeySourceEnv {
+		return nil
+	}
+	return store.NewKeySaver(dataDir, key, source)
+}
+
+func describeKeySource(dataDir string, source store.KeySource) string {
+	switch source {
+	case store.KeySourceFile:
+		return store.KeyFilePath(dataDir)
+	case store.KeySourceEnv:
+		return "$" + store.EncryptionKeyEnv
+	}
+	return "the OS keyring"
+}
+
+func printEnvKey(key []byte, source store.KeySource) {
+	if source != store.KeySourceEnv {
+		return
+	}
+	fmt.Println("\nSet this key in the environment of every clauder process. It is not saved")
+	fmt.Println("anywhere else; without it the content cannot be recovered.")
+	fmt.Printf("\n  export %s=%s\n\n", store.EncryptionKeyEnv, store.EncodeKey(key))
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dedupeThreshold float64
+	dedupeYes       bool
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Find and merge near-duplicate facts",
+	Long: `Find groups of near-duplicate facts stored in the same directory or
+repository path, and offer to merge each group into its most recently updated
+fact. The kept fact gains the tags of the others, which are deleted.`,
+	Args: cobra.NoArgs,
+	RunE: runDedupe,
+}
+
+func init() {
+	dedupeCmd.Flags().Float64Var(&dedupeThreshold, "threshold", store.SimilarityThreshold, "Minimum term overlap (0-1) for facts to count as duplicates")
+	dedupeCmd.Flags().BoolVarP(&dedupeYes, "yes", "y", false, "Merge every group without asking for confirmation")
+}
+
+func runDedupe(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	if dedupeThreshold <= 0 || dedupeThreshold > 1 {
+		return fmt.Errorf("--threshold must be between 0 and 1")
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	groups, err := s.FindDuplicateGroups(ctx, dedupeThreshold)
+	if err != nil {
+		return fmt.Errorf("failed to find duplicates: %w", err)
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No duplicate facts found.")
+		return nil
+	}
+
+	fmt.Printf("Found %d group(s) of similar facts.\n", len(groups))
+
+	merged := 0
+	for i, group := range groups {
+		keep := group[0]
+		fmt.Printf("\nGroup %d/%d (%s)\n", i+1, len(groups), keep.SourceDir)
+		fmt.Printf("  keep   #%d %s\n", keep.ID, truncateLine(keep.Content, 80))
+		var duplicateIDs []int64
+		for _, f := range group[1:] {
+			fmt.Printf("  merge  #%d %s\n", f.ID, truncateLine(f.Content, 80))
+			duplicateIDs = append(duplicateIDs, f.ID)
+		}
+
+		if !dedupeYes && !askYesNo(fmt.Sprintf("Merge into #%d?", keep.ID)) {
+			continue
+		}
+		if _, err := s.MergeFacts(ctx, keep.ID, duplicateIDs); err != nil {
+			return fmt.Errorf("failed to merge into fact #%d: %w", keep.ID, err)
+		}
+		merged += len(duplicateIDs)
+	}
+
+	fmt.Printf("\nMerged %d duplicate fact(s)\n", merged)
+	return nil
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var editTags []string
+
+var editCmd = &cobra.Command{
+	Use:   "edit <id> [new content]",
+	Short: "Edit a stored fact",
+	Long: `Edit a stored fact in place, keeping its ID and creation time.
+
+The previous version is kept in the fact's revision history (see 'clauder history').
+If no new content is given and --tags is not set, the fact is opened in $EDITOR.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runEdit,
+}
+
+func init() {
+	editCmd.Flags().StringSliceVarP(&editTags, "tags", "t", nil, "Replace the fact's tags")
+}
+
+func runEdit(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid fact ID: %s", args[0])
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	existing, err := s.PeekFact(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get fact: %w", err)
+	}
+	if existing == nil {
+		return fmt.Errorf("fact #%d not found", id)
+	}
+
+	content := strings.Join(args[1:], " ")
+	tagsChanged := cmd.Flags().Changed("tags")
+
+	if content == "" && !tagsChanged {
+		content, err = editInEditor(dataDir, existing.Content)
+		if err != nil {
+			return err
+		}
+		if content == existing.Content {
+			fmt.Println("No changes.")
+			return nil
+		}
+	}
+
+	if content != "" {
+		if content, err = scanSecrets(content); err != nil {
+			return err
+		}
+	}
+
+	var tags []string
+	if tagsChanged {
+		tags = editTags
+		if tags == nil {
+			tags = []string{}
+		}
+	}
+
+	updated, err := s.UpdateFact(ctx, id, content, tags)
+	if err != nil {
+		return fmt.Errorf("failed to update fact: %w", err)
+	}
+
+	fmt.Printf("Updated fact #%d\n", updated.ID)
+	return nil
+}
+
+// editInEditor opens content in $EDITOR and returns the edited text. The
+// temp file is created in dataDir, with owner-only permissions, so decrypted
+// facts never land in a shared temp directory.
+func editInEditor(dataDir, content string) (string, error) {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	tmp, err := os.CreateTemp(dataDir, "edit-fact-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	editorCmd := exec.Command(editor[0], append(editor[1:], tmp.Name())...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return "", fmt.Errorf("editor failed: %w", err)
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited fact: %w", err)
+	}
+
+	edited := strings.TrimSpace(string(data))
+	if edited == "" {
+		return "", fmt.Errorf("fact content cannot be empty")
+	}
+	return edited, nil
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportTags   []string
+	exportDir    string
+	exportSince  string
+	exportFormat string
+	exportOutput string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export facts as JSONL or Markdown",
+	Long: `Export facts, oldest first. The default JSONL format writes one fact per line
+with its ID, tags, directory and timestamps, and can be read back with
+'clauder import'. The Markdown format groups facts by directory for review.`,
+	Args: cobra.NoArgs,
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringSliceVarP(&exportTags, "tags", "t", nil, "Only export facts with these tags")
+	exportCmd.Flags().StringVarP(&exportDir, "dir", "d", "", "Only export facts stored from this directory or below it")
+	exportCmd.Flags().StringVar(&exportSince, "since", "", "Only export facts updated since a date (YYYY-MM-DD) or age (e.g. 7d)")
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "jsonl", "Output format: jsonl or markdown")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to a file instead of stdout")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	if exportFormat != "jsonl" && exportFormat != "markdown" {
+		return fmt.Errorf("invalid --format %q (use jsonl or markdown)", exportFormat)
+	}
+
+	// Reuse the recall query language for --since
+	var filter string
+	if exportSince != "" {
+		filter = "since:" + exportSince
+	}
+	q, err := store.ParseQuery(filter)
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	q.Tags = exportTags
+	if exportDir != "" {
+		if q.SourceDir, err = filepath.Abs(exportDir); err != nil {
+			return fmt.Errorf("invalid --dir: %w", err)
+		}
+		q.DirMode = store.DirDescendants
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	var out io.Writer = os.Stdout
+	if exportOutput != "" {
+		// Exports hold every fact in plaintext, even from an encrypted
+		// database
+		file, err := os.OpenFile(exportOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", exportOutput, err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+	w := bufio.NewWriter(out)
+
+	var facts []store.Fact
+	err = s.ExportFacts(ctx, q, func(f store.Fact) error {
+		if exportFormat == "markdown" {
+			facts = append(facts, f)
+			return nil
+		}
+		line, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", line)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export facts: %w", err)
+	}
+	if exportFormat == "markdown" {
+		writeMarkdown(w, facts)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if exportOutput != "" {
+		fmt.Printf("Exported facts to %s\n", exportOutput)
+	}
+	return nil
+}
+
+// writeMarkdown renders facts grouped by directory, in first-seen order
+func writeMarkdown(w io.Writer, facts []store.Fact) {
+	var dirs []string
+	byDir := make(map[string][]store.Fact)
+	for _, f := range facts {
+		if _, ok := byDir[f.SourceDir]; !ok {
+			dirs = append(dirs, f.SourceDir)
+		}
+		byDir[f.SourceDir] = append(byDir[f.SourceDir], f)
+	}
+
+	_, _ = fmt.Fprintf(w, "# Clauder facts\n\nExported %s, %d fact(s).\n", time.Now().Format("2006-01-02 15:04"), len(facts))
+	for _, dir := range dirs {
+		title := dir
+		if dir == store.GlobalScope {
+			title = "Global"
+		}
+		_, _ = fmt.Fprintf(w, "\n## %s\n", title)
+		for _, f := range byDir[dir] {
+			_, _ = fmt.Fprintf(w, "\n### #%d (updated %s)\n\n", f.ID, f.UpdatedAt.Format("2006-01-02 15:04"))
+			var meta []string
+			if f.Kind != store.KindNote {
+				meta = append(meta, "Kind: "+factKindLabel(f))
+			}
+			if len(f.Tags) > 0 {
+				meta = append(meta, "Tags: "+strings.Join(f.Tags, ", "))
+			}
+			if f.Pinned {
+				meta = append(meta, "Pinned")
+			}
+			if f.ExpiresAt != nil {
+				meta = append(meta, "Expires: "+f.ExpiresAt.Format("2006-01-02 15:04"))
+			}
+			if len(f.SupersededBy) > 0 {
+				meta = append(meta, "Superseded by "+store.FormatIDs(f.SupersededBy))
+			}
+			if len(meta) > 0 {
+				_, _ = fmt.Fprintf(w, "_%s_\n\n", strings.Join(meta, " · "))
+			}
+			_, _ = fmt.Fprintf(w, "%s\n", f.Content)
+			for _, line := range f.Details.Lines() {
+				_, _ = fmt.Fprintf(w, "\n%s\n", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forgetTags       []string
+	forgetCurrentDir bool
+	forgetYes        bool
+)
+
+var forgetCmd = &cobra.Command{
+	Use:   "forget [id | query]",
+	Short: "Delete stored facts",
+	Long: `Delete a single fact by ID, or every fact matching a recall-style filter
+(see 'clauder recall --help' for the query syntax).
+
+Filter deletes show a preview of the matching facts and ask for confirmation
+unless --yes is given.`,
+	RunE: runForget,
+}
+
+func init() {
+	forgetCmd.Flags().StringSliceVarP(&forgetTags, "tags", "t", nil, "Only delete facts with these tags")
+	forgetCmd.Flags().BoolVarP(&forgetCurrentDir, "local", "l", false, "Only delete facts stored from the current directory itself")
+	forgetCmd.Flags().BoolVarP(&forgetYes, "yes", "y", false, "Delete without asking for confirmation")
+}
+
+func runForget(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// A single numeric argument is a fact ID
+	if len(args) == 1 && len(forgetTags) == 0 && !forgetCurrentDir {
+		if id, err := strconv.ParseInt(args[0], 10, 64); err == nil {
+			fact, err := s.PeekFact(ctx, id)
+			if err != nil {
+				return fmt.Errorf("failed to find fact: %w", err)
+			}
+			if fact == nil {
+				return fmt.Errorf("fact #%d not found", id)
+			}
+			if err := s.DeleteFact(ctx, id); err != nil {
+				return fmt.Errorf("failed to forget fact: %w", err)
+			}
+			fmt.Printf("Forgot fact #%d\n", id)
+			return nil
+		}
+	}
+
+	query := strings.Join(args, " ")
+
+	sourceDir := ""
+	if forgetCurrentDir {
+		sourceDir, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+	}
+
+	if strings.TrimSpace(query) == "" && len(forgetTags) == 0 && sourceDir == "" {
+		return fmt.Errorf("provide a fact ID, a query, --tags or --local")
+	}
+
+	q, err := store.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	q.Tags = append(q.Tags, forgetTags...)
+	if sourceDir != "" {
+		// Unlike recall --local, only the current directory itself
+		q.SourceDir = sourceDir
+		q.DirMode = store.DirExact
+	}
+	q.Limit = store.MaxLimit
+
+	var facts []store.Fact
+	for {
+		page, err := s.SearchFactsPage(ctx, q)
+		if err != nil {
+			return fmt.Errorf("failed to find facts: %w", err)
+		}
+		facts = append(facts, page.Facts...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	if len(facts) == 0 {
+		fmt.Println("No facts found.")
+		return nil
+	}
+
+	fmt.Printf("%d fact(s) will be forgotten:\n\n", len(facts))
+	for _, f := range facts {
+		fmt.Printf("  #%d %s\n", f.ID, truncateLine(f.Content, 80))
+	}
+	fmt.Println()
+
+	if !forgetYes && !askYesNo("Delete these facts?") {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	for _, f := range facts {
+		if err := s.DeleteFact(ctx, f.ID); err != nil {
+			return fmt.Errorf("failed to forget fact #%d: %w", f.ID, err)
+		}
+	}
+
+	fmt.Printf("Forgot %d fact(s)\n", len(facts))
+	return nil
+}
+
+// truncateLine shortens s to a single line of at most maxLen bytes
+func truncateLine(s string, maxLen int) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " ..."
+	}
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen-3] + "..."
+}
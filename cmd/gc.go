@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var gcMessagesOlderThan string
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove expired data and compact the database",
+	Long: `Delete facts whose TTL has passed, instances that stopped sending
+heartbeats, messages read longer ago than --messages-older-than and messages
+sent to instances that are gone. Then optimize the database and its search
+index, and vacuum it to return the freed space to the disk.
+
+Expired facts are also removed whenever the MCP server starts, and
+'clauder serve --gc-interval' runs gc periodically.`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+func init() {
+	gcCmd.Flags().StringVar(&gcMessagesOlderThan, "messages-older-than", "30d", "Delete messages read longer ago than this (0 to keep them)")
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	messageAge, err := store.ParseAge(gcMessagesOlderThan)
+	if err != nil {
+		return fmt.Errorf("invalid --messages-older-than %q (use e.g. 30d or 12w)", gcMessagesOlderThan)
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	result, err := s.GC(ctx, store.GCOptions{ReadMessageAge: messageAge})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d expired fact(s)\n", result.ExpiredFacts)
+	fmt.Printf("Removed %d stale instance(s)\n", result.StaleInstances)
+	fmt.Printf("Removed %d read message(s) and %d orphaned message(s)\n", result.ReadMessages, result.OrphanedMessages)
+	fmt.Printf("Database size: %s -> %s (reclaimed %s)\n",
+		formatBytes(result.SizeBefore), formatBytes(result.SizeAfter), formatBytes(max(result.Reclaimed(), 0)))
+	return nil
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
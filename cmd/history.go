@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <id>",
+	Short: "Show the revision history of a fact",
+	Long:  `Show every past version of a fact, oldest first, followed by its current content.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runHistory,
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid fact ID: %s", args[0])
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	fact, err := s.GetFactByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get fact: %w", err)
+	}
+	if fact == nil {
+		return fmt.Errorf("fact #%d not found", id)
+	}
+
+	revisions, err := s.GetFactRevisions(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get revisions: %w", err)
+	}
+
+	fmt.Printf("Fact #%d (created %s, %d revision(s))\n\n", fact.ID, fact.CreatedAt.Format("2006-01-02 15:04"), len(revisions))
+
+	for i, r := range revisions {
+		fmt.Printf("v%d [%s - %s]\n", i+1, r.CreatedAt.Format("2006-01-02 15:04"), r.ReplacedAt.Format("2006-01-02 15:04"))
+		if len(r.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", strings.Join(r.Tags, ", "))
+		}
+		printRevisionKind(r.Kind, r.Details)
+		fmt.Printf("%s\n\n", r.Content)
+	}
+
+	fmt.Printf("v%d (current) [%s]\n", len(revisions)+1, fact.UpdatedAt.Format("2006-01-02 15:04"))
+	if len(fact.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(fact.Tags, ", "))
+	}
+	printRevisionKind(fact.Kind, fact.Details)
+	fmt.Printf("%s\n", fact.Content)
+
+	return nil
+}
+
+// printRevisionKind prints the kind and details of a version, if any
+func printRevisionKind(kind store.FactKind, details *store.FactDetails) {
+	if kind != "" && kind != store.KindNote {
+		fmt.Printf("Kind: %s\n", kind)
+	}
+	for _, line := range details.Lines() {
+		fmt.Println(line)
+	}
+}
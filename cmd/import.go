@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/maorbril/clauder/internal/secrets"
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var importStrategy string
+
+var importCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import facts from a JSONL export",
+	Long: `Import facts written by 'clauder export' (JSONL), from a file or stdin.
+
+Facts are scanned for secrets before anything is stored, as with remember;
+a fact the scanner rejects aborts the whole import.
+
+Strategies (--strategy):
+  remap       store every fact under a new ID (default)
+  skip        skip facts already stored with the same content and directory
+  overwrite   keep the exported IDs, replacing facts stored under them`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().StringVarP(&importStrategy, "strategy", "s", "remap", "How to handle existing facts: remap, skip or overwrite")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	strategy, err := store.ParseImportStrategy(importStrategy)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if len(args) == 1 && args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", args[0], err)
+		}
+		defer func() { _ = file.Close() }()
+		in = file
+	}
+
+	facts, err := readFactsJSONL(in)
+	if err != nil {
+		return err
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	scanner, err := secrets.LoadScanner(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load secret scanner: %w", err)
+	}
+	// Scan every fact before anything is stored, including facts that
+	// overwrite existing ones
+	if err := scanImportedFacts(scanner, facts); err != nil {
+		return err
+	}
+
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	stats, err := s.ImportFacts(ctx, facts, strategy)
+	if err != nil {
+		return fmt.Errorf("failed to import facts: %w", err)
+	}
+
+	fmt.Printf("Imported %d fact(s)", stats.Imported)
+	if stats.Skipped > 0 {
+		fmt.Printf(", skipped %d duplicate(s)", stats.Skipped)
+	}
+	if stats.Overwritten > 0 {
+		fmt.Printf(", overwrote %d", stats.Overwritten)
+	}
+	fmt.Println()
+	return nil
+}
+
+// scanImportedFacts applies scanner to the content and details of each fact
+// in place, rejecting the whole import if any fact is rejected
+func scanImportedFacts(scanner *secrets.Scanner, facts []store.Fact) error {
+	var all []secrets.Finding
+	hits := 0
+	for i := range facts {
+		f := &facts[i]
+		var found []secrets.Finding
+		for _, field := range append([]*string{&f.Content}, f.Details.TextFields()...) {
+			scanned, findings, err := scanner.Apply(*field)
+			if err != nil {
+				return fmt.Errorf("not imported: fact #%d: %w", f.ID, err)
+			}
+			*field = scanned
+			found = append(found, findings...)
+		}
+		if len(found) > 0 {
+			hits++
+			all = append(all, found...)
+		}
+	}
+	if len(all) > 0 {
+		if scanner.Action == secrets.ActionRedact {
+			fmt.Fprintf(os.Stderr, "Redacted %d secret(s) in %d fact(s): %s\n", len(all), hits, describeFindings(all))
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: %d fact(s) contain secrets: %s\n", hits, describeFindings(all))
+		}
+	}
+	return nil
+}
+
+func readFactsJSONL(r io.Reader) ([]store.Fact, error) {
+	var facts []store.Fact
+	scanner := bufio.NewScanner(r)
+	// Facts can be up to 1MB
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var f store.Fact
+		if err := json.Unmarshal([]byte(text), &f); err != nil {
+			return nil, fmt.Errorf("line %d: invalid fact: %w", line, err)
+		}
+		if f.Content == "" || f.SourceDir == "" {
+			return nil, fmt.Errorf("line %d: content and source_dir are required", line)
+		}
+		facts = append(facts, f)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read facts: %w", err)
+	}
+	return facts, nil
+}
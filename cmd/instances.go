@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/maorbril/clauder/internal/store"
 	"github.com/spf13/cobra"
@@ -16,7 +15,11 @@ var instancesCmd = &cobra.Command{
 }
 
 func runInstances(cmd *cobra.Command, args []string) error {
-	dataDir := getDataDir()
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
 	s, err := store.NewSQLiteStore(dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
@@ -24,9 +27,9 @@ func runInstances(cmd *cobra.Command, args []string) error {
 	defer func() { _ = s.Close() }()
 
 	// Cleanup stale instances
-	_ = s.CleanupStaleInstances(5 * time.Minute)
+	_ = s.CleanupStaleInstances(ctx, store.StaleInstanceAge)
 
-	instances, err := s.GetInstances()
+	instances, err := s.GetInstances(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list instances: %w", err)
 	}
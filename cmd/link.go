@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var linkRemove bool
+
+var linkCmd = &cobra.Command{
+	Use:   "link <from-id> <kind> <to-id>",
+	Short: "Link two facts",
+	Long: `Record how two facts relate. Kinds:
+
+  supersedes   from-id replaces to-id, which is then hidden from recall
+  relates-to   the facts are about the same thing
+  depends-on   from-id only holds while to-id does
+
+Example:
+  clauder link 12 supersedes 7`,
+	Args: cobra.ExactArgs(3),
+	RunE: runLink,
+}
+
+func init() {
+	linkCmd.Flags().BoolVar(&linkRemove, "remove", false, "Remove the link instead of adding it")
+}
+
+func runLink(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	fromID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid fact ID: %s", args[0])
+	}
+	kind, err := store.ParseLinkKind(args[1])
+	if err != nil {
+		return err
+	}
+	toID, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid fact ID: %s", args[2])
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if linkRemove {
+		removed, err := s.UnlinkFacts(ctx, fromID, toID, kind)
+		if err != nil {
+			return fmt.Errorf("failed to unlink facts: %w", err)
+		}
+		if !removed {
+			return fmt.Errorf("no link #%d %s #%d", fromID, kind, toID)
+		}
+		fmt.Printf("Removed link: #%d %s #%d\n", fromID, kind, toID)
+		return nil
+	}
+
+	if _, err := s.LinkFacts(ctx, fromID, toID, kind); err != nil {
+		return fmt.Errorf("failed to link facts: %w", err)
+	}
+	fmt.Printf("Linked: #%d %s #%d\n", fromID, kind, toID)
+	return nil
+}
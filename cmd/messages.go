@@ -22,7 +22,11 @@ func init() {
 }
 
 func runMessages(cmd *cobra.Command, args []string) error {
-	dataDir := getDataDir()
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
 	s, err := store.NewSQLiteStore(dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
@@ -32,7 +36,7 @@ func runMessages(cmd *cobra.Command, args []string) error {
 	instanceID := args[0]
 	unreadOnly := !messagesAll
 
-	messages, err := s.GetMessages(instanceID, unreadOnly)
+	messages, err := s.GetMessages(ctx, instanceID, unreadOnly)
 	if err != nil {
 		return fmt.Errorf("failed to get messages: %w", err)
 	}
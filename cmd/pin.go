@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin <id>",
+	Short: "Pin a fact so it always appears first in context",
+	Long:  `Pin a fact so it is always included at the top of get_context, however old it is.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPin(cmd.Context(), args[0], true)
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin <id>",
+	Short: "Unpin a pinned fact",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPin(cmd.Context(), args[0], false)
+	},
+}
+
+func runPin(ctx context.Context, arg string, pinned bool) error {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid fact ID: %s", arg)
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	fact, err := s.SetPinned(ctx, id, pinned)
+	if err != nil {
+		return fmt.Errorf("failed to update fact: %w", err)
+	}
+	if fact == nil {
+		return fmt.Errorf("fact #%d not found", id)
+	}
+
+	if pinned {
+		fmt.Printf("Pinned fact #%d\n", id)
+	} else {
+		fmt.Printf("Unpinned fact #%d\n", id)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/maorbril/clauder/internal/profile"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage separate memory profiles",
+	Long: `Each profile keeps its own facts, messages, backups, keys and settings,
+e.g. to separate work and personal memory or to run experiments.
+
+The "default" profile lives in ~/.clauder ($CLAUDER_HOME); other profiles live
+in ~/.clauder/profiles/<name>. A command uses the profile given with --profile,
+then $CLAUDER_PROFILE, then the one selected with 'clauder profile use'.
+--data-dir bypasses profiles and uses a directory directly.`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles, marking the one in use",
+	Args:  cobra.NoArgs,
+	RunE:  runProfileList,
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProfileCreate,
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Use a profile when none is given",
+	Long: `Select the profile that commands and MCP servers use when no --profile or
+$CLAUDER_PROFILE is given. Running MCP servers keep the profile they started
+with until they are restarted.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProfileUse,
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileCreateCmd)
+	profileCmd.AddCommand(profileUseCmd)
+}
+
+func runProfileList(cmd *cobra.Command, args []string) error {
+	home, err := profile.Home()
+	if err != nil {
+		return err
+	}
+	names, err := profile.List(home)
+	if err != nil {
+		return fmt.Errorf("failed to list profiles: %w", err)
+	}
+	_, current, err := profile.Resolve(rootDataDir, rootProfile)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		marker := " "
+		if name == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %-12s %s\n", marker, name, profile.Dir(home, name))
+	}
+	if current == "" {
+		fmt.Printf("\nUsing data directory %s\n", rootDataDir)
+	}
+	return nil
+}
+
+func runProfileCreate(cmd *cobra.Command, args []string) error {
+	home, err := profile.Home()
+	if err != nil {
+		return err
+	}
+	dir, err := profile.Create(home, args[0])
+	if err != nil {
+		return fmt.Errorf("failed to create profile: %w", err)
+	}
+	fmt.Printf("Created profile %s in %s\n", args[0], dir)
+	fmt.Printf("Use it with --profile %s, or make it the default with 'clauder profile use %s'.\n", args[0], args[0])
+	return nil
+}
+
+func runProfileUse(cmd *cobra.Command, args []string) error {
+	home, err := profile.Home()
+	if err != nil {
+		return err
+	}
+	if err := profile.SetActive(home, args[0]); err != nil {
+		return fmt.Errorf("failed to select profile: %w", err)
+	}
+	fmt.Printf("Using profile %s (%s)\n", args[0], profile.Dir(home, args[0]))
+	return nil
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneUnusedFor string
+	pruneYes       bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune --unused-for <age>",
+	Short: "Delete facts that are no longer recalled",
+	Long: `Delete facts that have not been returned by recall, get_context or show
+for the given time (e.g. 90d), including facts never recalled at all. Facts
+younger than that and pinned facts are kept.
+
+The facts to delete are listed first, and deleted after confirmation unless
+--yes is given.`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().StringVar(&pruneUnusedFor, "unused-for", "", "Delete facts not recalled for this long (e.g. 90d, 12w)")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Delete without asking for confirmation")
+	_ = pruneCmd.MarkFlagRequired("unused-for")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	unusedFor, err := store.ParseAge(pruneUnusedFor)
+	if err != nil || unusedFor == 0 {
+		return fmt.Errorf("invalid --unused-for %q (use e.g. 90d or 12w)", pruneUnusedFor)
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	q := store.FactQuery{
+		UnusedSince:       time.Now().Add(-unusedFor),
+		IncludeSuperseded: true,
+		Limit:             store.MaxLimit,
+	}
+	var facts []store.Fact
+	for {
+		page, err := s.SearchFactsPage(ctx, q)
+		if err != nil {
+			return fmt.Errorf("failed to find unused facts: %w", err)
+		}
+		for _, f := range page.Facts {
+			if !f.Pinned {
+				facts = append(facts, f)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	if len(facts) == 0 {
+		fmt.Println("No unused facts found.")
+		return nil
+	}
+
+	fmt.Printf("%d fact(s) not recalled in %s will be deleted:\n\n", len(facts), pruneUnusedFor)
+	for _, f := range facts {
+		used := "never recalled"
+		if f.LastRecalledAt != nil {
+			used = "last recalled " + f.LastRecalledAt.Format("2006-01-02")
+		}
+		fmt.Printf("  #%d (%s) %s\n", f.ID, used, truncateLine(f.Content, 80))
+	}
+	fmt.Println()
+
+	if !pruneYes && !askYesNo("Delete these facts?") {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	for _, f := range facts {
+		if err := s.DeleteFact(ctx, f.ID); err != nil {
+			return fmt.Errorf("failed to delete fact #%d: %w", f.ID, err)
+		}
+	}
+
+	fmt.Printf("Deleted %d fact(s)\n", len(facts))
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/maorbril/clauder/internal/gitrepo"
+	"github.com/maorbril/clauder/internal/mcp"
 	"github.com/maorbril/clauder/internal/store"
 	"github.com/spf13/cobra"
 )
@@ -13,23 +15,62 @@ var (
 	recallTags       []string
 	recallLimit      int
 	recallCurrentDir bool
+	recallRecent     bool
+	recallFull       bool
+	recallMode       string
+	recallSuperseded bool
+	recallKinds      []string
+	recallCursor     string
+	recallClient     string
 )
 
 var recallCmd = &cobra.Command{
 	Use:   "recall [query]",
 	Short: "Search and retrieve stored facts",
-	Long:  `Search and retrieve previously stored facts, decisions, and context.`,
-	RunE:  runRecall,
+	Long: `Search and retrieve previously stored facts, decisions, and context.
+
+Query syntax:
+  word            facts containing word (multiple words must all match)
+  prefix*         words starting with prefix
+  "exact phrase"  the exact phrase
+  a OR b          either term
+  -word           exclude facts containing word
+  tag:arch        facts tagged arch (-tag:arch excludes them)
+  kind:command    facts of a kind: note, decision, convention, gotcha, todo, command
+  dir:~/src/api   facts stored from that directory or below it
+  since:7d        facts updated in the last 7 days (or since YYYY-MM-DD)
+  before:30d      facts last updated more than 30 days ago (or before YYYY-MM-DD)
+
+Search modes (--mode):
+  keyword         rank facts containing the query words by relevance (default)
+  semantic        rank facts by similarity of meaning, so rewordings match
+  hybrid          blend keyword relevance and semantic similarity
+
+Semantic search uses a built-in offline embedding. Set CLAUDER_EMBED_COMMAND
+to a local command that reads text on stdin and prints a JSON array of numbers
+to use your own embedding model instead.`,
+	RunE: runRecall,
 }
 
 func init() {
 	recallCmd.Flags().StringSliceVarP(&recallTags, "tags", "t", nil, "Filter by tags")
 	recallCmd.Flags().IntVarP(&recallLimit, "limit", "n", 20, "Maximum number of results")
-	recallCmd.Flags().BoolVarP(&recallCurrentDir, "local", "l", false, "Only show facts from current directory")
+	recallCmd.Flags().BoolVarP(&recallCurrentDir, "local", "l", false, "Only show facts from current directory and its parents up to the repository root")
+	recallCmd.Flags().BoolVarP(&recallRecent, "recent", "r", false, "Blend relevance with recency so newer facts rank higher")
+	recallCmd.Flags().BoolVarP(&recallFull, "full", "f", false, "Print full fact content instead of excerpts")
+	recallCmd.Flags().StringVarP(&recallMode, "mode", "m", "keyword", "Ranking mode: keyword, semantic or hybrid")
+	recallCmd.Flags().StringSliceVarP(&recallKinds, "kind", "k", nil, "Only show facts of these kinds")
+	recallCmd.Flags().BoolVar(&recallSuperseded, "superseded", false, "Include facts superseded by newer ones")
+	recallCmd.Flags().StringVar(&recallClient, "client", "", "Only show facts stored by clients whose name starts with this (e.g. claude-code, codex, cli)")
+	recallCmd.Flags().StringVar(&recallCursor, "cursor", "", "Show the page of results after an earlier recall with the same query")
 }
 
 func runRecall(cmd *cobra.Command, args []string) error {
-	dataDir := getDataDir()
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
 	s, err := store.NewSQLiteStore(dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
@@ -46,26 +87,101 @@ func runRecall(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	facts, err := s.GetFacts(query, recallTags, sourceDir, recallLimit)
+	q, err := store.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	q.Tags = append(q.Tags, recallTags...)
+	for _, k := range recallKinds {
+		kind, err := store.ParseFactKind(k)
+		if err != nil {
+			return err
+		}
+		q.Kinds = append(q.Kinds, kind)
+	}
+	if sourceDir != "" {
+		q.SourceDir = sourceDir
+		q.DirMode = store.DirAncestors
+		repo := gitrepo.Identify(sourceDir)
+		q.ScopeRoot, q.RepoID = repo.Root, repo.ID
+	}
+	q.Limit = recallLimit
+	q.Cursor = recallCursor
+	q.Client = recallClient
+	q.IncludeSuperseded = recallSuperseded
+	if recallRecent {
+		q.RecencyHalfLife = mcp.RecencyHalfLife
+	}
+	if q.Mode, err = store.ParseSearchMode(recallMode); err != nil {
+		return err
+	}
+
+	page, err := s.SearchFactsPage(ctx, q)
 	if err != nil {
 		return fmt.Errorf("failed to recall facts: %w", err)
 	}
 
-	if len(facts) == 0 {
+	if len(page.Facts) == 0 {
 		fmt.Println("No facts found.")
 		return nil
 	}
+	_ = s.RecordRecalls(ctx, store.FactIDs(page.Facts))
 
-	fmt.Printf("Found %d fact(s):\n\n", len(facts))
+	if page.NextCursor == "" && page.Offset == 0 {
+		fmt.Printf("Found %d fact(s):\n\n", page.Total)
+	} else {
+		fmt.Printf("Found %d fact(s), showing %d-%d:\n\n", page.Total, page.Offset+1, page.Offset+len(page.Facts))
+	}
 
-	for _, f := range facts {
-		fmt.Printf("#%d [%s]\n", f.ID, f.CreatedAt.Format("2006-01-02 15:04"))
+	for _, f := range page.Facts {
+		fmt.Printf("#%d [%s]", f.ID, f.CreatedAt.Format("2006-01-02 15:04"))
+		if f.Kind != store.KindNote {
+			fmt.Printf(" (%s)", factKindLabel(f))
+		}
+		if q.HasText() {
+			fmt.Printf(" (score %.2f)", f.Score)
+		}
+		if f.Pinned {
+			fmt.Print(" (pinned)")
+		}
+		if f.ExpiresAt != nil {
+			fmt.Printf(" (expires %s)", f.ExpiresAt.Format("2006-01-02 15:04"))
+		}
+		if len(f.SupersededBy) > 0 {
+			fmt.Printf(" (superseded by %s)", store.FormatIDs(f.SupersededBy))
+		}
+		fmt.Println()
 		if len(f.Tags) > 0 {
 			fmt.Printf("Tags: %s\n", strings.Join(f.Tags, ", "))
 		}
 		fmt.Printf("Dir: %s\n", f.SourceDir)
-		fmt.Printf("%s\n\n", f.Content)
+		if from := f.Provenance(); from != "" {
+			fmt.Printf("From: %s\n", from)
+		}
+		switch {
+		case recallFull:
+			fmt.Printf("%s\n", f.Content)
+		case f.Snippet != "":
+			fmt.Printf("%s\n", f.Snippet)
+		default:
+			fmt.Printf("%s\n", truncateLine(f.Content, mcp.MaxExcerptLength))
+		}
+		for _, line := range f.Details.Lines() {
+			fmt.Println(line)
+		}
+		fmt.Println()
 	}
 
+	if page.NextCursor != "" {
+		fmt.Printf("More results: repeat with --cursor %s\n", page.NextCursor)
+	}
 	return nil
 }
+
+// factKindLabel names the kind of f, noting todos that are done
+func factKindLabel(f store.Fact) string {
+	if f.Kind == store.KindTodo && f.Details != nil && f.Details.Done {
+		return "todo, done"
+	}
+	return string(f.Kind)
+}
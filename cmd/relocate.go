@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/maorbril/clauder/internal/gitrepo"
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var relocateCmd = &cobra.Command{
+	Use:   "relocate <old-dir> <new-dir>",
+	Short: "Move facts to a new directory after moving a checkout",
+	Long: `Rewrite the directory of every fact stored in <old-dir> or below it to the
+same location under <new-dir>. Use this after moving or renaming a checkout.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRelocate,
+}
+
+func runRelocate(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	oldDir, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid directory %s: %w", args[0], err)
+	}
+	newDir, err := filepath.Abs(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid directory %s: %w", args[1], err)
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	n, err := s.RelocateFacts(ctx, oldDir, newDir)
+	if err != nil {
+		return fmt.Errorf("failed to relocate facts: %w", err)
+	}
+	fmt.Printf("Relocated %d fact(s) from %s to %s\n", n, oldDir, newDir)
+
+	// Record the repository identity if the new location is a git checkout
+	repo := gitrepo.Identify(newDir)
+	if repo.Root != "" && repo.ID != "" {
+		if _, err := s.AssignRepo(ctx, repo.Root, repo.ID); err != nil {
+			return fmt.Errorf("failed to record repository: %w", err)
+		}
+	}
+
+	return nil
+}
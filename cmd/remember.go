@@ -1,30 +1,91 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/maorbril/clauder/internal/gitrepo"
 	"github.com/maorbril/clauder/internal/store"
 	"github.com/spf13/cobra"
 )
 
-var rememberTags []string
+var (
+	rememberTags   []string
+	rememberGlobal bool
+	rememberTTL    string
+	rememberOnDup  string
+	rememberKind   string
+	rememberDetail store.FactDetails
+)
 
 var rememberCmd = &cobra.Command{
 	Use:   "remember [fact]",
 	Short: "Store a fact or piece of context",
-	Long:  `Store a fact, decision, or piece of context that should persist across Claude Code sessions.`,
-	Args:  cobra.MinimumNArgs(1),
-	RunE:  runRemember,
+	Long: `Store a fact, decision, or piece of context that should persist across Claude Code sessions.
+
+A fact can be given a kind, along with that kind's fields:
+
+  decision     --rationale, --alternatives
+  convention   --example
+  gotcha       --workaround
+  todo
+  command      --command (required), --cwd
+
+Example:
+  clauder remember --kind command --command "go test -tags fts5 ./..." "Run the unit tests"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runRemember,
 }
 
 func init() {
 	rememberCmd.Flags().StringSliceVarP(&rememberTags, "tags", "t", nil, "Tags to categorize the fact")
+	rememberCmd.Flags().BoolVarP(&rememberGlobal, "global", "g", false, "Store the fact for every directory instead of the current one")
+	rememberCmd.Flags().StringVar(&rememberTTL, "ttl", "", "Forget the fact after this long (e.g. 12h, 3d, 2w)")
+	rememberCmd.Flags().StringVar(&rememberOnDup, "on-duplicate", "warn", "What to do when a similar fact exists: warn, reject, merge or allow")
+	rememberCmd.Flags().StringVarP(&rememberKind, "kind", "k", "note", "Kind of fact: note, decision, convention, gotcha, todo or command")
+	rememberCmd.Flags().StringVar(&rememberDetail.Rationale, "rationale", "", "Why a decision was made")
+	rememberCmd.Flags().StringSliceVar(&rememberDetail.Alternatives, "alternatives", nil, "Options a decision rejected")
+	rememberCmd.Flags().StringVar(&rememberDetail.Example, "example", "", "An example of following a convention")
+	rememberCmd.Flags().StringVar(&rememberDetail.Workaround, "workaround", "", "How to work around a gotcha")
+	rememberCmd.Flags().StringVar(&rememberDetail.Command, "command", "", "The command line of a command fact")
+	rememberCmd.Flags().StringVar(&rememberDetail.Cwd, "cwd", "", "The directory to run a command fact in")
 }
 
 func runRemember(cmd *cobra.Command, args []string) error {
-	dataDir := getDataDir()
+	ctx := cmd.Context()
+	mode, err := store.ParseDuplicateMode(rememberOnDup)
+	if err != nil {
+		return err
+	}
+	kind, err := store.ParseFactKind(rememberKind)
+	if err != nil {
+		return err
+	}
+	var details *store.FactDetails
+	if !rememberDetail.IsZero() {
+		details = &rememberDetail
+	}
+	if err := details.Validate(kind); err != nil {
+		return err
+	}
+
+	var expiresAt *time.Time
+	if rememberTTL != "" {
+		ttl, err := store.ParseAge(rememberTTL)
+		if err != nil || ttl == 0 {
+			return fmt.Errorf("invalid --ttl %q (use e.g. 12h, 3d or 2w)", rememberTTL)
+		}
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
 	s, err := store.NewSQLiteStore(dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
@@ -36,12 +97,62 @@ func runRemember(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get working directory: %w", err)
 	}
 
-	fact := strings.Join(args, " ")
-	stored, err := s.AddFact(fact, rememberTags, workDir)
+	content, err := scanSecrets(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	for _, field := range details.TextFields() {
+		if *field, err = scanSecrets(*field); err != nil {
+			return err
+		}
+	}
+
+	newFact := store.Fact{
+		Content:       content,
+		Kind:          kind,
+		Details:       details,
+		Tags:          rememberTags,
+		SourceDir:     workDir,
+		ExpiresAt:     expiresAt,
+		Client:        store.CLIClient,
+		ClientVersion: Version,
+	}
+	if rememberGlobal {
+		newFact.SourceDir = store.GlobalScope
+	} else if repo := gitrepo.Identify(workDir); repo.Root != "" {
+		newFact.RepoID, newFact.RepoPath = repo.ID, repo.Path
+		newFact.CommitSHA = gitrepo.HeadCommit(workDir)
+	}
+
+	stored, similar, err := s.RememberFact(ctx, newFact, mode)
+	var dupErr *store.DuplicateError
+	if errors.As(err, &dupErr) {
+		printSimilarFacts(dupErr.Similar)
+		return fmt.Errorf("not stored: %w", err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to store fact: %w", err)
 	}
 
+	if mode == store.DuplicateMerge && len(similar) > 0 {
+		fmt.Printf("Merged into existing fact #%d\n", stored.ID)
+		return nil
+	}
+	if len(similar) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: similar to existing fact(s) %s\n", store.FormatFactIDs(similar))
+		printSimilarFacts(similar)
+	}
+
+	if stored.ExpiresAt != nil {
+		fmt.Printf("Stored fact #%d (expires %s)\n", stored.ID, stored.ExpiresAt.Format("2006-01-02 15:04"))
+		return nil
+	}
 	fmt.Printf("Stored fact #%d\n", stored.ID)
 	return nil
 }
+
+func printSimilarFacts(facts []store.Fact) {
+	for _, f := range facts {
+		fmt.Fprintf(os.Stderr, "  #%d (%.0f%% similar) %s\n", f.ID, f.Score*100, truncateLine(f.Content, 80))
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var restoreForce bool
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore the database from a backup",
+	Long: `Replace the database with a backup after checking its integrity. The current
+database is backed up to ~/.clauder/backups first.
+
+Restoring while MCP servers are running would swap their data underneath
+them, so this refuses to run while instances are registered unless --force
+is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "Restore even while instances are running")
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid path %s: %w", args[0], err)
+	}
+	if err := store.VerifyBackup(path); err != nil {
+		return fmt.Errorf("backup %s is not usable: %w", path, err)
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err := requireNoInstances(ctx, s, restoreForce); err != nil {
+		return err
+	}
+
+	safety := filepath.Join(backupDir(dataDir), "pre-restore-"+time.Now().Format("20060102-150405")+".db")
+	if err := s.Backup(ctx, safety); err != nil {
+		return fmt.Errorf("failed to back up current database: %w", err)
+	}
+
+	if err := s.Restore(ctx, path); err != nil {
+		return fmt.Errorf("failed to restore database: %w", err)
+	}
+
+	fmt.Printf("Restored from %s (previous database saved to %s)\n", path, safety)
+	return nil
+}
+
+// requireNoInstances fails if MCP servers are registered, unless force is set
+func requireNoInstances(ctx context.Context, s *store.SQLiteStore, force bool) error {
+	_ = s.CleanupStaleInstances(ctx, store.StaleInstanceAge)
+	instances, err := s.GetInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get instances: %w", err)
+	}
+	if len(instances) > 0 && !force {
+		for _, inst := range instances {
+			fmt.Printf("  %s - %s\n", inst.ID, inst.Directory)
+		}
+		return fmt.Errorf("%d instance(s) are running; stop them or use --force", len(instances))
+	}
+	return nil
+}
@@ -1,13 +1,16 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-
+	"github.com/maorbril/clauder/internal/profile"
 	"github.com/maorbril/clauder/internal/telemetry"
 	"github.com/spf13/cobra"
 )
 
+var (
+	rootDataDir string
+	rootProfile string
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "clauder",
 	Short: "Claude Code harness for persistent memory and instance communication",
@@ -33,21 +36,42 @@ func Execute() error {
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&rootDataDir, "data-dir", "", "Keep data in this directory instead of a profile")
+	rootCmd.PersistentFlags().StringVar(&rootProfile, "profile", "", "Use this memory profile (default: the active profile, see 'clauder profile')")
+
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(rememberCmd)
 	rootCmd.AddCommand(recallCmd)
+	rootCmd.AddCommand(editCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(linkCmd)
+	rootCmd.AddCommand(forgetCmd)
+	rootCmd.AddCommand(pinCmd)
+	rootCmd.AddCommand(unpinCmd)
+	rootCmd.AddCommand(dedupeCmd)
+	rootCmd.AddCommand(tagsCmd)
+	rootCmd.AddCommand(relocateCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(scanCmd)
 	rootCmd.AddCommand(instancesCmd)
+	rootCmd.AddCommand(sessionsCmd)
 	rootCmd.AddCommand(sendCmd)
 	rootCmd.AddCommand(messagesCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(setupCmd)
+	rootCmd.AddCommand(profileCmd)
 }
 
-func getDataDir() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error getting home directory: %v\n", err)
-		os.Exit(1)
-	}
-	return home + "/.clauder"
+// getDataDir returns the data directory selected by --data-dir, --profile,
+// $CLAUDER_PROFILE or the active profile
+func getDataDir() (string, error) {
+	dir, _, err := profile.Resolve(rootDataDir, rootProfile)
+	return dir, err
 }
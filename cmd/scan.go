@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/maorbril/clauder/internal/secrets"
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Audit stored facts and messages for secrets",
+	Long: `Scan every fact, fact revision and message for secrets such as cloud keys,
+tokens, private keys, JWTs, passwords in connection strings and other
+high-entropy strings.
+
+New facts and messages are scanned before they are stored. The action taken
+and any extra patterns are configured in ~/.clauder/secrets.toml:
+
+  action = "redact"   # redact (default), reject or warn
+  entropy = true      # flag long random-looking strings
+
+  [[patterns]]
+  name = "internal-token"
+  regex = "itk_[A-Za-z0-9]{32}"
+
+Use 'clauder forget <id>' to remove a fact along with its revisions.`,
+	Args: cobra.NoArgs,
+	RunE: runScan,
+}
+
+func runScan(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	scanner, err := secrets.LoadScanner(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load secret scanner: %w", err)
+	}
+
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	var facts []store.Fact
+	if err := s.ExportFacts(ctx, store.FactQuery{}, func(f store.Fact) error {
+		facts = append(facts, f)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to read facts: %w", err)
+	}
+
+	var factHits, messageHits int
+	for _, f := range facts {
+		hit := false
+		if findings := scanner.Scan(f.Content); len(findings) > 0 {
+			fmt.Printf("Fact #%d (%s): %s\n", f.ID, f.SourceDir, describeFindings(findings))
+			hit = true
+		}
+		if findings := scanner.Scan(strings.Join(f.Details.Lines(), "\n")); len(findings) > 0 {
+			fmt.Printf("Fact #%d details: %s\n", f.ID, describeFindings(findings))
+			hit = true
+		}
+		revisions, err := s.GetFactRevisions(ctx, f.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read revisions of fact #%d: %w", f.ID, err)
+		}
+		for _, r := range revisions {
+			if findings := scanner.Scan(r.Content); len(findings) > 0 {
+				fmt.Printf("Fact #%d revision %d: %s\n", f.ID, r.ID, describeFindings(findings))
+				hit = true
+			}
+		}
+		if hit {
+			factHits++
+		}
+	}
+
+	if err := s.ExportMessages(ctx, func(m store.Message) error {
+		if findings := scanner.Scan(m.Content); len(findings) > 0 {
+			fmt.Printf("Message #%d (%s to %s): %s\n", m.ID, m.FromInstance, m.ToInstance, describeFindings(findings))
+			messageHits++
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	if factHits == 0 && messageHits == 0 {
+		fmt.Printf("No secrets found in %d fact(s) and their revisions, or in messages.\n", len(facts))
+		return nil
+	}
+	fmt.Printf("\nSecrets found in %d fact(s) and %d message(s).\n", factHits, messageHits)
+	return nil
+}
+
+func describeFindings(findings []secrets.Finding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = f.Rule + " " + f.Masked()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// scanSecrets applies the configured secret scanner to content before the
+// CLI stores it, reporting what it found on stderr
+func scanSecrets(content string) (string, error) {
+	dataDir, err := getDataDir()
+	if err != nil {
+		return "", err
+	}
+	scanner, err := secrets.LoadScanner(dataDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to load secret scanner: %w", err)
+	}
+	scanned, findings, err := scanner.Apply(content)
+	if err != nil {
+		return "", fmt.Errorf("not stored: %w", err)
+	}
+	if len(findings) > 0 {
+		if scanner.Action == secrets.ActionRedact {
+			fmt.Fprintf(os.Stderr, "Redacted %d secret(s): %s\n", len(findings), describeFindings(findings))
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: content contains secrets: %s\n", describeFindings(findings))
+		}
+	}
+	return scanned, nil
+}
@@ -17,7 +17,11 @@ var sendCmd = &cobra.Command{
 }
 
 func runSend(cmd *cobra.Command, args []string) error {
-	dataDir := getDataDir()
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
 	s, err := store.NewSQLiteStore(dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
@@ -28,7 +32,7 @@ func runSend(cmd *cobra.Command, args []string) error {
 	content := strings.Join(args[1:], " ")
 
 	// Check if target instance exists
-	target, err := s.GetInstance(to)
+	target, err := s.GetInstance(ctx, to)
 	if err != nil {
 		return fmt.Errorf("failed to find instance: %w", err)
 	}
@@ -36,7 +40,12 @@ func runSend(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("instance '%s' not found", to)
 	}
 
-	msg, err := s.SendMessage("cli", to, content)
+	content, err = scanSecrets(content)
+	if err != nil {
+		return err
+	}
+
+	msg, err := s.SendMessage(ctx, "cli", to, content)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
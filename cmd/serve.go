@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/maorbril/clauder/internal/gitrepo"
 	"github.com/maorbril/clauder/internal/mcp"
+	"github.com/maorbril/clauder/internal/secrets"
 	"github.com/maorbril/clauder/internal/store"
 	"github.com/spf13/cobra"
 )
@@ -21,36 +23,69 @@ var serveCmd = &cobra.Command{
 	RunE:  runServe,
 }
 
+var (
+	serveRequestTimeout time.Duration
+	serveGCInterval     time.Duration
+)
+
+func init() {
+	serveCmd.Flags().DurationVar(&serveRequestTimeout, "request-timeout", mcp.DefaultRequestTimeout, "Cancel tool calls that run longer than this (0 to disable)")
+	serveCmd.Flags().DurationVar(&serveGCInterval, "gc-interval", 0, "Run gc this often while serving, e.g. 24h (0 to disable)")
+}
+
 func runServe(cmd *cobra.Command, args []string) error {
-	dataDir := getDataDir()
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
 	s, err := store.NewSQLiteStore(dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
 	}
 	defer func() { _ = s.Close() }()
 
+	scanner, err := secrets.LoadScanner(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load secret scanner: %w", err)
+	}
+
 	workDir, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get working directory: %w", err)
 	}
 
+	// Attach repository identity to facts stored here before it was recorded
+	repo := gitrepo.Identify(workDir)
+	_, _ = s.AssignRepo(ctx, repo.Root, repo.ID)
+
+	// Drop facts that have outlived their TTL
+	_, _ = s.PurgeExpiredFacts(ctx)
+
+	// Take the daily rotating backup
+	_, _ = s.AutoBackup(ctx, backupDir(dataDir), store.AutoBackupInterval, store.DefaultBackupRetention)
+
 	instanceID := uuid.New().String()
 
 	// Register this instance
-	if err := s.RegisterInstance(instanceID, os.Getpid(), workDir); err != nil {
+	if err := s.RegisterInstance(ctx, instanceID, os.Getpid(), workDir); err != nil {
 		return fmt.Errorf("failed to register instance: %w", err)
 	}
+	// Record the session, which outlives the instance
+	if _, err := s.StartSession(ctx, instanceID, workDir); err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
 
 	// Setup cleanup on exit
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		<-sigChan
-		_ = s.UnregisterInstance(instanceID)
+		_ = s.EndSession(ctx, instanceID)
+		_ = s.UnregisterInstance(ctx, instanceID)
 		cancel()
 		os.Exit(0)
 	}()
@@ -64,18 +99,38 @@ func runServe(cmd *cobra.Command, args []string) error {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				_ = s.Heartbeat(instanceID)
+				_ = s.Heartbeat(ctx, instanceID)
 			}
 		}
 	}()
 
+	// Periodic maintenance, opt-in since it vacuums the shared database
+	if serveGCInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(serveGCInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					_, _ = s.GC(ctx, store.GCOptions{ReadMessageAge: store.DefaultReadMessageAge})
+				}
+			}
+		}()
+	}
+
 	// Run MCP server
 	server := mcp.NewServer(s, instanceID, workDir)
-	if err := server.Run(); err != nil {
-		_ = s.UnregisterInstance(instanceID)
+	server.SetSecretScanner(scanner)
+	server.SetRequestTimeout(serveRequestTimeout)
+	if err := server.Run(ctx); err != nil {
+		_ = s.EndSession(ctx, instanceID)
+		_ = s.UnregisterInstance(ctx, instanceID)
 		return err
 	}
 
-	_ = s.UnregisterInstance(instanceID)
+	_ = s.EndSession(ctx, instanceID)
+	_ = s.UnregisterInstance(ctx, instanceID)
 	return nil
 }
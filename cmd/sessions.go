@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionsLimit int
+	sessionsLocal bool
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List past and running MCP server sessions",
+	Long: `List MCP server sessions, newest first, with the client that connected and
+the handoff summary each session left with the end_session tool. The next
+session in the same directory sees the latest summary in get_context.`,
+	Args: cobra.NoArgs,
+	RunE: runSessions,
+}
+
+func init() {
+	sessionsCmd.Flags().IntVarP(&sessionsLimit, "limit", "n", 20, "Maximum number of sessions")
+	sessionsCmd.Flags().BoolVarP(&sessionsLocal, "local", "l", false, "Only show sessions in the current directory")
+}
+
+func runSessions(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	dir := ""
+	if sessionsLocal {
+		if dir, err = os.Getwd(); err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+	}
+
+	sessions, err := s.ListSessions(ctx, dir, sessionsLimit)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No sessions found.")
+		return nil
+	}
+
+	// Sessions of live instances are still running
+	_ = s.CleanupStaleInstances(ctx, store.StaleInstanceAge)
+	instances, err := s.GetInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list instances: %w", err)
+	}
+	running := make(map[string]bool)
+	for _, inst := range instances {
+		running[inst.ID] = true
+	}
+
+	fmt.Printf("Found %d session(s):\n\n", len(sessions))
+	for _, session := range sessions {
+		fmt.Printf("#%d %s\n", session.ID, session.Directory)
+		if session.Client != "" {
+			fmt.Printf("  Client: %s\n", strings.TrimSpace(session.Client+" "+session.ClientVersion))
+		}
+		fmt.Printf("  Started: %s\n", session.StartedAt.Format("2006-01-02 15:04:05"))
+		switch {
+		case session.EndedAt != nil:
+			fmt.Printf("  Ended: %s\n", session.EndedAt.Format("2006-01-02 15:04:05"))
+		case running[session.InstanceID]:
+			fmt.Printf("  Running as instance %s\n", session.InstanceID)
+		default:
+			fmt.Println("  Ended: unknown (the server did not shut down cleanly)")
+		}
+		if session.Summary != "" {
+			fmt.Printf("  Summary: %s\n", session.Summary)
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/maorbril/clauder/internal/profile"
 	"github.com/maorbril/clauder/internal/telemetry"
 	toml "github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
@@ -30,6 +31,8 @@ var setupCmd = &cobra.Command{
 	Long: `Adds clauder as an MCP server to various AI coding tool configurations.
 
 By default, adds to the global Claude Code config (~/.claude.json).
+The server is configured to use the memory chosen with --data-dir or
+--profile, or else the active profile, even if another is activated later.
 
 Supported tools:
   --project   Claude Code project config (.mcp.json)
@@ -65,6 +68,11 @@ type ClaudeConfig struct {
 }
 
 func runSetup(cmd *cobra.Command, args []string) error {
+	// Fail now rather than when the server first starts
+	if _, _, err := profile.Resolve(rootDataDir, rootProfile); err != nil {
+		return err
+	}
+
 	// Find the clauder binary path
 	binaryPath, err := getBinaryPath()
 	if err != nil {
@@ -119,6 +127,28 @@ func runSetup(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// serveArgs returns the arguments that start the MCP server, pinning the
+// data directory or profile chosen for setup, including the active profile,
+// so the server keeps using it after 'clauder profile use'
+func serveArgs() []string {
+	args := []string{"serve"}
+	if rootDataDir != "" {
+		if dir, err := filepath.Abs(rootDataDir); err == nil {
+			args = append(args, "--data-dir", dir)
+		}
+		return args
+	}
+	name := rootProfile
+	if name == "" {
+		// $CLAUDER_PROFILE or the active profile
+		_, name, _ = profile.Resolve("", "")
+	}
+	if name != "" {
+		args = append(args, "--profile", name)
+	}
+	return args
+}
+
 func getBinaryPath() (string, error) {
 	// First try to find in PATH
 	path, err := exec.LookPath("clauder")
@@ -163,7 +193,7 @@ func setupGlobalConfig(binaryPath string) error {
 	// Add clauder
 	mcpServers["clauder"] = map[string]interface{}{
 		"command": binaryPath,
-		"args":    []string{"serve"},
+		"args":    serveArgs(),
 	}
 	config["mcpServers"] = mcpServers
 
@@ -214,7 +244,7 @@ func setupProjectConfig(binaryPath string) error {
 	// Add clauder
 	config.McpServers["clauder"] = MCPServer{
 		Command: binaryPath,
-		Args:    []string{"serve"},
+		Args:    serveArgs(),
 	}
 
 	// Write back
@@ -262,7 +292,7 @@ func setupOpencodeConfig(binaryPath string) error {
 	// Add clauder with OpenCode's format
 	mcp["clauder"] = map[string]interface{}{
 		"type":    "local",
-		"command": []string{binaryPath, "serve"},
+		"command": append([]string{binaryPath}, serveArgs()...),
 		"enabled": true,
 	}
 	config["mcp"] = mcp
@@ -318,7 +348,7 @@ func setupCodexConfig(binaryPath string) error {
 	// Add clauder with Codex's format
 	mcpServers["clauder"] = map[string]interface{}{
 		"command": binaryPath,
-		"args":    []string{"serve"},
+		"args":    serveArgs(),
 	}
 	config["mcp_servers"] = mcpServers
 
@@ -373,7 +403,7 @@ func setupGeminiConfig(binaryPath string) error {
 	// Add clauder with Gemini CLI's format
 	mcpServers["clauder"] = map[string]interface{}{
 		"command": binaryPath,
-		"args":    []string{"serve"},
+		"args":    serveArgs(),
 	}
 	config["mcpServers"] = mcpServers
 
@@ -416,11 +446,18 @@ func addPermissionRules(config map[string]interface{}) {
 	// Clauder MCP tools to allow
 	clauderTools := []string{
 		"mcp__clauder__remember",
+		"mcp__clauder__update_fact",
 		"mcp__clauder__recall",
+		"mcp__clauder__forget",
+		"mcp__clauder__pin",
+		"mcp__clauder__unpin",
+		"mcp__clauder__link_facts",
+		"mcp__clauder__list_tags",
 		"mcp__clauder__get_context",
 		"mcp__clauder__list_instances",
 		"mcp__clauder__send_message",
 		"mcp__clauder__get_messages",
+		"mcp__clauder__end_session",
 	}
 
 	// Add permission rules for each tool
@@ -445,18 +482,25 @@ func setupClaudeMD() error {
 This project uses **clauder** for persistent memory across Claude Code sessions.
 
 ### Available Tools
-- **mcp__clauder__remember**: Store facts, decisions, or context
+- **mcp__clauder__remember**: Store facts, decisions, or context, with a kind (decision, convention, gotcha, todo, command) where one fits
+- **mcp__clauder__update_fact**: Correct a stored fact in place (keeps revision history)
 - **mcp__clauder__recall**: Search and retrieve stored facts
+- **mcp__clauder__forget**: Delete wrong or obsolete facts
+- **mcp__clauder__pin** / **mcp__clauder__unpin**: Keep key conventions at the top of context
+- **mcp__clauder__link_facts**: Mark a fact as superseded by a newer one, or relate facts
+- **mcp__clauder__list_tags**: List existing tags to reuse when storing facts
 - **mcp__clauder__get_context**: Load all relevant context for this directory
 - **mcp__clauder__list_instances**: List other running Claude Code sessions
 - **mcp__clauder__send_message**: Send messages to other instances
 - **mcp__clauder__get_messages**: Check for incoming messages
+- **mcp__clauder__end_session**: Leave a handoff summary for the next session in this directory
 
 ### Usage Guidelines
 1. **At session start**: Call ` + "`get_context`" + ` to load persistent memory
-2. **Store important info**: Use ` + "`remember`" + ` for decisions, architecture notes, preferences
+2. **Store important info**: Use ` + "`remember`" + ` for decisions, architecture notes, preferences; record how to build and test as ` + "`command`" + ` facts
 3. **Periodic message check**: Call ` + "`get_messages`" + ` periodically to check for messages from other instances
 4. **Cross-instance communication**: Use ` + "`list_instances`" + ` and ` + "`send_message`" + ` to coordinate with other sessions
+5. **At session end**: Call ` + "`end_session`" + ` with what was done and what comes next
 `
 
 	// Read existing CLAUDE.md or create new
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/maorbril/clauder/internal/profile"
 	toml "github.com/pelletier/go-toml/v2"
 )
 
@@ -118,8 +119,77 @@ func TestSetupGlobalConfig_NewFile(t *testing.T) {
 	}
 
 	args, ok := clauder["args"].([]interface{})
-	if !ok || len(args) != 1 || args[0] != "serve" {
-		t.Errorf("expected args [serve], got %v", clauder["args"])
+	if !ok || len(args) != 3 || args[0] != "serve" || args[1] != "--profile" || args[2] != "default" {
+		t.Errorf("expected args [serve --profile default], got %v", clauder["args"])
+	}
+}
+
+func TestSetupGlobalConfig_Profile(t *testing.T) {
+	tmpHome, cleanup := setupTempHome(t)
+	defer cleanup()
+
+	restoreHome := setTestHome(t, tmpHome)
+	defer restoreHome()
+
+	setupAllowAll = false
+	rootProfile = "work"
+	defer func() { rootProfile = "" }()
+
+	if err := setupGlobalConfig("/usr/local/bin/clauder"); err != nil {
+		t.Fatalf("setupGlobalConfig failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpHome, ".claude.json"))
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	var config struct {
+		McpServers map[string]MCPServer `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	args := config.McpServers["clauder"].Args
+	if strings.Join(args, " ") != "serve --profile work" {
+		t.Errorf("expected args [serve --profile work], got %v", args)
+	}
+}
+
+func TestSetupGlobalConfig_ActiveProfile(t *testing.T) {
+	tmpHome, cleanup := setupTempHome(t)
+	defer cleanup()
+
+	restoreHome := setTestHome(t, tmpHome)
+	defer restoreHome()
+	t.Setenv(profile.HomeEnv, "")
+	t.Setenv(profile.ProfileEnv, "")
+
+	// As after 'clauder profile use work'
+	clauderHome := filepath.Join(tmpHome, ".clauder")
+	if _, err := profile.Create(clauderHome, "work"); err != nil {
+		t.Fatalf("failed to create profile: %v", err)
+	}
+	if err := profile.SetActive(clauderHome, "work"); err != nil {
+		t.Fatalf("failed to activate profile: %v", err)
+	}
+
+	setupAllowAll = false
+	if err := setupGlobalConfig("/usr/local/bin/clauder"); err != nil {
+		t.Fatalf("setupGlobalConfig failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpHome, ".claude.json"))
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	var config struct {
+		McpServers map[string]MCPServer `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if args := config.McpServers["clauder"].Args; strings.Join(args, " ") != "serve --profile work" {
+		t.Errorf("expected the active profile to be pinned, got %v", args)
 	}
 }
 
@@ -263,8 +333,8 @@ func TestSetupProjectConfig_NewFile(t *testing.T) {
 		t.Errorf("expected command %s, got %s", binaryPath, clauder.Command)
 	}
 
-	if len(clauder.Args) != 1 || clauder.Args[0] != "serve" {
-		t.Errorf("expected args [serve], got %v", clauder.Args)
+	if strings.Join(clauder.Args, " ") != "serve --profile default" {
+		t.Errorf("expected args [serve --profile default], got %v", clauder.Args)
 	}
 }
 
@@ -356,10 +426,10 @@ func TestSetupOpencodeConfig_NewFile(t *testing.T) {
 	}
 
 	command, ok := clauder["command"].([]interface{})
-	if !ok || len(command) != 2 {
-		t.Errorf("expected command array with 2 elements, got %v", clauder["command"])
+	if !ok || len(command) != 4 {
+		t.Fatalf("expected command array with 4 elements, got %v", clauder["command"])
 	}
-	if command[0] != binaryPath || command[1] != "serve" {
+	if command[0] != binaryPath || command[1] != "serve" || command[3] != "default" {
 		t.Errorf("unexpected command: %v", command)
 	}
 }
@@ -453,8 +523,8 @@ func TestSetupCodexConfig_NewFile(t *testing.T) {
 	}
 
 	args, ok := clauder["args"].([]interface{})
-	if !ok || len(args) != 1 || args[0] != "serve" {
-		t.Errorf("expected args [serve], got %v", clauder["args"])
+	if !ok || len(args) != 3 || args[0] != "serve" || args[1] != "--profile" || args[2] != "default" {
+		t.Errorf("expected args [serve --profile default], got %v", clauder["args"])
 	}
 }
 
@@ -579,8 +649,8 @@ func TestSetupGeminiConfig_NewFile(t *testing.T) {
 	}
 
 	args, ok := clauder["args"].([]interface{})
-	if !ok || len(args) != 1 || args[0] != "serve" {
-		t.Errorf("expected args [serve], got %v", clauder["args"])
+	if !ok || len(args) != 3 || args[0] != "serve" || args[1] != "--profile" || args[2] != "default" {
+		t.Errorf("expected args [serve --profile default], got %v", clauder["args"])
 	}
 }
 
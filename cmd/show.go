@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var showDepth int
+
+var showCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a fact and the facts linked to it",
+	Long: `Show a fact in full, followed by its link graph: the facts it links to
+(followed up to --depth levels) and the facts that link to it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShow,
+}
+
+func init() {
+	showCmd.Flags().IntVarP(&showDepth, "depth", "d", 3, "How many levels of outgoing links to follow")
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid fact ID: %s", args[0])
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	fact, err := s.GetFactByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get fact: %w", err)
+	}
+	if fact == nil {
+		return fmt.Errorf("fact #%d not found", id)
+	}
+
+	fmt.Printf("#%d [%s]", fact.ID, fact.CreatedAt.Format("2006-01-02 15:04"))
+	if fact.Kind != store.KindNote {
+		fmt.Printf(" (%s)", factKindLabel(*fact))
+	}
+	if fact.Pinned {
+		fmt.Print(" (pinned)")
+	}
+	if fact.ExpiresAt != nil {
+		fmt.Printf(" (expires %s)", fact.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	if len(fact.SupersededBy) > 0 {
+		fmt.Printf(" (superseded by %s)", store.FormatIDs(fact.SupersededBy))
+	}
+	fmt.Println()
+	if len(fact.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(fact.Tags, ", "))
+	}
+	fmt.Printf("Dir: %s\n", fact.SourceDir)
+	if from := fact.Provenance(); from != "" {
+		fmt.Printf("From: %s\n", from)
+	}
+	if fact.LastRecalledAt != nil {
+		fmt.Printf("Recalled: %d time(s), last %s\n", fact.RecallCount, fact.LastRecalledAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("%s\n", fact.Content)
+	for _, line := range fact.Details.Lines() {
+		fmt.Println(line)
+	}
+
+	links, err := s.GetFactLinks(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get links: %w", err)
+	}
+	var outgoing, incoming []store.FactLink
+	for _, l := range links {
+		if l.FromID == id {
+			outgoing = append(outgoing, l)
+		} else {
+			incoming = append(incoming, l)
+		}
+	}
+
+	if len(outgoing) > 0 {
+		fmt.Println("\nLinks:")
+		visited := map[int64]bool{id: true}
+		if err := printLinkTree(ctx, s, outgoing, visited, 1); err != nil {
+			return err
+		}
+	}
+	if len(incoming) > 0 {
+		fmt.Println("\nLinked from:")
+		for _, l := range incoming {
+			from, err := s.PeekFact(ctx, l.FromID)
+			if err != nil {
+				return fmt.Errorf("failed to get fact #%d: %w", l.FromID, err)
+			}
+			if from != nil {
+				fmt.Printf("  #%d %s this: %s\n", from.ID, l.Kind, truncateLine(from.Content, 80))
+			}
+		}
+	}
+	return nil
+}
+
+// printLinkTree prints outgoing links indented by depth, following the
+// linked facts' own links until showDepth. Facts already printed are not
+// expanded again, so cycles of relates-to links terminate.
+func printLinkTree(ctx context.Context, s *store.SQLiteStore, links []store.FactLink, visited map[int64]bool, depth int) error {
+	for _, l := range links {
+		to, err := s.PeekFact(ctx, l.ToID)
+		if err != nil {
+			return fmt.Errorf("failed to get fact #%d: %w", l.ToID, err)
+		}
+		if to == nil {
+			continue
+		}
+		indent := strings.Repeat("  ", depth)
+		if visited[to.ID] {
+			fmt.Printf("%s%s #%d (shown above)\n", indent, l.Kind, to.ID)
+			continue
+		}
+		visited[to.ID] = true
+		fmt.Printf("%s%s #%d: %s\n", indent, l.Kind, to.ID, truncateLine(to.Content, 80))
+
+		if depth >= showDepth {
+			continue
+		}
+		next, err := s.GetFactLinks(ctx, to.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get links of fact #%d: %w", to.ID, err)
+		}
+		var children []store.FactLink
+		for _, nl := range next {
+			if nl.FromID == to.ID {
+				children = append(children, nl)
+			}
+		}
+		if err := printLinkTree(ctx, s, children, visited, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,8 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"time"
 
+	"github.com/maorbril/clauder/internal/profile"
 	"github.com/maorbril/clauder/internal/store"
 	"github.com/spf13/cobra"
 )
@@ -16,8 +16,15 @@ var statusCmd = &cobra.Command{
 	RunE:  runStatus,
 }
 
+// statusTopN is the number of directories and tags status lists
+const statusTopN = 10
+
 func runStatus(cmd *cobra.Command, args []string) error {
-	dataDir := getDataDir()
+	ctx := cmd.Context()
+	dataDir, profileName, err := profile.Resolve(rootDataDir, rootProfile)
+	if err != nil {
+		return err
+	}
 	s, err := store.NewSQLiteStore(dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to open store: %w", err)
@@ -30,33 +37,89 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	}
 
 	// Get facts stats
-	allFacts, err := s.GetFacts("", nil, "", 0)
+	stats, err := s.Stats(ctx, store.FactQuery{})
 	if err != nil {
-		return fmt.Errorf("failed to get facts: %w", err)
+		return fmt.Errorf("failed to get fact stats: %w", err)
 	}
-	localFacts, err := s.GetFacts("", nil, workDir, 0)
+	localFacts, err := s.CountFacts(ctx, store.FactQuery{SourceDir: workDir})
 	if err != nil {
-		return fmt.Errorf("failed to get local facts: %w", err)
+		return fmt.Errorf("failed to count local facts: %w", err)
 	}
 
 	// Get instances
-	if err := s.CleanupStaleInstances(5 * time.Minute); err != nil {
+	if err := s.CleanupStaleInstances(ctx, store.StaleInstanceAge); err != nil {
 		return fmt.Errorf("failed to cleanup stale instances: %w", err)
 	}
-	instances, err := s.GetInstances()
+	instances, err := s.GetInstances(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get instances: %w", err)
 	}
 
 	fmt.Println("Clauder Status")
 	fmt.Println("==============")
+	if profileName != "" {
+		fmt.Printf("Profile: %s\n", profileName)
+	}
 	fmt.Printf("Data directory: %s\n", dataDir)
 	fmt.Printf("Working directory: %s\n\n", workDir)
 
 	fmt.Println("Facts")
 	fmt.Println("-----")
-	fmt.Printf("Total facts: %d\n", len(allFacts))
-	fmt.Printf("Local facts (this directory): %d\n\n", len(localFacts))
+	fmt.Printf("Total facts: %d\n", stats.Total)
+	fmt.Printf("Pinned facts: %d\n", stats.Pinned)
+	fmt.Printf("Local facts (this directory): %d\n\n", localFacts)
+
+	if len(stats.Dirs) > 0 {
+		fmt.Println("By directory:")
+		for i, d := range stats.Dirs {
+			if i == statusTopN {
+				fmt.Printf("  ... and %d more\n", len(stats.Dirs)-statusTopN)
+				break
+			}
+			dir := d.Dir
+			if dir == store.GlobalScope {
+				dir = "(global)"
+			}
+			fmt.Printf("  %5d  %s\n", d.Count, dir)
+		}
+		fmt.Println()
+	}
+
+	if len(stats.Tags) > 0 {
+		fmt.Println("By tag:")
+		for i, t := range stats.Tags {
+			if i == statusTopN {
+				fmt.Printf("  ... and %d more\n", len(stats.Tags)-statusTopN)
+				break
+			}
+			fmt.Printf("  %5d  %s\n", t.Count, t.Tag)
+		}
+		fmt.Println()
+	}
+
+	if stats.Total > 0 {
+		fmt.Println("By age (since last update):")
+		for _, a := range stats.Ages {
+			fmt.Printf("  %5d  %s\n", a.Count, a.Label)
+		}
+		fmt.Println()
+	}
+
+	if len(stats.MostRecalled) > 0 {
+		fmt.Println("Most recalled:")
+		for _, f := range stats.MostRecalled {
+			fmt.Printf("  %5d  #%d %s\n", f.RecallCount, f.ID, truncateLine(f.Content, 60))
+		}
+		fmt.Println()
+	}
+
+	if stats.NeverRecalledCount > 0 {
+		fmt.Printf("Never recalled: %d fact(s), oldest first:\n", stats.NeverRecalledCount)
+		for _, f := range stats.NeverRecalled {
+			fmt.Printf("  %s  #%d %s\n", f.CreatedAt.Format("2006-01-02"), f.ID, truncateLine(f.Content, 60))
+		}
+		fmt.Println()
+	}
 
 	fmt.Println("Instances")
 	fmt.Println("---------")
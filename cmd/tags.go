@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/maorbril/clauder/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "List and manage tags",
+	Long:  `List every tag in use with the number of facts carrying it.`,
+	Args:  cobra.NoArgs,
+	RunE:  runTags,
+}
+
+var tagsRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a tag on every fact",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runTagsRename,
+}
+
+var tagsMergeCmd = &cobra.Command{
+	Use:   "merge <source>... <target>",
+	Short: "Merge one or more tags into another",
+	Long:  `Replace every source tag with the target tag. Facts carrying several of the tags keep a single target tag.`,
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runTagsMerge,
+}
+
+func init() {
+	tagsCmd.AddCommand(tagsRenameCmd)
+	tagsCmd.AddCommand(tagsMergeCmd)
+}
+
+func runTags(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	tags, err := s.ListTags(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	if len(tags) == 0 {
+		fmt.Println("No tags found.")
+		return nil
+	}
+
+	fmt.Printf("Found %d tag(s):\n\n", len(tags))
+	for _, t := range tags {
+		fmt.Printf("  %-30s %d\n", t.Tag, t.Count)
+	}
+
+	return nil
+}
+
+func runTagsRename(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	n, err := s.RenameTag(ctx, args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("failed to rename tag: %w", err)
+	}
+
+	fmt.Printf("Renamed %q to %q on %d fact(s)\n", args[0], args[1], n)
+	return nil
+}
+
+func runTagsMerge(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	s, err := store.NewSQLiteStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	sources, target := args[:len(args)-1], args[len(args)-1]
+	n, err := s.MergeTags(ctx, sources, target)
+	if err != nil {
+		return fmt.Errorf("failed to merge tags: %w", err)
+	}
+
+	fmt.Printf("Merged %d tag(s) into %q (%d fact(s) updated)\n", len(sources), target, n)
+	return nil
+}
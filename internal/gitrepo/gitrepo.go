@@ -0,0 +1,132 @@
+package gitrepo
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Info identifies the git repository a directory belongs to
+type Info struct {
+	// Root is the absolute path of the working tree root
+	Root string
+	// ID identifies the repository independently of where it is checked
+	// out: a normalized remote URL, or "commit:<sha>" of the root commit for
+	// repositories without a remote. Empty if neither is available.
+	ID string
+	// Path is the directory relative to Root, using forward slashes ("." for
+	// the root itself)
+	Path string
+}
+
+// FindRoot returns the root of the git working tree containing dir, or ""
+// if dir is not inside one. Worktrees and submodules, where .git is a file,
+// are recognized as well.
+func FindRoot(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Identify returns repository information for dir. It returns the zero Info
+// if dir is not inside a git working tree.
+func Identify(dir string) Info {
+	root := FindRoot(dir)
+	if root == "" {
+		return Info{}
+	}
+
+	info := Info{Root: root, Path: RelPath(root, dir)}
+	if remote := remoteURL(root); remote != "" {
+		info.ID = NormalizeRemote(remote)
+	} else if commit := rootCommit(root); commit != "" {
+		info.ID = "commit:" + commit
+	}
+	return info
+}
+
+// RelPath returns dir relative to root with forward slashes, or "" if dir is
+// not inside root.
+func RelPath(root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// NormalizeRemote reduces the different spellings of a remote URL to
+// host/path, so that SSH and HTTPS clones of a repository compare equal:
+//
+//	git@github.com:Org/Repo.git       -> github.com/org/repo
+//	https://user@github.com/org/repo  -> github.com/org/repo
+//	ssh://git@host:2222/org/repo.git  -> host/org/repo
+func NormalizeRemote(remote string) string {
+	remote = strings.TrimSpace(remote)
+
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(remote, "@"); at >= 0 && strings.Contains(remote[at:], ":") {
+		// scp-like syntax: user@host:path
+		rest := remote[at+1:]
+		colon := strings.Index(rest, ":")
+		host, path = rest[:colon], rest[colon+1:]
+	} else {
+		// Local path remote
+		return filepath.ToSlash(filepath.Clean(remote))
+	}
+
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	return strings.ToLower(host + "/" + path)
+}
+
+// HeadCommit returns the SHA of the commit checked out in the working tree
+// containing dir, or "" if there is none
+func HeadCommit(dir string) string {
+	return git(dir, "rev-parse", "--verify", "--quiet", "HEAD")
+}
+
+func remoteURL(root string) string {
+	if out := git(root, "remote", "get-url", "origin"); out != "" {
+		return out
+	}
+	remotes := strings.Fields(git(root, "remote"))
+	if len(remotes) == 0 {
+		return ""
+	}
+	return git(root, "remote", "get-url", remotes[0])
+}
+
+func rootCommit(root string) string {
+	commits := strings.Fields(git(root, "rev-list", "--max-parents=0", "HEAD"))
+	if len(commits) == 0 {
+		return ""
+	}
+	// Merged histories can have several root commits; pick one deterministically
+	sort.Strings(commits)
+	return commits[0]
+}
+
+// git runs a git command in dir and returns its trimmed output, or "" if git
+// is unavailable or the command fails.
+func git(dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
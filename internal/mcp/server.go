@@ -2,12 +2,16 @@ package mcp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"sync"
+	"time"
 
+	"github.com/maorbril/clauder/internal/gitrepo"
+	"github.com/maorbril/clauder/internal/secrets"
 	"github.com/maorbril/clauder/internal/store"
 )
 
@@ -17,13 +21,28 @@ const (
 	ServerVersion   = "0.1.0" // Keep in sync with cmd.Version
 )
 
+// DefaultRequestTimeout is how long a request may run before it is cancelled
+const DefaultRequestTimeout = 30 * time.Second
+
 type Server struct {
 	store      store.Store
 	instanceID string
 	workDir    string
+	repo       gitrepo.Info
+	secrets    *secrets.Scanner
 	reader     *bufio.Reader
 	writer     io.Writer
+	timeout    time.Duration
 	mu         sync.Mutex
+
+	// inflight holds the cancel functions of queued and running requests,
+	// keyed by request ID
+	inflightMu sync.Mutex
+	inflight   map[string]context.CancelFunc
+
+	// client identifies the MCP client, as sent in its initialize request
+	clientMu sync.Mutex
+	client   ClientInfo
 }
 
 type Request struct {
@@ -119,12 +138,37 @@ func NewServer(s store.Store, instanceID, workDir string) *Server {
 		store:      s,
 		instanceID: instanceID,
 		workDir:    workDir,
+		repo:       gitrepo.Identify(workDir),
+		secrets:    secrets.NewScanner(),
 		reader:     bufio.NewReader(os.Stdin),
 		writer:     os.Stdout,
+		timeout:    DefaultRequestTimeout,
+		inflight:   make(map[string]context.CancelFunc),
 	}
 }
 
-func (s *Server) Run() error {
+// SetSecretScanner replaces the scanner applied to facts and messages before
+// they are stored
+func (s *Server) SetSecretScanner(scanner *secrets.Scanner) {
+	s.secrets = scanner
+}
+
+// SetRequestTimeout sets how long a request may run before it is cancelled.
+// Zero disables the timeout.
+func (s *Server) SetRequestTimeout(d time.Duration) {
+	s.timeout = d
+}
+
+// Run serves requests until the input is closed or ctx is done. Requests are
+// handled one at a time in the order they arrive, while the input is read
+// concurrently so notifications/cancelled can cancel a queued or running
+// request.
+func (s *Server) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	prev := make(chan struct{})
+	close(prev)
 	for {
 		line, err := s.reader.ReadBytes('\n')
 		if err != nil {
@@ -139,21 +183,91 @@ func (s *Server) Run() error {
 			s.sendError(nil, -32700, "Parse error", nil)
 			continue
 		}
+		if req.Method == "notifications/cancelled" {
+			s.handleCancelled(&req)
+			continue
+		}
+
+		reqCtx, cancel := s.startRequest(ctx, req.ID)
+		wait, done := prev, make(chan struct{})
+		prev = done
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done)
+			defer cancel()
+			<-wait
+			s.handleRequest(reqCtx, &req)
+			s.finishRequest(req.ID)
+		}()
+	}
+}
+
+// startRequest returns the context of a request, registering it so it can be
+// cancelled by ID
+func (s *Server) startRequest(ctx context.Context, id interface{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if id != nil {
+		s.inflightMu.Lock()
+		s.inflight[requestKey(id)] = cancel
+		s.inflightMu.Unlock()
+	}
+	return ctx, cancel
+}
 
-		s.handleRequest(&req)
+func (s *Server) finishRequest(id interface{}) {
+	if id == nil {
+		return
+	}
+	s.inflightMu.Lock()
+	delete(s.inflight, requestKey(id))
+	s.inflightMu.Unlock()
+}
+
+// handleCancelled cancels the request named by a notifications/cancelled
+// notification. Requests that already finished are ignored.
+func (s *Server) handleCancelled(req *Request) {
+	var params struct {
+		RequestID interface{} `json:"requestId"`
+		Reason    string      `json:"reason,omitempty"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.RequestID == nil {
+		return
 	}
+	s.inflightMu.Lock()
+	cancel, ok := s.inflight[requestKey(params.RequestID)]
+	s.inflightMu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// requestKey identifies a JSON-RPC request ID, which may be a number or a
+// string
+func requestKey(id interface{}) string {
+	return fmt.Sprintf("%T:%v", id, id)
 }
 
-func (s *Server) handleRequest(req *Request) {
+func (s *Server) handleRequest(ctx context.Context, req *Request) {
+	// A cancelled request gets no response
+	if ctx.Err() != nil {
+		return
+	}
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
 	switch req.Method {
 	case "initialize":
-		s.handleInitialize(req)
+		s.handleInitialize(ctx, req)
 	case "initialized":
 		// No response needed
 	case "tools/list":
 		s.handleToolsList(req)
 	case "tools/call":
-		s.handleToolCall(req)
+		s.handleToolCall(ctx, req)
 	case "ping":
 		s.sendResult(req.ID, map[string]interface{}{})
 	default:
@@ -161,7 +275,18 @@ func (s *Server) handleRequest(req *Request) {
 	}
 }
 
-func (s *Server) handleInitialize(req *Request) {
+func (s *Server) handleInitialize(ctx context.Context, req *Request) {
+	var params InitializeParams
+	if len(req.Params) > 0 {
+		// Best effort: the client is only recorded as fact and session
+		// provenance
+		_ = json.Unmarshal(req.Params, &params)
+	}
+	s.clientMu.Lock()
+	s.client = params.ClientInfo
+	s.clientMu.Unlock()
+	_ = s.store.SetSessionClient(ctx, s.instanceID, params.ClientInfo.Name, params.ClientInfo.Version)
+
 	result := InitializeResult{
 		ProtocolVersion: ProtocolVersion,
 		Capabilities: ServerCapability{
@@ -175,11 +300,18 @@ func (s *Server) handleInitialize(req *Request) {
 	s.sendResult(req.ID, result)
 }
 
+// clientInfo returns the client that initialized the session, if any
+func (s *Server) clientInfo() ClientInfo {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	return s.client
+}
+
 func (s *Server) handleToolsList(req *Request) {
 	tools := []Tool{
 		{
 			Name:        "remember",
-			Description: "Store a fact, decision, or piece of context for future sessions. Use this to persist important information that should be available across Claude Code sessions.",
+			Description: "Store a fact, decision, or piece of context for future sessions. Use this to persist important information that should be available across Claude Code sessions. Give it a kind and the kind's fields when it is a decision, convention, gotcha, todo or command, so it can be found by kind and shown in its own get_context section.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -187,44 +319,276 @@ func (s *Server) handleToolsList(req *Request) {
 						Type:        "string",
 						Description: "The fact, decision, or context to remember",
 					},
+					"kind": {
+						Type:        "string",
+						Description: "What sort of fact this is (default: note). Commands must also give 'command'",
+						Enum:        []string{"note", "decision", "convention", "gotcha", "todo", "command"},
+					},
+					"rationale": {
+						Type:        "string",
+						Description: "Decisions only: why this was chosen",
+					},
+					"alternatives": {
+						Type:        "array",
+						Description: "Decisions only: the options that were considered and rejected",
+						Items:       &Items{Type: "string"},
+					},
+					"example": {
+						Type:        "string",
+						Description: "Conventions only: a short example of following the convention",
+					},
+					"workaround": {
+						Type:        "string",
+						Description: "Gotchas only: how to avoid or work around the problem",
+					},
+					"command": {
+						Type:        "string",
+						Description: "Commands only (required): the command line to run",
+					},
+					"cwd": {
+						Type:        "string",
+						Description: "Commands only: the directory to run the command in",
+					},
 					"tags": {
 						Type:        "array",
-						Description: "Optional tags to categorize this fact (e.g., 'architecture', 'decision', 'preference')",
+						Description: "Optional tags to categorize this fact (e.g., 'architecture', 'decision', 'preference'). Tags are case-insensitive; prefer existing tags from list_tags",
 						Items:       &Items{Type: "string"},
 					},
+					"global": {
+						Type:        "boolean",
+						Description: "If true, store the fact for every directory (e.g. personal preferences) instead of the current one",
+					},
+					"ttl": {
+						Type:        "string",
+						Description: "Optional lifetime for facts that are only temporarily true (e.g. '12h', '3d', '2w'). The fact is forgotten once it expires",
+					},
+					"on_duplicate": {
+						Type:        "string",
+						Description: "What to do if a very similar fact already exists in this scope: 'warn' (default) stores it and lists the similar facts, 'reject' does not store it, 'merge' refreshes the existing fact and adds the new tags to it, 'allow' skips the check",
+						Enum:        []string{"warn", "reject", "merge", "allow"},
+					},
 				},
 				Required: []string{"fact"},
 			},
 		},
+		{
+			Name:        "update_fact",
+			Description: "Correct or refine a previously stored fact in place. The fact keeps its ID and creation time; the previous version is kept in its revision history.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"id": {
+						Type:        "integer",
+						Description: "The ID of the fact to update",
+					},
+					"fact": {
+						Type:        "string",
+						Description: "The new content of the fact (omit to keep the current content)",
+					},
+					"tags": {
+						Type:        "array",
+						Description: "Replacement tags (omit to keep the current tags, pass an empty list to clear them)",
+						Items:       &Items{Type: "string"},
+					},
+					"kind": {
+						Type:        "string",
+						Description: "A new kind for the fact (omit to keep the current kind). Changing the kind drops the fields of the old kind",
+						Enum:        []string{"note", "decision", "convention", "gotcha", "todo", "command"},
+					},
+					"rationale": {
+						Type:        "string",
+						Description: "Decisions only: why this was chosen",
+					},
+					"alternatives": {
+						Type:        "array",
+						Description: "Decisions only: the options that were considered and rejected",
+						Items:       &Items{Type: "string"},
+					},
+					"example": {
+						Type:        "string",
+						Description: "Conventions only: a short example of following the convention",
+					},
+					"workaround": {
+						Type:        "string",
+						Description: "Gotchas only: how to avoid or work around the problem",
+					},
+					"command": {
+						Type:        "string",
+						Description: "Commands only (required): the command line to run",
+					},
+					"cwd": {
+						Type:        "string",
+						Description: "Commands only: the directory to run the command in",
+					},
+					"done": {
+						Type:        "boolean",
+						Description: "Todos only: mark the todo done (hidden from get_context) or open again",
+					},
+				},
+				Required: []string{"id"},
+			},
+		},
 		{
 			Name:        "recall",
-			Description: "Search and retrieve stored facts. Use this to find previously stored context, decisions, or information.",
+			Description: "Search and retrieve stored facts. Use this to find previously stored context, decisions, or information. Query results are ranked by relevance and shown as highlighted excerpts.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"query": {
 						Type:        "string",
-						Description: "Search query to find relevant facts (uses full-text search)",
+						Description: "Search query. Words are ANDed; supports \"exact phrase\", prefix*, a OR b, -excluded, and filters tag:NAME, -tag:NAME, kind:KIND, dir:PATH (includes subdirectories), since:7d, before:2026-01-01",
 					},
 					"tags": {
 						Type:        "array",
 						Description: "Filter by tags",
 						Items:       &Items{Type: "string"},
 					},
+					"kind": {
+						Type:        "string",
+						Description: "Only return facts of this kind, e.g. 'command' for how to build and test, or 'gotcha' for known pitfalls",
+						Enum:        []string{"note", "decision", "convention", "gotcha", "todo", "command"},
+					},
 					"current_dir_only": {
 						Type:        "boolean",
-						Description: "If true, only return facts from the current directory",
+						Description: "If true, only return facts from the current directory and its parents up to the repository root",
+					},
+					"client": {
+						Type:        "string",
+						Description: "Only return facts stored by clients whose name starts with this, e.g. 'claude-code', 'codex', or 'cli' for the clauder command line",
 					},
 					"limit": {
 						Type:        "integer",
 						Description: "Maximum number of facts to return (default: 20)",
 					},
+					"cursor": {
+						Type:        "string",
+						Description: "The next_cursor of an earlier recall, to get the next page of results for the same query",
+					},
+					"prefer_recent": {
+						Type:        "boolean",
+						Description: "If true, blend relevance with recency so newer facts rank higher",
+					},
+					"mode": {
+						Type:        "string",
+						Description: "How to rank query matches: 'keyword' (default, exact words), 'semantic' (similar meaning, finds rewordings), or 'hybrid' (both)",
+						Enum:        []string{"keyword", "semantic", "hybrid"},
+					},
+					"full": {
+						Type:        "boolean",
+						Description: "If true, return full fact content instead of highlighted excerpts",
+					},
+					"include_superseded": {
+						Type:        "boolean",
+						Description: "If true, also return facts that were superseded by newer ones (default: false)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "forget",
+			Description: "Delete stored facts that are wrong or obsolete. Pass an 'id' to delete a single fact, or a recall-style filter to delete matching facts. Filter deletes are a dry-run preview; to delete, call again with 'confirm' true and 'ids' set to the previewed IDs.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"id": {
+						Type:        "integer",
+						Description: "The ID of a single fact to delete",
+					},
+					"query": {
+						Type:        "string",
+						Description: "Search query selecting facts to delete (same syntax as recall)",
+					},
+					"tags": {
+						Type:        "array",
+						Description: "Only delete facts with these tags",
+						Items:       &Items{Type: "string"},
+					},
+					"current_dir_only": {
+						Type:        "boolean",
+						Description: "If true, only delete facts stored from the current directory itself",
+					},
+					"include_superseded": {
+						Type:        "boolean",
+						Description: "If true, also match facts that were superseded by newer ones (default: false)",
+					},
+					"confirm": {
+						Type:        "boolean",
+						Description: "Must be true to actually delete facts matched by a filter (default: false, preview only)",
+					},
+					"ids": {
+						Type:        "array",
+						Description: "With confirm, the fact IDs listed by the dry run. Only these are deleted, and only if they still match the filter.",
+						Items:       &Items{Type: "integer"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "pin",
+			Description: "Pin a fact so it is always included at the top of get_context, no matter how old it is. Use this for key conventions and decisions.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"id": {
+						Type:        "integer",
+						Description: "The ID of the fact to pin",
+					},
+				},
+				Required: []string{"id"},
+			},
+		},
+		{
+			Name:        "unpin",
+			Description: "Unpin a previously pinned fact so it is ranked like any other fact.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"id": {
+						Type:        "integer",
+						Description: "The ID of the fact to unpin",
+					},
+				},
+				Required: []string{"id"},
+			},
+		},
+		{
+			Name:        "link_facts",
+			Description: "Record how two facts relate. When a decision changes, store the new fact and link it with 'supersedes' so the old one is hidden from recall and marked as replaced in get_context.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"from_id": {
+						Type:        "integer",
+						Description: "The ID of the fact the link starts from, e.g. the newer fact",
+					},
+					"to_id": {
+						Type:        "integer",
+						Description: "The ID of the fact the link points to, e.g. the fact being replaced",
+					},
+					"kind": {
+						Type:        "string",
+						Description: "'supersedes' (from_id replaces to_id), 'relates-to', or 'depends-on' (from_id depends on to_id)",
+						Enum:        []string{"supersedes", "relates-to", "depends-on"},
+					},
+					"remove": {
+						Type:        "boolean",
+						Description: "If true, remove the link instead of adding it",
+					},
 				},
+				Required: []string{"from_id", "to_id", "kind"},
+			},
+		},
+		{
+			Name:        "list_tags",
+			Description: "List the tags already in use with their fact counts. Check this before tagging a new fact so you reuse existing tags instead of inventing near-duplicates.",
+			InputSchema: InputSchema{
+				Type:       "object",
+				Properties: map[string]Property{},
 			},
 		},
 		{
 			Name:        "get_context",
-			Description: "Get all relevant context for the current working directory. Call this at the start of a session to load persistent context.",
+			Description: "Get all relevant context for the current working directory, grouped by scope: this directory, its parents up to the repository root, and global facts. Call this at the start of a session to load persistent context.",
 			InputSchema: InputSchema{
 				Type:       "object",
 				Properties: map[string]Property{},
@@ -269,12 +633,26 @@ func (s *Server) handleToolsList(req *Request) {
 				},
 			},
 		},
+		{
+			Name:        "end_session",
+			Description: "Leave a handoff summary for the next session in this directory, which sees it at the top of get_context. Call this when finishing a piece of work: what was done, what is in progress and what to do next.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"summary": {
+						Type:        "string",
+						Description: "A short handoff: what this session did, what is unfinished and suggested next steps",
+					},
+				},
+				Required: []string{"summary"},
+			},
+		},
 	}
 
 	s.sendResult(req.ID, map[string]interface{}{"tools": tools})
 }
 
-func (s *Server) handleToolCall(req *Request) {
+func (s *Server) handleToolCall(ctx context.Context, req *Request) {
 	var params ToolCallParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		s.sendError(req.ID, -32602, "Invalid params", nil)
@@ -285,17 +663,31 @@ func (s *Server) handleToolCall(req *Request) {
 
 	switch params.Name {
 	case "remember":
-		result = s.toolRemember(params.Arguments)
+		result = s.toolRemember(ctx, params.Arguments)
+	case "update_fact":
+		result = s.toolUpdateFact(ctx, params.Arguments)
 	case "recall":
-		result = s.toolRecall(params.Arguments)
+		result = s.toolRecall(ctx, params.Arguments)
+	case "forget":
+		result = s.toolForget(ctx, params.Arguments)
+	case "pin":
+		result = s.toolPin(ctx, params.Arguments, true)
+	case "unpin":
+		result = s.toolPin(ctx, params.Arguments, false)
+	case "link_facts":
+		result = s.toolLinkFacts(ctx, params.Arguments)
+	case "list_tags":
+		result = s.toolListTags(ctx, params.Arguments)
 	case "get_context":
-		result = s.toolGetContext(params.Arguments)
+		result = s.toolGetContext(ctx, params.Arguments)
 	case "list_instances":
-		result = s.toolListInstances(params.Arguments)
+		result = s.toolListInstances(ctx, params.Arguments)
 	case "send_message":
-		result = s.toolSendMessage(params.Arguments)
+		result = s.toolSendMessage(ctx, params.Arguments)
 	case "get_messages":
-		result = s.toolGetMessages(params.Arguments)
+		result = s.toolGetMessages(ctx, params.Arguments)
+	case "end_session":
+		result = s.toolEndSession(ctx, params.Arguments)
 	default:
 		result = ToolResult{
 			Content: []ContentBlock{{Type: "text", Text: "Unknown tool: " + params.Name}},
@@ -303,6 +695,13 @@ func (s *Server) handleToolCall(req *Request) {
 		}
 	}
 
+	switch ctx.Err() {
+	case context.Canceled:
+		// The client cancelled the request and expects no response
+		return
+	case context.DeadlineExceeded:
+		result = errorResult(fmt.Sprintf("%s timed out after %s", params.Name, s.timeout))
+	}
 	s.sendResult(req.ID, result)
 }
 
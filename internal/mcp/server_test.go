@@ -0,0 +1,74 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_RequestTimeout(t *testing.T) {
+	server, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	server.reader = bufio.NewReader(strings.NewReader(
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"recall","arguments":{"query":"anything"}}}` + "\n" +
+			`{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n",
+	))
+	var out bytes.Buffer
+	server.writer = &out
+	server.SetRequestTimeout(time.Nanosecond)
+
+	if err := server.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 responses, got %q", out.String())
+	}
+	var resp struct {
+		ID     float64    `json:"id"`
+		Result ToolResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.ID != 1 || !resp.Result.IsError || !strings.Contains(resp.Result.Content[0].Text, "timed out") {
+		t.Errorf("expected a timeout error for request 1, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"id":2`) {
+		t.Errorf("expected the ping response second, got %s", lines[1])
+	}
+}
+
+func TestHandleCancelled(t *testing.T) {
+	server, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	var out bytes.Buffer
+	server.writer = &out
+
+	ctx, cancel := server.startRequest(context.Background(), float64(7))
+	defer cancel()
+	server.handleCancelled(&Request{Method: "notifications/cancelled", Params: json.RawMessage(`{"requestId":7,"reason":"user aborted"}`)})
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("expected the request to be cancelled, got %v", ctx.Err())
+	}
+
+	server.handleRequest(ctx, &Request{
+		ID:     float64(7),
+		Method: "tools/call",
+		Params: json.RawMessage(`{"name":"recall","arguments":{"query":"anything"}}`),
+	})
+	server.finishRequest(float64(7))
+	if out.Len() != 0 {
+		t.Errorf("expected no response to a cancelled request, got %s", out.String())
+	}
+
+	// Cancelling a finished request is ignored
+	server.handleCancelled(&Request{Method: "notifications/cancelled", Params: json.RawMessage(`{"requestId":7}`)})
+}
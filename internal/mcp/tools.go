@@ -1,10 +1,16 @@
 package mcp
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/maorbril/clauder/internal/gitrepo"
+	"github.com/maorbril/clauder/internal/secrets"
+	"github.com/maorbril/clauder/internal/store"
 	"github.com/maorbril/clauder/internal/telemetry"
 )
 
@@ -16,7 +22,15 @@ const (
 	MaxTagCount    = 50
 )
 
-func (s *Server) toolRemember(args map[string]interface{}) ToolResult {
+// Recall output tuning, shared with the recall command
+const (
+	// MaxExcerptLength caps fact bodies shown without a search snippet
+	MaxExcerptLength = 300
+	// RecencyHalfLife is the age at which recency ranking halves a score
+	RecencyHalfLife = 30 * 24 * time.Hour
+)
+
+func (s *Server) toolRemember(ctx context.Context, args map[string]interface{}) ToolResult {
 	telemetry.TrackMCPTool("remember")
 	fact, ok := args["fact"].(string)
 	if !ok || fact == "" {
@@ -27,149 +41,733 @@ func (s *Server) toolRemember(args map[string]interface{}) ToolResult {
 		return errorResult(fmt.Sprintf("fact exceeds maximum size of %d bytes", MaxFactSize))
 	}
 
-	var tags []string
-	if tagsRaw, ok := args["tags"].([]interface{}); ok {
-		if len(tagsRaw) > MaxTagCount {
-			return errorResult(fmt.Sprintf("too many tags (max %d)", MaxTagCount))
+	tags, err := parseTags(args["tags"])
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	kindStr, _ := args["kind"].(string)
+	kind, err := store.ParseFactKind(kindStr)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	details, err := parseDetails(args)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	if err := details.Validate(kind); err != nil {
+		return errorResult(err.Error())
+	}
+
+	secretNote, err := s.scanFields(append([]*string{&fact}, details.TextFields()...)...)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	newFact := store.Fact{
+		Content:   fact,
+		Kind:      kind,
+		Details:   details,
+		Tags:      tags,
+		SourceDir: s.workDir,
+		RepoID:    s.repo.ID,
+		RepoPath:  s.repo.Path,
+	}
+	s.setProvenance(&newFact)
+	if global, ok := args["global"].(bool); ok && global {
+		newFact.SourceDir = store.GlobalScope
+		newFact.RepoID, newFact.RepoPath, newFact.CommitSHA = "", "", ""
+	}
+	if ttl, ok := args["ttl"].(string); ok && ttl != "" {
+		d, err := store.ParseAge(ttl)
+		if err != nil || d == 0 {
+			return errorResult(fmt.Sprintf("invalid ttl %q (use e.g. 12h, 7d or 2w)", ttl))
 		}
-		for _, t := range tagsRaw {
-			if tag, ok := t.(string); ok {
-				if len(tag) > MaxTagLength {
-					return errorResult(fmt.Sprintf("tag exceeds maximum length of %d", MaxTagLength))
-				}
-				tags = append(tags, tag)
+		expiresAt := time.Now().Add(d)
+		newFact.ExpiresAt = &expiresAt
+	}
+
+	onDuplicate, _ := args["on_duplicate"].(string)
+	mode, err := store.ParseDuplicateMode(onDuplicate)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	stored, similar, err := s.store.RememberFact(ctx, newFact, mode)
+	var dupErr *store.DuplicateError
+	if errors.As(err, &dupErr) {
+		return errorResult(fmt.Sprintf("Not stored: %s is very similar. Use update_fact to refine it instead:\n%s",
+			store.FormatFactIDs(dupErr.Similar), similarFactList(dupErr.Similar)))
+	}
+	if err != nil {
+		return errorResult(fmt.Sprintf("failed to store fact: %v", err))
+	}
+
+	if mode == store.DuplicateMerge && len(similar) > 0 {
+		return textResult(fmt.Sprintf("Merged into existing fact #%d: %s", stored.ID, truncate(stored.Content, 100)) + secretNote)
+	}
+
+	msg := fmt.Sprintf("Stored fact #%d: %s", stored.ID, truncate(fact, 100))
+	if stored.ExpiresAt != nil {
+		msg += fmt.Sprintf(" (expires %s)", stored.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	msg += secretNote
+	if len(similar) > 0 {
+		msg += fmt.Sprintf("\n\nWarning: similar to existing fact(s) %s. Consider update_fact or forget to avoid duplicates:\n%s",
+			store.FormatFactIDs(similar), similarFactList(similar))
+	}
+	return textResult(msg)
+}
+
+// setProvenance records the client, instance and commit f is stored from
+func (s *Server) setProvenance(f *store.Fact) {
+	client := s.clientInfo()
+	f.Client, f.ClientVersion = client.Name, client.Version
+	f.InstanceID = s.instanceID
+	if s.repo.Root != "" {
+		f.CommitSHA = gitrepo.HeadCommit(s.workDir)
+	}
+}
+
+func (s *Server) toolUpdateFact(ctx context.Context, args map[string]interface{}) ToolResult {
+	telemetry.TrackMCPTool("update_fact")
+	idRaw, ok := args["id"].(float64)
+	if !ok || idRaw <= 0 {
+		return errorResult("id is required")
+	}
+	id := int64(idRaw)
+
+	fact, _ := args["fact"].(string)
+	if len(fact) > MaxFactSize {
+		return errorResult(fmt.Sprintf("fact exceeds maximum size of %d bytes", MaxFactSize))
+	}
+
+	tags, err := parseTags(args["tags"])
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	if _, ok := args["tags"]; ok && tags == nil {
+		// An explicit empty list clears the tags
+		tags = []string{}
+	}
+
+	kindStr, hasKind := args["kind"].(string)
+	details, err := parseDetails(args)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	done, hasDone := args["done"].(bool)
+	changeKind := hasKind || details != nil || hasDone
+
+	if fact == "" && tags == nil && !changeKind {
+		return errorResult("nothing to update: provide 'fact', 'tags', 'kind' and/or kind-specific fields")
+	}
+
+	var kind store.FactKind
+	if changeKind {
+		current, err := s.store.PeekFact(ctx, id)
+		if err != nil {
+			return errorResult(fmt.Sprintf("failed to find fact: %v", err))
+		}
+		if current == nil {
+			return errorResult(fmt.Sprintf("fact #%d not found", id))
+		}
+		kind = current.Kind
+		if hasKind {
+			if kind, err = store.ParseFactKind(kindStr); err != nil {
+				return errorResult(err.Error())
+			}
+		}
+		// Fields of the current kind are kept unless replaced
+		if kind == current.Kind {
+			details = current.Details.Merge(details)
+		}
+		if hasDone {
+			if details == nil {
+				details = &store.FactDetails{}
 			}
+			details.Done = done
+		}
+		if err := details.Validate(kind); err != nil {
+			return errorResult(err.Error())
 		}
 	}
 
-	stored, err := s.store.AddFact(fact, tags, s.workDir)
+	fields := details.TextFields()
+	if fact != "" {
+		fields = append(fields, &fact)
+	}
+	secretNote, err := s.scanFields(fields...)
 	if err != nil {
-		return errorResult(fmt.Sprintf("failed to store fact: %v", err))
+		return errorResult(err.Error())
+	}
+
+	updated, err := s.store.EditFact(ctx, id, store.FactEdit{
+		Content: fact,
+		Tags:    tags,
+		SetKind: changeKind,
+		Kind:    kind,
+		Details: details,
+	})
+	if err != nil {
+		return errorResult(fmt.Sprintf("failed to update fact: %v", err))
+	}
+	if updated == nil {
+		return errorResult(fmt.Sprintf("fact #%d not found", id))
 	}
 
-	return textResult(fmt.Sprintf("Stored fact #%d: %s", stored.ID, truncate(fact, 100)))
+	return textResult(fmt.Sprintf("Updated fact #%d: %s", updated.ID, truncate(updated.Content, 100)) + secretNote)
 }
 
-func (s *Server) toolRecall(args map[string]interface{}) ToolResult {
-	telemetry.TrackMCPTool("recall")
+func (s *Server) toolForget(ctx context.Context, args map[string]interface{}) ToolResult {
+	telemetry.TrackMCPTool("forget")
+
+	if idRaw, ok := args["id"].(float64); ok {
+		id := int64(idRaw)
+		fact, err := s.store.PeekFact(ctx, id)
+		if err != nil {
+			return errorResult(fmt.Sprintf("failed to find fact: %v", err))
+		}
+		if fact == nil {
+			return errorResult(fmt.Sprintf("fact #%d not found", id))
+		}
+		if err := s.store.DeleteFact(ctx, id); err != nil {
+			return errorResult(fmt.Sprintf("failed to forget fact: %v", err))
+		}
+		return textResult(fmt.Sprintf("Forgot fact #%d: %s", id, truncate(fact.Content, 100)))
+	}
+
 	query, _ := args["query"].(string)
+	_, hasTags := args["tags"].([]interface{})
+	currentDirOnly, _ := args["current_dir_only"].(bool)
+	if strings.TrimSpace(query) == "" && !hasTags && !currentDirOnly {
+		return errorResult("provide an 'id' or at least one filter (query, tags, current_dir_only)")
+	}
 
-	var tags []string
-	if tagsRaw, ok := args["tags"].([]interface{}); ok {
-		for _, t := range tagsRaw {
-			if tag, ok := t.(string); ok {
-				tags = append(tags, tag)
-			}
+	q, err := s.factQuery(args)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	if currentDirOnly {
+		// Unlike recall, never reach into parent directories or other
+		// checkouts when deleting
+		q.DirMode = store.DirExact
+		q.ScopeRoot, q.RepoID = "", ""
+	}
+	q.Limit = store.MaxLimit
+
+	var facts []store.Fact
+	for {
+		page, err := s.store.SearchFactsPage(ctx, q)
+		if err != nil {
+			return errorResult(fmt.Sprintf("failed to find facts: %v", err))
+		}
+		facts = append(facts, page.Facts...)
+		if page.NextCursor == "" {
+			break
 		}
+		q.Cursor = page.NextCursor
 	}
 
-	sourceDir := ""
-	if currentDirOnly, ok := args["current_dir_only"].(bool); ok && currentDirOnly {
-		sourceDir = s.workDir
+	if len(facts) == 0 {
+		return textResult("No facts found matching your filter.")
 	}
 
-	limit := 20
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Dry run: %d fact(s) would be forgotten:\n\n", len(facts)))
+		for _, f := range facts {
+			sb.WriteString(fmt.Sprintf("- #%d %s\n", f.ID, truncate(f.Content, 100)))
+		}
+		sb.WriteString("\nCall forget again with the same filter, confirm=true and 'ids' set to the IDs listed above to delete them.")
+		return textResult(sb.String())
+	}
+
+	// Only delete facts that were previewed and still match the filter, so
+	// facts added or changed since the dry run are never deleted unseen
+	idsRaw, ok := args["ids"].([]interface{})
+	if !ok || len(idsRaw) == 0 {
+		return errorResult("confirm requires 'ids': the IDs listed by the dry run")
+	}
+	previewed := make(map[int64]bool, len(idsRaw))
+	for _, raw := range idsRaw {
+		if id, ok := raw.(float64); ok {
+			previewed[int64(id)] = true
+		}
+	}
+
+	deleted := 0
+	for _, f := range facts {
+		if !previewed[f.ID] {
+			continue
+		}
+		if err := s.store.DeleteFact(ctx, f.ID); err != nil {
+			return errorResult(fmt.Sprintf("failed to forget fact #%d after deleting %d: %v", f.ID, deleted, err))
+		}
+		deleted++
+	}
+
+	text := fmt.Sprintf("Forgot %d fact(s).", deleted)
+	if skipped := len(facts) - deleted; skipped > 0 {
+		text += fmt.Sprintf(" %d matching fact(s) were not in 'ids' and were kept.", skipped)
+	}
+	if missing := len(previewed) - deleted; missing > 0 {
+		text += fmt.Sprintf(" %d ID(s) no longer match the filter and were kept.", missing)
+	}
+	return textResult(text)
+}
+
+// toolPin handles both the pin and unpin tools
+func (s *Server) toolPin(ctx context.Context, args map[string]interface{}, pinned bool) ToolResult {
+	action := "pin"
+	if !pinned {
+		action = "unpin"
+	}
+	telemetry.TrackMCPTool(action)
+
+	idRaw, ok := args["id"].(float64)
+	if !ok || idRaw <= 0 {
+		return errorResult("id is required")
+	}
+	id := int64(idRaw)
+
+	fact, err := s.store.SetPinned(ctx, id, pinned)
+	if err != nil {
+		return errorResult(fmt.Sprintf("failed to %s fact: %v", action, err))
+	}
+	if fact == nil {
+		return errorResult(fmt.Sprintf("fact #%d not found", id))
+	}
+
+	if pinned {
+		return textResult(fmt.Sprintf("Pinned fact #%d: %s", id, truncate(fact.Content, 100)))
+	}
+	return textResult(fmt.Sprintf("Unpinned fact #%d: %s", id, truncate(fact.Content, 100)))
+}
+
+func (s *Server) toolLinkFacts(ctx context.Context, args map[string]interface{}) ToolResult {
+	telemetry.TrackMCPTool("link_facts")
+
+	fromRaw, ok := args["from_id"].(float64)
+	if !ok || fromRaw <= 0 {
+		return errorResult("from_id is required")
+	}
+	toRaw, ok := args["to_id"].(float64)
+	if !ok || toRaw <= 0 {
+		return errorResult("to_id is required")
+	}
+	fromID, toID := int64(fromRaw), int64(toRaw)
+
+	kindStr, _ := args["kind"].(string)
+	kind, err := store.ParseLinkKind(kindStr)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	if remove, ok := args["remove"].(bool); ok && remove {
+		removed, err := s.store.UnlinkFacts(ctx, fromID, toID, kind)
+		if err != nil {
+			return errorResult(fmt.Sprintf("failed to unlink facts: %v", err))
+		}
+		if !removed {
+			return errorResult(fmt.Sprintf("no link #%d %s #%d", fromID, kind, toID))
+		}
+		return textResult(fmt.Sprintf("Removed link: #%d %s #%d", fromID, kind, toID))
+	}
+
+	if _, err := s.store.LinkFacts(ctx, fromID, toID, kind); err != nil {
+		return errorResult(fmt.Sprintf("failed to link facts: %v", err))
+	}
+	msg := fmt.Sprintf("Linked: #%d %s #%d", fromID, kind, toID)
+	if kind == store.LinkSupersedes {
+		msg += fmt.Sprintf(". Fact #%d is now hidden from recall unless include_superseded is set.", toID)
+	}
+	return textResult(msg)
+}
+
+func (s *Server) toolRecall(ctx context.Context, args map[string]interface{}) ToolResult {
+	telemetry.TrackMCPTool("recall")
+	q, err := s.factQuery(args)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	q.Limit = 20
 	if l, ok := args["limit"].(float64); ok {
-		limit = int(l)
+		q.Limit = int(l)
 	}
+	if preferRecent, ok := args["prefer_recent"].(bool); ok && preferRecent {
+		q.RecencyHalfLife = RecencyHalfLife
+	}
+	mode, _ := args["mode"].(string)
+	if q.Mode, err = store.ParseSearchMode(mode); err != nil {
+		return errorResult(err.Error())
+	}
+	full, _ := args["full"].(bool)
+	q.Cursor, _ = args["cursor"].(string)
+	q.Client, _ = args["client"].(string)
 
-	facts, err := s.store.GetFacts(query, tags, sourceDir, limit)
+	page, err := s.store.SearchFactsPage(ctx, q)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		return errorResult("invalid cursor: pass the next_cursor of an earlier recall with the same query")
+	}
 	if err != nil {
 		return errorResult(fmt.Sprintf("failed to recall facts: %v", err))
 	}
 
-	if len(facts) == 0 {
+	if len(page.Facts) == 0 {
+		if page.Total > 0 {
+			return textResult(fmt.Sprintf("No more facts: all %d were on earlier pages.", page.Total))
+		}
 		return textResult("No facts found matching your query.")
 	}
+	_ = s.store.RecordRecalls(ctx, store.FactIDs(page.Facts))
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Found %d fact(s):\n\n", len(facts)))
+	if page.NextCursor == "" && page.Offset == 0 {
+		sb.WriteString(fmt.Sprintf("Found %d fact(s):\n\n", page.Total))
+	} else {
+		sb.WriteString(fmt.Sprintf("Found %d fact(s), showing %d-%d:\n\n", page.Total, page.Offset+1, page.Offset+len(page.Facts)))
+	}
 
-	for _, f := range facts {
-		sb.WriteString(fmt.Sprintf("**#%d** [%s]\n", f.ID, f.CreatedAt.Format("2006-01-02 15:04")))
+	for _, f := range page.Facts {
+		sb.WriteString(fmt.Sprintf("**#%d** [%s]", f.ID, f.CreatedAt.Format("2006-01-02 15:04")))
+		if f.Kind != store.KindNote {
+			sb.WriteString(fmt.Sprintf(" (%s)", kindLabel(f)))
+		}
+		if q.HasText() {
+			sb.WriteString(fmt.Sprintf(" (score %.2f)", f.Score))
+		}
+		if f.Pinned {
+			sb.WriteString(" (pinned)")
+		}
+		if f.ExpiresAt != nil {
+			sb.WriteString(fmt.Sprintf(" (expires %s)", f.ExpiresAt.Format("2006-01-02 15:04")))
+		}
+		if len(f.SupersededBy) > 0 {
+			sb.WriteString(fmt.Sprintf(" (superseded by %s)", store.FormatIDs(f.SupersededBy)))
+		}
+		sb.WriteString("\n")
 		if len(f.Tags) > 0 {
 			sb.WriteString(fmt.Sprintf("Tags: %s\n", strings.Join(f.Tags, ", ")))
 		}
 		sb.WriteString(fmt.Sprintf("Dir: %s\n", f.SourceDir))
-		sb.WriteString(fmt.Sprintf("%s\n\n", f.Content))
+		if from := f.Provenance(); from != "" {
+			sb.WriteString(fmt.Sprintf("From: %s\n", from))
+		}
+		if full {
+			sb.WriteString(fmt.Sprintf("%s\n", f.Content))
+		} else {
+			sb.WriteString(fmt.Sprintf("%s\n", excerpt(f)))
+		}
+		for _, line := range f.Details.Lines() {
+			sb.WriteString(line + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	if page.NextCursor != "" {
+		sb.WriteString(fmt.Sprintf("next_cursor: %s (pass as cursor to get the next page)\n", page.NextCursor))
 	}
 
 	return textResult(sb.String())
 }
 
-func (s *Server) toolGetContext(args map[string]interface{}) ToolResult {
+func (s *Server) toolListTags(ctx context.Context, args map[string]interface{}) ToolResult {
+	telemetry.TrackMCPTool("list_tags")
+	tags, err := s.store.ListTags(ctx)
+	if err != nil {
+		return errorResult(fmt.Sprintf("failed to list tags: %v", err))
+	}
+
+	if len(tags) == 0 {
+		return textResult("No tags in use yet.")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d tag(s):\n\n", len(tags)))
+	for _, t := range tags {
+		sb.WriteString(fmt.Sprintf("- %s (%d)\n", t.Tag, t.Count))
+	}
+
+	return textResult(sb.String())
+}
+
+func (s *Server) toolGetContext(ctx context.Context, args map[string]interface{}) ToolResult {
 	telemetry.TrackMCPTool("get_context")
-	// Get facts from current directory
-	localFacts, err := s.store.GetFacts("", nil, s.workDir, 50)
+	// Get facts from the current directory and its ancestors up to the repo root
+	scopedFacts, err := s.store.SearchFacts(ctx, store.FactQuery{
+		SourceDir:   s.workDir,
+		DirMode:     store.DirAncestors,
+		ScopeRoot:   s.repo.Root,
+		RepoID:      s.repo.ID,
+		Limit:       50,
+		PinnedFirst: true,
+		// Superseded facts are shown with what replaced them
+		IncludeSuperseded: true,
+	})
 	if err != nil {
 		return errorResult(fmt.Sprintf("failed to get local context: %v", err))
 	}
 
-	// Get recent global facts (from all directories)
-	globalFacts, err := s.store.GetFacts("", nil, "", 20)
+	// Get facts that apply everywhere
+	globalFacts, err := s.store.SearchFacts(ctx, store.FactQuery{
+		SourceDir:         store.GlobalScope,
+		Limit:             20,
+		PinnedFirst:       true,
+		IncludeSuperseded: true,
+	})
 	if err != nil {
 		return errorResult(fmt.Sprintf("failed to get global context: %v", err))
 	}
 
+	// Get recent facts (from all directories)
+	recentFacts, err := s.store.GetFacts(ctx, "", nil, "", 20)
+	if err != nil {
+		return errorResult(fmt.Sprintf("failed to get recent context: %v", err))
+	}
+
+	scopedFacts = withoutDoneTodos(scopedFacts)
+	globalFacts = withoutDoneTodos(globalFacts)
+	recentFacts = withoutDoneTodos(recentFacts)
+
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("# Context for %s\n\n", s.workDir))
 
-	if len(localFacts) > 0 {
-		sb.WriteString("## Local Facts (this directory)\n\n")
-		for _, f := range localFacts {
-			tagStr := ""
-			if len(f.Tags) > 0 {
-				tagStr = fmt.Sprintf(" [%s]", strings.Join(f.Tags, ", "))
-			}
-			sb.WriteString(fmt.Sprintf("- %s%s\n", f.Content, tagStr))
+	// Lead with the handoff of the previous session here
+	if last, err := s.store.LastSession(ctx, s.workDir, s.instanceID); err == nil && last != nil {
+		sb.WriteString(fmt.Sprintf("Last session (%s): %s\n\n", formatAgo(time.Since(*last.SummarizedAt)), last.Summary))
+	}
+
+	shown := make(map[int64]bool)
+
+	// Pinned facts come first so they are never crowded out
+	var pinnedFacts []store.Fact
+	for _, f := range append(scopedFacts, globalFacts...) {
+		if f.Pinned {
+			pinnedFacts = append(pinnedFacts, f)
+			shown[f.ID] = true
 		}
+	}
+	if len(pinnedFacts) > 0 {
+		sb.WriteString("## Pinned Facts\n\n")
+		s.writeFactList(ctx, &sb, pinnedFacts, factListOptions{kind: true})
 		sb.WriteString("\n")
 	}
 
-	// Filter global facts to exclude local ones
-	var otherFacts []struct {
-		content string
-		dir     string
-		tags    []string
+	// Facts of a structured kind get a section each, ahead of plain notes
+	for _, section := range contextKindSections {
+		var facts []store.Fact
+		for _, f := range append(scopedFacts, globalFacts...) {
+			if !shown[f.ID] && f.Kind == section.kind {
+				facts = append(facts, f)
+				shown[f.ID] = true
+			}
+		}
+		if len(facts) > 0 {
+			sb.WriteString(fmt.Sprintf("## %s\n\n", section.title))
+			s.writeFactList(ctx, &sb, facts, factListOptions{})
+			sb.WriteString("\n")
+		}
 	}
-	localIDs := make(map[int64]bool)
-	for _, f := range localFacts {
-		localIDs[f.ID] = true
+
+	byDir := make(map[string][]store.Fact)
+	for _, f := range scopedFacts {
+		if shown[f.ID] {
+			continue
+		}
+		dir := f.SourceDir
+		if s.repo.ID != "" && f.RepoID == s.repo.ID {
+			// Facts from other checkouts of this repository
+			dir = filepath.Join(s.repo.Root, filepath.FromSlash(f.RepoPath))
+		}
+		byDir[dir] = append(byDir[dir], f)
 	}
+
+	for i, dir := range store.AncestorDirs(s.workDir, s.repo.Root) {
+		facts := byDir[dir]
+		if len(facts) == 0 {
+			continue
+		}
+		switch {
+		case i == 0:
+			sb.WriteString("## Local Facts (this directory)\n\n")
+		case dir == s.repo.Root:
+			sb.WriteString(fmt.Sprintf("## Repository Facts (%s)\n\n", dir))
+		default:
+			sb.WriteString(fmt.Sprintf("## Parent Directory Facts (%s)\n\n", dir))
+		}
+		s.writeFactList(ctx, &sb, facts, factListOptions{})
+		sb.WriteString("\n")
+		for _, f := range facts {
+			shown[f.ID] = true
+		}
+	}
+
+	var unpinnedGlobal []store.Fact
 	for _, f := range globalFacts {
-		if !localIDs[f.ID] {
-			otherFacts = append(otherFacts, struct {
-				content string
-				dir     string
-				tags    []string
-			}{f.Content, f.SourceDir, f.Tags})
+		if !shown[f.ID] {
+			unpinnedGlobal = append(unpinnedGlobal, f)
+			shown[f.ID] = true
+		}
+	}
+	if len(unpinnedGlobal) > 0 {
+		sb.WriteString("## Global Facts\n\n")
+		s.writeFactList(ctx, &sb, unpinnedGlobal, factListOptions{})
+		sb.WriteString("\n")
+	}
+
+	// Filter recent facts to exclude the ones already shown
+	var otherFacts []store.Fact
+	for _, f := range recentFacts {
+		if !shown[f.ID] {
+			otherFacts = append(otherFacts, f)
 		}
 	}
 
 	if len(otherFacts) > 0 {
 		sb.WriteString("## Recent Facts (other directories)\n\n")
-		for _, f := range otherFacts {
-			tagStr := ""
-			if len(f.tags) > 0 {
-				tagStr = fmt.Sprintf(" [%s]", strings.Join(f.tags, ", "))
-			}
-			sb.WriteString(fmt.Sprintf("- %s (%s)%s\n", f.content, f.dir, tagStr))
-		}
+		s.writeFactList(ctx, &sb, otherFacts, factListOptions{dir: true, kind: true})
 	}
 
-	if len(localFacts) == 0 && len(otherFacts) == 0 {
+	if len(shown) == 0 && len(otherFacts) == 0 {
 		sb.WriteString("No stored context yet. Use the `remember` tool to store facts and decisions.\n")
 	}
 
+	// Best effort: the context is returned even if usage could not be
+	// recorded
+	ids := store.FactIDs(otherFacts)
+	for id := range shown {
+		ids = append(ids, id)
+	}
+	_ = s.store.RecordRecalls(ctx, ids)
+
 	return textResult(sb.String())
 }
 
-func (s *Server) toolListInstances(args map[string]interface{}) ToolResult {
+// scanSecrets applies the secret scanner to content before it is stored. It
+// returns the content to store and a note to append to the result.
+func (s *Server) scanSecrets(content string) (string, string, error) {
+	note, err := s.scanFields(&content)
+	return content, note, err
+}
+
+// scanFields applies the secret scanner to each field in place, returning a
+// note to append to the result
+func (s *Server) scanFields(fields ...*string) (string, error) {
+	var all []secrets.Finding
+	for _, field := range fields {
+		scanned, findings, err := s.secrets.Apply(*field)
+		if err != nil {
+			return "", fmt.Errorf("not stored: %w; remove the secret, or note where it is kept instead of its value", err)
+		}
+		*field = scanned
+		all = append(all, findings...)
+	}
+	if len(all) == 0 {
+		return "", nil
+	}
+	if s.secrets.Action == secrets.ActionRedact {
+		return fmt.Sprintf("\n\nRedacted %d secret(s) before storing (%s).", len(all), secrets.Describe(all)), nil
+	}
+	return fmt.Sprintf("\n\nWarning: this looks like it contains secrets (%s), which will show up in every get_context.", secrets.Describe(all)), nil
+}
+
+// similarFactList renders near-duplicate facts with their similarity
+func similarFactList(facts []store.Fact) string {
+	var sb strings.Builder
+	for _, f := range facts {
+		sb.WriteString(fmt.Sprintf("- #%d (%.0f%% similar): %s\n", f.ID, f.Score*100, truncate(f.Content, 100)))
+	}
+	return sb.String()
+}
+
+// contextKindSections orders the get_context sections of structured facts
+var contextKindSections = []struct {
+	kind  store.FactKind
+	title string
+}{
+	{store.KindConvention, "Conventions"},
+	{store.KindCommand, "Commands"},
+	{store.KindGotcha, "Gotchas"},
+	{store.KindDecision, "Decisions"},
+	{store.KindTodo, "Open TODOs"},
+}
+
+// factListOptions selects what writeFactList shows besides each fact
+type factListOptions struct {
+	dir  bool
+	kind bool
+}
+
+// writeFactList renders facts as a bullet list with their structured fields,
+// noting which facts replaced superseded ones
+func (s *Server) writeFactList(ctx context.Context, sb *strings.Builder, facts []store.Fact, opts factListOptions) {
+	for _, f := range facts {
+		kindStr := ""
+		if opts.kind && f.Kind != store.KindNote {
+			kindStr = fmt.Sprintf("(%s) ", kindLabel(f))
+		}
+		dirStr := ""
+		if opts.dir {
+			dirStr = fmt.Sprintf(" (%s)", f.SourceDir)
+		}
+		tagStr := ""
+		if len(f.Tags) > 0 {
+			tagStr = fmt.Sprintf(" [%s]", strings.Join(f.Tags, ", "))
+		}
+		sb.WriteString(fmt.Sprintf("- %s%s%s%s%s\n", kindStr, f.Content, dirStr, tagStr, s.supersededNote(ctx, f)))
+		for _, line := range f.Details.Lines() {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+}
+
+// kindLabel names the kind of f, noting todos that are done
+func kindLabel(f store.Fact) string {
+	if f.Kind == store.KindTodo && f.Details != nil && f.Details.Done {
+		return "todo, done"
+	}
+	return string(f.Kind)
+}
+
+// withoutDoneTodos drops todos that are done, which get_context leaves out
+func withoutDoneTodos(facts []store.Fact) []store.Fact {
+	var open []store.Fact
+	for _, f := range facts {
+		if f.Kind != store.KindTodo || f.Details == nil || !f.Details.Done {
+			open = append(open, f)
+		}
+	}
+	return open
+}
+
+// supersededNote describes the facts that replaced f, if any
+func (s *Server) supersededNote(ctx context.Context, f store.Fact) string {
+	if len(f.SupersededBy) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, id := range f.SupersededBy {
+		newer, err := s.store.PeekFact(ctx, id)
+		if err != nil || newer == nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("#%d: %s", id, truncate(newer.Content, 100)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (superseded by %s)", strings.Join(parts, "; "))
+}
+
+func (s *Server) toolListInstances(ctx context.Context, args map[string]interface{}) ToolResult {
 	telemetry.TrackMCPTool("list_instances")
 	// Cleanup stale instances first
-	_ = s.store.CleanupStaleInstances(5 * time.Minute)
+	_ = s.store.CleanupStaleInstances(ctx, store.StaleInstanceAge)
 
-	instances, err := s.store.GetInstances()
+	instances, err := s.store.GetInstances(ctx)
 	if err != nil {
 		return errorResult(fmt.Sprintf("failed to list instances: %v", err))
 	}
@@ -195,7 +793,7 @@ func (s *Server) toolListInstances(args map[string]interface{}) ToolResult {
 	return textResult(sb.String())
 }
 
-func (s *Server) toolSendMessage(args map[string]interface{}) ToolResult {
+func (s *Server) toolSendMessage(ctx context.Context, args map[string]interface{}) ToolResult {
 	telemetry.TrackMCPTool("send_message")
 	to, ok := args["to"].(string)
 	if !ok || to == "" {
@@ -211,8 +809,13 @@ func (s *Server) toolSendMessage(args map[string]interface{}) ToolResult {
 		return errorResult(fmt.Sprintf("message exceeds maximum size of %d bytes", MaxMessageSize))
 	}
 
+	content, secretNote, err := s.scanSecrets(content)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
 	// Check if target instance exists
-	target, err := s.store.GetInstance(to)
+	target, err := s.store.GetInstance(ctx, to)
 	if err != nil {
 		return errorResult(fmt.Sprintf("failed to find instance: %v", err))
 	}
@@ -220,22 +823,22 @@ func (s *Server) toolSendMessage(args map[string]interface{}) ToolResult {
 		return errorResult(fmt.Sprintf("instance '%s' not found", to))
 	}
 
-	msg, err := s.store.SendMessage(s.instanceID, to, content)
+	msg, err := s.store.SendMessage(ctx, s.instanceID, to, content)
 	if err != nil {
 		return errorResult(fmt.Sprintf("failed to send message: %v", err))
 	}
 
-	return textResult(fmt.Sprintf("Message #%d sent to %s", msg.ID, to))
+	return textResult(fmt.Sprintf("Message #%d sent to %s", msg.ID, to) + secretNote)
 }
 
-func (s *Server) toolGetMessages(args map[string]interface{}) ToolResult {
+func (s *Server) toolGetMessages(ctx context.Context, args map[string]interface{}) ToolResult {
 	telemetry.TrackMCPTool("get_messages")
 	unreadOnly := true
 	if val, ok := args["unread_only"].(bool); ok {
 		unreadOnly = val
 	}
 
-	messages, err := s.store.GetMessages(s.instanceID, unreadOnly)
+	messages, err := s.store.GetMessages(ctx, s.instanceID, unreadOnly)
 	if err != nil {
 		return errorResult(fmt.Sprintf("failed to get messages: %v", err))
 	}
@@ -261,7 +864,7 @@ func (s *Server) toolGetMessages(args map[string]interface{}) ToolResult {
 
 		// Mark as read
 		if m.ReadAt == nil {
-			if err := s.store.MarkMessageRead(m.ID); err != nil {
+			if err := s.store.MarkMessageRead(ctx, m.ID); err != nil {
 				// Log error but don't fail the entire operation
 				sb.WriteString(fmt.Sprintf("  (warning: failed to mark as read: %v)\n", err))
 			}
@@ -271,8 +874,135 @@ func (s *Server) toolGetMessages(args map[string]interface{}) ToolResult {
 	return textResult(sb.String())
 }
 
+func (s *Server) toolEndSession(ctx context.Context, args map[string]interface{}) ToolResult {
+	telemetry.TrackMCPTool("end_session")
+	summary, ok := args["summary"].(string)
+	if !ok || strings.TrimSpace(summary) == "" {
+		return errorResult("summary is required")
+	}
+
+	if len(summary) > MaxMessageSize {
+		return errorResult(fmt.Sprintf("summary exceeds maximum size of %d bytes", MaxMessageSize))
+	}
+
+	summary, secretNote, err := s.scanSecrets(summary)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	if err := s.store.SummarizeSession(ctx, s.instanceID, summary); err != nil {
+		return errorResult(fmt.Sprintf("failed to save session summary: %v", err))
+	}
+
+	return textResult(fmt.Sprintf("Session summary saved. The next session in %s will see it in get_context.", s.workDir) + secretNote)
+}
+
 // Helpers
 
+// formatAgo describes a duration in the past, e.g. "2h ago"
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// factQuery builds a fact search from the recall-style "query", "tags" and
+// "current_dir_only" arguments.
+func (s *Server) factQuery(args map[string]interface{}) (store.FactQuery, error) {
+	query, _ := args["query"].(string)
+	q, err := store.ParseQuery(query)
+	if err != nil {
+		return store.FactQuery{}, fmt.Errorf("invalid query: %w", err)
+	}
+
+	if tagsRaw, ok := args["tags"].([]interface{}); ok {
+		for _, t := range tagsRaw {
+			if tag, ok := t.(string); ok {
+				q.Tags = append(q.Tags, tag)
+			}
+		}
+	}
+
+	if currentDirOnly, ok := args["current_dir_only"].(bool); ok && currentDirOnly {
+		q.SourceDir = s.workDir
+		q.DirMode = store.DirAncestors
+		q.ScopeRoot = s.repo.Root
+		q.RepoID = s.repo.ID
+	}
+
+	if kindStr, ok := args["kind"].(string); ok && kindStr != "" {
+		kind, err := store.ParseFactKind(kindStr)
+		if err != nil {
+			return store.FactQuery{}, err
+		}
+		q.Kinds = append(q.Kinds, kind)
+	}
+
+	if includeSuperseded, ok := args["include_superseded"].(bool); ok {
+		q.IncludeSuperseded = includeSuperseded
+	}
+
+	return q, nil
+}
+
+// parseDetails reads the kind-specific fields of a fact, returning nil if
+// none are set. "done" is left to the caller, since false is meaningful.
+func parseDetails(args map[string]interface{}) (*store.FactDetails, error) {
+	d := &store.FactDetails{}
+	d.Rationale, _ = args["rationale"].(string)
+	if alternatives, ok := args["alternatives"].([]interface{}); ok {
+		for _, a := range alternatives {
+			if alt, ok := a.(string); ok && alt != "" {
+				d.Alternatives = append(d.Alternatives, alt)
+			}
+		}
+	}
+	d.Example, _ = args["example"].(string)
+	d.Workaround, _ = args["workaround"].(string)
+	d.Command, _ = args["command"].(string)
+	d.Cwd, _ = args["cwd"].(string)
+	if d.IsZero() {
+		return nil, nil
+	}
+
+	size := 0
+	for _, field := range d.TextFields() {
+		size += len(*field)
+	}
+	if size > MaxFactSize {
+		return nil, fmt.Errorf("fact details exceed maximum size of %d bytes", MaxFactSize)
+	}
+	return d, nil
+}
+
+// parseTags validates a raw "tags" argument against the tag limits.
+func parseTags(raw interface{}) ([]string, error) {
+	tagsRaw, ok := raw.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	if len(tagsRaw) > MaxTagCount {
+		return nil, fmt.Errorf("too many tags (max %d)", MaxTagCount)
+	}
+	var tags []string
+	for _, t := range tagsRaw {
+		if tag, ok := t.(string); ok {
+			if len(tag) > MaxTagLength {
+				return nil, fmt.Errorf("tag exceeds maximum length of %d", MaxTagLength)
+			}
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
 func textResult(text string) ToolResult {
 	return ToolResult{
 		Content: []ContentBlock{{Type: "text", Text: text}},
@@ -286,6 +1016,15 @@ func errorResult(msg string) ToolResult {
 	}
 }
 
+// excerpt returns the search snippet of a fact, or a truncated body when the
+// fact was not matched by a full-text query.
+func excerpt(f store.Fact) string {
+	if f.Snippet != "" {
+		return f.Snippet
+	}
+	return truncate(f.Content, MaxExcerptLength)
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
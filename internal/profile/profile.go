@@ -0,0 +1,171 @@
+// Package profile resolves where clauder keeps its data. Each named profile
+// is a separate data directory with its own database, keys and settings.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// HomeEnv overrides the clauder home directory (default ~/.clauder)
+	HomeEnv = "CLAUDER_HOME"
+	// ProfileEnv selects a profile, overriding the active one
+	ProfileEnv = "CLAUDER_PROFILE"
+
+	// Default is the profile stored directly in the clauder home directory
+	Default = "default"
+
+	// activeFile, in the home directory, names the profile in use
+	activeFile = "profile"
+	// profilesDir, in the home directory, holds the named profiles
+	profilesDir = "profiles"
+)
+
+// ErrNotFound is returned for a profile that has not been created
+var ErrNotFound = errors.New("profile not found")
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidateName checks that name is usable as a directory name
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use lowercase letters, digits, - and _)", name)
+	}
+	return nil
+}
+
+// Home returns the clauder home directory: $CLAUDER_HOME or ~/.clauder
+func Home() (string, error) {
+	if home := os.Getenv(HomeEnv); home != "" {
+		return filepath.Abs(home)
+	}
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(userHome, ".clauder"), nil
+}
+
+// Dir returns the data directory of a profile
+func Dir(home, name string) string {
+	if name == Default {
+		return home
+	}
+	return filepath.Join(home, profilesDir, name)
+}
+
+// Exists reports whether a profile has been created. The default profile
+// always exists.
+func Exists(home, name string) bool {
+	if name == Default {
+		return true
+	}
+	info, err := os.Stat(Dir(home, name))
+	return err == nil && info.IsDir()
+}
+
+// Create makes the data directory of a new profile
+func Create(home, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	if Exists(home, name) {
+		return "", fmt.Errorf("profile %q already exists", name)
+	}
+	dir := Dir(home, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// List returns the default profile followed by the named ones, sorted
+func List(home string) ([]string, error) {
+	names := []string{Default}
+	entries, err := os.ReadDir(filepath.Join(home, profilesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var named []string
+	for _, e := range entries {
+		if e.IsDir() && ValidateName(e.Name()) == nil && e.Name() != Default {
+			named = append(named, e.Name())
+		}
+	}
+	sort.Strings(named)
+	return append(names, named...), nil
+}
+
+// Active returns the profile selected with SetActive, or Default
+func Active(home string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(home, activeFile))
+	if os.IsNotExist(err) {
+		return Default, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return Default, nil
+	}
+	return name, ValidateName(name)
+}
+
+// SetActive makes name the profile used when none is given
+func SetActive(home, name string) error {
+	if !Exists(home, name) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if name == Default {
+		err := os.Remove(filepath.Join(home, activeFile))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(home, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(home, activeFile), []byte(name+"\n"), 0644)
+}
+
+// Resolve returns the data directory to use and the profile it belongs to.
+// An explicit dataDir wins; otherwise the profile is the given name,
+// $CLAUDER_PROFILE or the active profile, in that order. The profile is ""
+// for an explicit dataDir.
+func Resolve(dataDir, name string) (string, string, error) {
+	if dataDir != "" {
+		if name != "" {
+			return "", "", fmt.Errorf("use either a data directory or a profile, not both")
+		}
+		dir, err := filepath.Abs(dataDir)
+		return dir, "", err
+	}
+
+	home, err := Home()
+	if err != nil {
+		return "", "", err
+	}
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	if name == "" {
+		if name, err = Active(home); err != nil {
+			return "", "", fmt.Errorf("failed to read active profile: %w", err)
+		}
+	}
+	if err := ValidateName(name); err != nil {
+		return "", "", err
+	}
+	if !Exists(home, name) {
+		return "", "", fmt.Errorf("%w: %s (create it with 'clauder profile create %s')", ErrNotFound, name, name)
+	}
+	return Dir(home, name), name, nil
+}
@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupTestHome(t *testing.T) (string, func()) {
+	t.Helper()
+	home, err := os.MkdirTemp("", "clauder-profile-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Setenv(HomeEnv, home)
+	t.Setenv(ProfileEnv, "")
+	return home, func() { _ = os.RemoveAll(home) }
+}
+
+func TestResolve(t *testing.T) {
+	home, cleanup := setupTestHome(t)
+	defer cleanup()
+
+	dir, name, err := Resolve("", "")
+	if err != nil || dir != home || name != Default {
+		t.Fatalf("expected default profile in %s, got %s %q (%v)", home, dir, name, err)
+	}
+
+	if _, _, err := Resolve("", "work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing profile, got %v", err)
+	}
+	workDir, err := Create(home, "work")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if workDir != filepath.Join(home, "profiles", "work") {
+		t.Errorf("unexpected profile dir %s", workDir)
+	}
+	if dir, name, _ := Resolve("", "work"); dir != workDir || name != "work" {
+		t.Errorf("expected work profile, got %s %q", dir, name)
+	}
+
+	if err := SetActive(home, "work"); err != nil {
+		t.Fatalf("SetActive failed: %v", err)
+	}
+	if _, name, _ := Resolve("", ""); name != "work" {
+		t.Errorf("expected the active profile, got %q", name)
+	}
+	t.Setenv(ProfileEnv, Default)
+	if _, name, _ := Resolve("", ""); name != Default {
+		t.Errorf("expected %s to override the active profile, got %q", ProfileEnv, name)
+	}
+
+	explicit := filepath.Join(home, "elsewhere")
+	if dir, name, err := Resolve(explicit, ""); err != nil || dir != explicit || name != "" {
+		t.Errorf("expected explicit data dir, got %s %q (%v)", dir, name, err)
+	}
+	if _, _, err := Resolve(explicit, "work"); err == nil {
+		t.Error("expected error for both a data dir and a profile")
+	}
+}
+
+func TestCreateAndList(t *testing.T) {
+	home, cleanup := setupTestHome(t)
+	defer cleanup()
+
+	for _, name := range []string{"personal", "work"} {
+		if _, err := Create(home, name); err != nil {
+			t.Fatalf("Create(%s) failed: %v", name, err)
+		}
+	}
+	if _, err := Create(home, "work"); err == nil {
+		t.Error("expected error creating an existing profile")
+	}
+	for _, name := range []string{"", "Work", "../x", "a/b", Default} {
+		if _, err := Create(home, name); err == nil {
+			t.Errorf("expected error creating profile %q", name)
+		}
+	}
+
+	names, err := List(home)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(names) != 3 || names[0] != Default || names[1] != "personal" || names[2] != "work" {
+		t.Errorf("expected default, personal, work, got %v", names)
+	}
+}
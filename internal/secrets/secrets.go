@@ -0,0 +1,297 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// ConfigFile is the name of the scanner configuration in the data directory
+const ConfigFile = "secrets.toml"
+
+// Action is what happens to content that contains secrets
+type Action int
+
+const (
+	// ActionRedact replaces each secret with a [REDACTED:<rule>] marker
+	ActionRedact Action = iota
+	// ActionReject refuses to store the content
+	ActionReject
+	// ActionWarn stores the content unchanged and reports the secrets
+	ActionWarn
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionReject:
+		return "reject"
+	case ActionWarn:
+		return "warn"
+	}
+	return "redact"
+}
+
+// ParseAction parses "redact", "reject" or "warn"
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "redact":
+		return ActionRedact, nil
+	case "reject":
+		return ActionReject, nil
+	case "warn":
+		return ActionWarn, nil
+	}
+	return ActionRedact, fmt.Errorf("invalid secret action %q (use redact, reject or warn)", s)
+}
+
+// Rule detects one kind of secret. If the pattern has a group named
+// "secret", only that group is reported and redacted.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// builtinRules are checked by every scanner
+var builtinRules = []Rule{
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{"private-key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----[\s\S]*?(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY-----|$)`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"connection-string", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:(?P<secret>[^\s@/]+)@`)},
+}
+
+// High-entropy detection: long tokens mixing upper case, lower case and
+// digits with at least this many bits of entropy per character. Hex strings
+// such as commit hashes and UUIDs have no upper case and are never flagged.
+const (
+	entropyRuleName  = "high-entropy"
+	minEntropyLength = 24
+	minEntropyBits   = 4.0
+)
+
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`)
+
+// Finding is one secret found in a text
+type Finding struct {
+	Rule  string
+	Start int
+	End   int
+	Match string
+}
+
+// Masked shows the start of the secret and its length, safe to print
+func (f Finding) Masked() string {
+	prefix := f.Match
+	if len(prefix) > 4 {
+		prefix = prefix[:4]
+	}
+	return fmt.Sprintf("%s… (%d chars)", prefix, len(f.Match))
+}
+
+// ErrSecretsFound is returned by Apply when the action is reject
+var ErrSecretsFound = errors.New("content contains secrets")
+
+// Scanner finds secrets in text before it is stored
+type Scanner struct {
+	Action  Action
+	Entropy bool
+	rules   []Rule
+}
+
+// NewScanner returns a scanner with the built-in rules that redacts secrets
+func NewScanner() *Scanner {
+	return &Scanner{
+		Action:  ActionRedact,
+		Entropy: true,
+		rules:   append([]Rule(nil), builtinRules...),
+	}
+}
+
+// AddRule adds a user-defined rule
+func (s *Scanner) AddRule(name, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", name, err)
+	}
+	s.rules = append(s.rules, Rule{Name: name, Pattern: re})
+	return nil
+}
+
+// Scan returns the secrets in text, in order and without overlaps
+func (s *Scanner) Scan(text string) []Finding {
+	var findings []Finding
+	for _, rule := range s.rules {
+		group := rule.Pattern.SubexpIndex("secret")
+		for _, loc := range rule.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if group > 0 && loc[2*group] >= 0 {
+				start, end = loc[2*group], loc[2*group+1]
+			}
+			if start < end {
+				findings = append(findings, Finding{Rule: rule.Name, Start: start, End: end, Match: text[start:end]})
+			}
+		}
+	}
+	if s.Entropy {
+		for _, loc := range entropyToken.FindAllStringIndex(text, -1) {
+			if token := text[loc[0]:loc[1]]; isHighEntropy(token) {
+				findings = append(findings, Finding{Rule: entropyRuleName, Start: loc[0], End: loc[1], Match: token})
+			}
+		}
+	}
+
+	// Earlier and longer findings win; named rules come before the entropy
+	// check, so they win ties
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Start != findings[j].Start {
+			return findings[i].Start < findings[j].Start
+		}
+		return findings[i].End > findings[j].End
+	})
+	var merged []Finding
+	for _, f := range findings {
+		if len(merged) > 0 && f.Start < merged[len(merged)-1].End {
+			continue
+		}
+		merged = append(merged, f)
+	}
+	return merged
+}
+
+// Apply scans text and applies the scanner's action. It returns the text to
+// store, which is redacted for ActionRedact, and the secrets found. With
+// ActionReject and any secret found it returns ErrSecretsFound.
+func (s *Scanner) Apply(text string) (string, []Finding, error) {
+	findings := s.Scan(text)
+	if len(findings) == 0 {
+		return text, nil, nil
+	}
+	switch s.Action {
+	case ActionReject:
+		return "", findings, fmt.Errorf("%w: %s", ErrSecretsFound, Describe(findings))
+	case ActionRedact:
+		return Redact(text, findings), findings, nil
+	}
+	return text, findings, nil
+}
+
+// Redact replaces each finding in text with a [REDACTED:<rule>] marker
+func Redact(text string, findings []Finding) string {
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(text[last:f.Start])
+		b.WriteString("[REDACTED:" + f.Rule + "]")
+		last = f.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Describe lists the rules that matched, e.g. "aws-access-key, jwt"
+func Describe(findings []Finding) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		if !seen[f.Rule] {
+			seen[f.Rule] = true
+			names = append(names, f.Rule)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func isHighEntropy(token string) bool {
+	if len(token) < minEntropyLength {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper && lower && digit && shannonEntropy(token) >= minEntropyBits
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]float64)
+	for _, r := range s {
+		counts[r]++
+	}
+	n := float64(len(s))
+	var entropy float64
+	for _, c := range counts {
+		p := c / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Config is the format of secrets.toml:
+//
+//	action = "reject"   # redact (default), reject or warn
+//	entropy = false     # disable the high-entropy check
+//
+//	[[patterns]]
+//	name = "internal-token"
+//	regex = "itk_[A-Za-z0-9]{32}"
+type Config struct {
+	Action   string          `toml:"action"`
+	Entropy  *bool           `toml:"entropy"`
+	Patterns []PatternConfig `toml:"patterns"`
+}
+
+type PatternConfig struct {
+	Name  string `toml:"name"`
+	Regex string `toml:"regex"`
+}
+
+// LoadScanner returns a scanner configured by secrets.toml in dataDir, or
+// the default scanner if there is no such file
+func LoadScanner(dataDir string) (*Scanner, error) {
+	scanner := NewScanner()
+	path := filepath.Join(dataDir, ConfigFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return scanner, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := toml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if scanner.Action, err = ParseAction(config.Action); err != nil {
+		return nil, err
+	}
+	if config.Entropy != nil {
+		scanner.Entropy = *config.Entropy
+	}
+	for _, p := range config.Patterns {
+		if p.Name == "" {
+			p.Name = "custom"
+		}
+		if err := scanner.AddRule(p.Name, p.Regex); err != nil {
+			return nil, err
+		}
+	}
+	return scanner, nil
+}
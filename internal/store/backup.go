@@ -0,0 +1,288 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Automatic backup defaults
+const (
+	DefaultBackupRetention = 7
+	AutoBackupInterval     = 24 * time.Hour
+)
+
+// Rotating backups are named clauder-YYYYMMDD-HHMMSS.db so they sort by age
+const (
+	backupPrefix     = "clauder-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102-150405"
+)
+
+// Backups taken before migrating are named pre-migrate-vN-YYYYMMDD-HHMMSS.db
+const preMigratePrefix = "pre-migrate-"
+
+// backupStepPages is the number of pages copied between checks for
+// cancellation
+const backupStepPages = 1024
+
+// Backup writes a consistent snapshot of the database to destPath using
+// SQLite's online backup API, so it is safe while other instances write.
+func (s *SQLiteStore) Backup(ctx context.Context, destPath string) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Write to a temporary file so a failed backup never leaves a torn file
+	tmpPath := destPath + ".tmp"
+	_ = os.Remove(tmpPath)
+	dest, err := sql.Open("sqlite3", tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := copyDatabase(ctx, dest, s.db); err != nil {
+		_ = dest.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := dest.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, destPath)
+}
+
+// BackupRotating writes a timestamped backup into dir and deletes the oldest
+// rotating backups beyond keep. It returns the path of the new backup.
+func (s *SQLiteStore) BackupRotating(ctx context.Context, dir string, keep int) (string, error) {
+	path := filepath.Join(dir, backupPrefix+time.Now().Format(backupTimeFormat)+backupSuffix)
+	if err := s.Backup(ctx, path); err != nil {
+		return "", err
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return path, err
+	}
+	if keep > 0 && len(backups) > keep {
+		for _, old := range backups[keep:] {
+			if err := os.Remove(old); err != nil {
+				return path, fmt.Errorf("failed to remove old backup: %w", err)
+			}
+		}
+	}
+	return path, nil
+}
+
+// AutoBackup writes a rotating backup into dir unless the newest one is less
+// than interval old. It returns the path of the new backup, or "" if none was
+// needed.
+func (s *SQLiteStore) AutoBackup(ctx context.Context, dir string, interval time.Duration, keep int) (string, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) > 0 {
+		if info, err := os.Stat(backups[0]); err == nil && time.Since(info.ModTime()) < interval {
+			return "", nil
+		}
+	}
+	return s.BackupRotating(ctx, dir, keep)
+}
+
+// ListBackups returns the rotating backups in dir, newest first
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// prunePreMigrateBackups deletes the oldest pre-migrate backups in dir
+// beyond keep. They sort by modification time, since the schema version in
+// their names does not sort as text.
+func prunePreMigrateBackups(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, preMigratePrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		backups = append(backups, backup{filepath.Join(dir, name), info.ModTime()})
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].modTime.Equal(backups[j].modTime) {
+			return backups[i].modTime.After(backups[j].modTime)
+		}
+		return backups[i].path > backups[j].path
+	})
+	for _, old := range backups[keep:] {
+		if err := os.Remove(old.path); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// VerifyBackup checks that path is an intact clauder database
+func VerifyBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("integrity check failed: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM facts").Scan(&count); err != nil {
+		return fmt.Errorf("not a clauder database: %w", err)
+	}
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("%w (schema version %d, this version supports up to %d)", ErrSchemaTooNew, version, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// Restore replaces the contents of the database with the backup at path,
+// after verifying its integrity. Older backups are migrated to the current
+// schema.
+func (s *SQLiteStore) Restore(ctx context.Context, path string) error {
+	if err := VerifyBackup(path); err != nil {
+		return err
+	}
+
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	// Refuse backups encrypted with a key we don't have before replacing
+	// anything
+	var hasSettings bool
+	if err := src.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'settings')").Scan(&hasSettings); err != nil {
+		return err
+	}
+	if hasSettings {
+		id, err := getSetting(src, settingKeyID)
+		if err != nil {
+			return err
+		}
+		if _, err := s.cipherFor(id); err != nil {
+			return err
+		}
+	}
+
+	if err := copyDatabase(ctx, s.db, src); err != nil {
+		return err
+	}
+	if err := s.migrate(); err != nil {
+		return err
+	}
+	return s.initEncryption()
+}
+
+// copyDatabase copies the main database of src into dst with SQLite's online
+// backup API
+func copyDatabase(ctx context.Context, dst, src *sql.DB) error {
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = srcConn.Close() }()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dstConn.Close() }()
+
+	return dstConn.Raw(func(dstDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			dstSQLite, ok := dstDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected destination driver %T", dstDriver)
+			}
+			srcSQLite, ok := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected source driver %T", srcDriver)
+			}
+
+			backup, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+			// Copy in chunks so a cancelled context stops the copy; finishing
+			// early rolls back the destination
+			for {
+				if err := ctx.Err(); err != nil {
+					_ = backup.Finish()
+					return err
+				}
+				done, err := backup.Step(backupStepPages)
+				if err != nil {
+					_ = backup.Finish()
+					return fmt.Errorf("failed to copy database: %w", err)
+				}
+				if done {
+					break
+				}
+			}
+			return backup.Finish()
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	kept, _ := store.AddFact(ctx, "fact in backup", []string{"arch"}, "/project")
+
+	backupDir, err := os.MkdirTemp("", "clauder-backup-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(backupDir) }()
+
+	path := filepath.Join(backupDir, "snapshot.db")
+	if err := store.Backup(ctx, path); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := VerifyBackup(path); err != nil {
+		t.Fatalf("VerifyBackup failed: %v", err)
+	}
+
+	_ = store.DeleteFact(ctx, kept.ID)
+	_, _ = store.AddFact(ctx, "fact after backup", nil, "/project")
+
+	if err := store.Restore(ctx, path); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	facts, _ := store.GetFacts(ctx, "", nil, "", 10)
+	if len(facts) != 1 || facts[0].ID != kept.ID || len(facts[0].Tags) != 1 {
+		t.Errorf("expected only the backed up fact, got %v", facts)
+	}
+	if found, _ := store.GetFacts(ctx, "backup", nil, "", 10); len(found) != 1 {
+		t.Error("expected full-text index to be restored")
+	}
+}
+
+func TestBackup_Cancelled(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	backupDir, err := os.MkdirTemp("", "clauder-backup-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(backupDir) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	path := filepath.Join(backupDir, "snapshot.db")
+	if err := store.Backup(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	entries, _ := os.ReadDir(backupDir)
+	if len(entries) != 0 {
+		t.Errorf("expected no files left by a cancelled backup, got %d", len(entries))
+	}
+}
+
+func TestVerifyBackup_Invalid(t *testing.T) {
+	dir, err := os.MkdirTemp("", "clauder-backup-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	garbage := filepath.Join(dir, "garbage.db")
+	_ = os.WriteFile(garbage, []byte("not a database at all, just some text"), 0644)
+
+	for _, path := range []string{filepath.Join(dir, "missing.db"), garbage} {
+		if err := VerifyBackup(path); err == nil {
+			t.Errorf("VerifyBackup(%s) expected error", filepath.Base(path))
+		}
+	}
+}
+
+func TestVerifyBackup_NewerSchema(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	path := filepath.Join(store.dataDir, "newer.db")
+	if err := store.Backup(ctx, path); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to open backup: %v", err)
+	}
+	_, _ = db.Exec(fmt.Sprintf("PRAGMA user_version = %d", LatestSchemaVersion()+1))
+	_ = db.Close()
+
+	if err := VerifyBackup(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestBackupRotating(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	dir, err := os.MkdirTemp("", "clauder-backup-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// Pre-existing rotating backups, oldest first
+	for _, name := range []string{"clauder-20250101-000000.db", "clauder-20250102-000000.db", "clauder-20250103-000000.db"} {
+		_ = os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	_ = os.WriteFile(filepath.Join(dir, "pre-restore-20250101-000000.db"), nil, 0644)
+
+	path, err := store.BackupRotating(ctx, dir, 2)
+	if err != nil {
+		t.Fatalf("BackupRotating failed: %v", err)
+	}
+	backups, _ := ListBackups(dir)
+	if len(backups) != 2 || backups[0] != path || filepath.Base(backups[1]) != "clauder-20250103-000000.db" {
+		t.Errorf("expected newest two backups, got %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pre-restore-20250101-000000.db")); err != nil {
+		t.Error("expected other files to be left alone")
+	}
+
+	// A recent backup makes AutoBackup a no-op
+	path, err = store.AutoBackup(ctx, dir, time.Hour, 2)
+	if err != nil || path != "" {
+		t.Errorf("expected no new backup, got %q, %v", path, err)
+	}
+}
@@ -0,0 +1,394 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Encryption errors returned when opening an encrypted database
+var (
+	ErrEncryptionKeyRequired = errors.New("database is encrypted and no encryption key was found")
+	ErrWrongEncryptionKey    = errors.New("encryption key does not match the database")
+)
+
+// EncryptionKeySize is the size in bytes of AES-256 content keys
+const EncryptionKeySize = 32
+
+// Encrypted content is stored as enc:v1:<base64 nonce+ciphertext>
+const encryptedPrefix = "enc:v1:"
+
+// settingKeyID records the ID of the key content is encrypted with
+const settingKeyID = "encryption_key_id"
+
+// GenerateKey returns a new random content encryption key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodeKey encodes a key in the base64 form read from the environment,
+// key files and the keyring
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// DecodeKey parses a base64 encoded key
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("invalid encryption key: want %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+	return key, nil
+}
+
+// contentCipher encrypts content columns with AES-GCM. A nil cipher stores
+// content in plaintext.
+type contentCipher struct {
+	aead  cipher.AEAD
+	keyID string
+}
+
+func newContentCipher(key []byte) (*contentCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &contentCipher{aead: aead, keyID: keyID(key)}, nil
+}
+
+// keyID identifies a key without revealing it
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *contentCipher) seal(plaintext string) (string, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts stored content. Content without the encrypted prefix is
+// returned as is.
+func (c *contentCipher) open(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	if c == nil {
+		return "", ErrEncryptionKeyRequired
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("corrupted encrypted content")
+	}
+	nonceSize := c.aead.NonceSize()
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt content: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Encrypted reports whether fact and message content is encrypted
+func (s *SQLiteStore) Encrypted() bool {
+	return s.cipher != nil
+}
+
+// initEncryption loads the content key if the database is encrypted
+func (s *SQLiteStore) initEncryption() error {
+	id, err := getSetting(s.db, settingKeyID)
+	if err != nil {
+		return err
+	}
+	c, err := s.cipherFor(id)
+	if err != nil {
+		return err
+	}
+	s.cipher = c
+	s.index.ready = false
+	if c == nil {
+		// A restored plaintext database must not be shadowed by the index
+		// of an encrypted one
+		_, err = s.db.Exec("DROP TABLE IF EXISTS temp.facts_fts")
+		return err
+	}
+	// The search index lives in a per-connection temp table
+	s.db.SetMaxOpenConns(1)
+	return nil
+}
+
+// cipherFor loads the key with the given ID, or returns nil for an
+// unencrypted database
+func (s *SQLiteStore) cipherFor(id string) (*contentCipher, error) {
+	if id == "" {
+		return nil, nil
+	}
+	if s.cipher != nil && s.cipher.keyID == id {
+		return s.cipher, nil
+	}
+	key, source, err := LoadKey(s.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	// A key change that stopped between encrypting the database and
+	// replacing the current key left the matching key staged
+	for _, staged := range loadStagedKeys(s.dataDir) {
+		if keyID(staged) == id && (key == nil || keyID(key) != id) {
+			key = staged
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w; set %s, create %s or store the key in the OS keyring", ErrEncryptionKeyRequired, EncryptionKeyEnv, KeyFilePath(s.dataDir))
+	}
+	c, err := newContentCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if c.keyID != id {
+		return nil, fmt.Errorf("%w (key from %s)", ErrWrongEncryptionKey, source)
+	}
+	return c, nil
+}
+
+// KeySaver persists a new content key around the commit that re-encrypts
+// the database, so a failed commit never replaces the key still in use
+type KeySaver interface {
+	// Stage saves the new key next to the current one
+	Stage() error
+	// Promote replaces the current key with the staged one
+	Promote() error
+	// Discard removes the staged key
+	Discard() error
+}
+
+// SetEncryptionKey encrypts all fact, revision and message content, session
+// summaries and fact embeddings with key, re-encrypting content already
+// encrypted with the current key. Full-text search moves from the on-disk
+// index to an in-memory one, and the database is vacuumed so no plaintext is
+// left in free pages. saver, if set, stages the new key before committing
+// and promotes it once the commit succeeded.
+func (s *SQLiteStore) SetEncryptionKey(ctx context.Context, key []byte, saver KeySaver) error {
+	c, err := newContentCipher(key)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The on-disk index holds plaintext and its triggers would index
+	// ciphertext
+	if _, err := tx.ExecContext(ctx, `
+	DROP TRIGGER IF EXISTS facts_ai;
+	DROP TRIGGER IF EXISTS facts_ad;
+	DROP TRIGGER IF EXISTS facts_au;
+	DROP TABLE IF EXISTS main.facts_fts;
+	`); err != nil {
+		return err
+	}
+
+	for _, col := range []struct{ table, column string }{
+		{"facts", "content"},
+		{"facts", "details"},
+		{"fact_revisions", "content"},
+		{"fact_revisions", "details"},
+		{"messages", "content"},
+		{"sessions", "summary"},
+		{"fact_embeddings", "vector"},
+	} {
+		if err := s.reencryptTable(ctx, tx, col.table, col.column, c); err != nil {
+			return fmt.Errorf("failed to encrypt %s.%s: %w", col.table, col.column, err)
+		}
+	}
+	if err := setSetting(ctx, tx, settingKeyID, c.keyID); err != nil {
+		return err
+	}
+	if saver != nil {
+		if err := saver.Stage(); err != nil {
+			return fmt.Errorf("failed to save encryption key: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		if saver != nil {
+			_ = saver.Discard()
+		}
+		return err
+	}
+
+	s.cipher = c
+	s.index.ready = false
+	s.db.SetMaxOpenConns(1)
+
+	if saver != nil {
+		if err := saver.Promote(); err != nil {
+			return fmt.Errorf("database is encrypted with the new key, but it could not replace the old key (the new key stays staged and is still found): %w", err)
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
+
+// reencryptTable rewrites a text or blob column of table with c, skipping
+// NULLs
+func (s *SQLiteStore) reencryptTable(ctx context.Context, tx *sql.Tx, table, column string, c *contentCipher) error {
+	rows, err := tx.QueryContext(ctx, "SELECT rowid, "+column+" FROM "+table+" WHERE "+column+" IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	contents := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		contents[id] = content
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, stored := range contents {
+		plaintext, err := s.cipher.open(stored)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", id, err)
+		}
+		sealed, err := c.seal(plaintext)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE "+table+" SET "+column+" = ? WHERE rowid = ?", sealed, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncSearchIndex rebuilds the in-memory full-text index of an encrypted
+// database if facts may have changed since it was last built, here or in
+// another process. Unencrypted databases use the on-disk index.
+func (s *SQLiteStore) syncSearchIndex(ctx context.Context) error {
+	if s.cipher == nil {
+		return nil
+	}
+	s.index.mu.Lock()
+	defer s.index.mu.Unlock()
+
+	// Holding the store's only connection, nothing else writes while the
+	// index is checked and rebuilt
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM temp.sqlite_master WHERE name = 'facts_fts')").Scan(&exists); err != nil {
+		return err
+	}
+	var dataVersion, changes int64
+	if err := tx.QueryRowContext(ctx, "SELECT (SELECT data_version FROM pragma_data_version), total_changes()").Scan(&dataVersion, &changes); err != nil {
+		return err
+	}
+	if exists && s.index.ready && dataVersion == s.index.dataVersion && changes == s.index.changes {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS temp.facts_fts; CREATE VIRTUAL TABLE temp.facts_fts USING fts5(content)"); err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, content FROM facts")
+	if err != nil {
+		return err
+	}
+	contents := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		contents[id] = content
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, stored := range contents {
+		content, err := s.cipher.open(stored)
+		if err != nil {
+			return fmt.Errorf("fact #%d: %w", id, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO temp.facts_fts (rowid, content) VALUES (?, ?)", id, content); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// FTS5 writes the new index when the transaction commits
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes()").Scan(&changes); err != nil {
+		return err
+	}
+	s.index.ready = true
+	s.index.dataVersion = dataVersion
+	s.index.changes = changes
+	return nil
+}
+
+func getSetting(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, key string) (string, error) {
+	var value string
+	err := q.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func setSetting(ctx context.Context, tx *sql.Tx, key, value string) error {
+	_, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
+	return err
+}
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetEncryptionKey(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	fact, _ := store.AddFact(ctx, "staging database lives on db-internal-7", []string{"infra"}, "/project")
+	_, _ = store.UpdateFact(ctx, fact.ID, "staging database lives on db-internal-9", nil)
+	_, _ = store.SendMessage(ctx, "a", "b", "customer acme is on the beta")
+
+	key, _ := GenerateKey()
+	t.Setenv(EncryptionKeyEnv, EncodeKey(key))
+	if err := store.SetEncryptionKey(ctx, key, nil); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+	if !store.Encrypted() {
+		t.Error("expected store to report encryption")
+	}
+
+	for _, table := range []string{"facts", "fact_revisions", "messages"} {
+		var content string
+		_ = store.db.QueryRow("SELECT content FROM " + table).Scan(&content)
+		if !strings.HasPrefix(content, encryptedPrefix) {
+			t.Errorf("expected %s content to be encrypted, got %q", table, content)
+		}
+	}
+	var onDisk bool
+	_ = store.db.QueryRow("SELECT EXISTS (SELECT 1 FROM main.sqlite_master WHERE name = 'facts_fts')").Scan(&onDisk)
+	if onDisk {
+		t.Error("expected the on-disk full-text index to be dropped")
+	}
+
+	facts, err := store.GetFacts(ctx, "internal", nil, "", 10)
+	if err != nil {
+		t.Fatalf("GetFacts failed: %v", err)
+	}
+	if len(facts) != 1 || facts[0].Content != "staging database lives on db-internal-9" || !strings.Contains(facts[0].Snippet, "**internal**") {
+		t.Errorf("expected decrypted match with snippet, got %v", facts)
+	}
+	revisions, _ := store.GetFactRevisions(ctx, fact.ID)
+	if len(revisions) != 1 || revisions[0].Content != "staging database lives on db-internal-7" {
+		t.Errorf("expected decrypted revision, got %v", revisions)
+	}
+	messages, _ := store.GetMessages(ctx, "b", false)
+	if len(messages) != 1 || messages[0].Content != "customer acme is on the beta" {
+		t.Errorf("expected decrypted message, got %v", messages)
+	}
+
+	// New facts are searchable immediately, here and in other processes
+	_, _ = store.AddFact(ctx, "deploy with make release", nil, "/project")
+	if found, _ := store.GetFacts(ctx, "release", nil, "", 10); len(found) != 1 {
+		t.Errorf("expected new fact to be indexed, got %d", len(found))
+	}
+	other, err := NewSQLiteStore(store.dataDir)
+	if err != nil {
+		t.Fatalf("failed to reopen with key: %v", err)
+	}
+	defer func() { _ = other.Close() }()
+	_, _ = other.AddFact(ctx, "the release train leaves on fridays", nil, "/project")
+	if found, _ := store.GetFacts(ctx, "release", nil, "", 10); len(found) != 2 {
+		t.Errorf("expected fact from another connection to be indexed, got %d", len(found))
+	}
+}
+
+func TestSetEncryptionKey_RotateAndOpen(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(ctx, "rotate me", nil, "/project")
+	oldKey, _ := GenerateKey()
+	newKey, _ := GenerateKey()
+	t.Setenv(EncryptionKeyEnv, EncodeKey(oldKey))
+	if err := store.SetEncryptionKey(ctx, oldKey, nil); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+
+	saver := &testKeySaver{}
+	if err := store.SetEncryptionKey(ctx, newKey, saver); err != nil {
+		t.Fatalf("rotation failed: %v", err)
+	}
+	if !saver.staged || !saver.promoted || saver.discarded {
+		t.Errorf("expected the new key to be staged and promoted, got %+v", saver)
+	}
+	if found, _ := store.GetFacts(ctx, "rotate", nil, "", 10); len(found) != 1 || found[0].Content != "rotate me" {
+		t.Errorf("expected fact readable after rotation, got %v", found)
+	}
+
+	if _, err := NewSQLiteStore(store.dataDir); !errors.Is(err, ErrWrongEncryptionKey) {
+		t.Errorf("expected ErrWrongEncryptionKey with the old key, got %v", err)
+	}
+	t.Setenv(EncryptionKeyEnv, "")
+	if _, err := NewSQLiteStore(store.dataDir); !errors.Is(err, ErrEncryptionKeyRequired) {
+		t.Errorf("expected ErrEncryptionKeyRequired without a key, got %v", err)
+	}
+	t.Setenv(EncryptionKeyEnv, EncodeKey(newKey))
+	reopened, err := NewSQLiteStore(store.dataDir)
+	if err != nil {
+		t.Fatalf("failed to open with the new key: %v", err)
+	}
+	_ = reopened.Close()
+}
+
+func TestSetEncryptionKey_SaveFailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(ctx, "stays plaintext", nil, "/project")
+	key, _ := GenerateKey()
+	if err := store.SetEncryptionKey(ctx, key, &testKeySaver{stageErr: errors.New("keyring locked")}); err == nil {
+		t.Fatal("expected error when the key cannot be saved")
+	}
+	if store.Encrypted() {
+		t.Error("expected store to stay unencrypted")
+	}
+	if found, _ := store.GetFacts(ctx, "plaintext", nil, "", 10); len(found) != 1 {
+		t.Errorf("expected search to keep working, got %d", len(found))
+	}
+}
+
+// testKeySaver records the calls of SetEncryptionKey, wrapping next if set
+type testKeySaver struct {
+	next                        KeySaver
+	stageErr, promoteErr        error
+	afterStage                  func()
+	staged, promoted, discarded bool
+}
+
+func (k *testKeySaver) Stage() error {
+	if k.stageErr != nil {
+		return k.stageErr
+	}
+	k.staged = true
+	if k.next != nil {
+		if err := k.next.Stage(); err != nil {
+			return err
+		}
+	}
+	if k.afterStage != nil {
+		k.afterStage()
+	}
+	return nil
+}
+
+func (k *testKeySaver) Promote() error {
+	if k.promoteErr != nil {
+		return k.promoteErr
+	}
+	k.promoted = true
+	if k.next != nil {
+		return k.next.Promote()
+	}
+	return nil
+}
+
+func (k *testKeySaver) Discard() error {
+	k.discarded = true
+	if k.next != nil {
+		return k.next.Discard()
+	}
+	return nil
+}
+
+// encryptWithKeyFile encrypts store with a key saved in its key file
+func encryptWithKeyFile(t *testing.T, store *SQLiteStore) []byte {
+	t.Helper()
+	t.Setenv(EncryptionKeyEnv, "")
+	t.Setenv(EncryptionKeyFile, "")
+	key, _ := GenerateKey()
+	if err := store.SetEncryptionKey(context.Background(), key, NewKeySaver(store.dataDir, key, KeySourceFile)); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+	return key
+}
+
+func TestSetEncryptionKey_FailedCommitKeepsOldKey(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(context.Background(), "survives a failed rotation", nil, "/project")
+	oldKey := encryptWithKeyFile(t, store)
+
+	// Cancelling the context once the key is staged fails the commit
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newKey, _ := GenerateKey()
+	saver := &testKeySaver{next: NewKeySaver(store.dataDir, newKey, KeySourceFile), afterStage: cancel}
+	if err := store.SetEncryptionKey(ctx, newKey, saver); err == nil {
+		t.Fatal("expected the rotation to fail")
+	}
+	if !saver.discarded || saver.promoted {
+		t.Errorf("expected the staged key to be discarded, got %+v", saver)
+	}
+
+	if key, _, _ := LoadKey(store.dataDir); string(key) != string(oldKey) {
+		t.Error("expected the key file to keep the old key")
+	}
+	if _, err := os.Stat(stagedKeyFilePath(store.dataDir)); !os.IsNotExist(err) {
+		t.Errorf("expected no staged key to be left, got %v", err)
+	}
+	_ = store.Close()
+	reopened, err := NewSQLiteStore(store.dataDir)
+	if err != nil {
+		t.Fatalf("failed to open with the old key: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if found, _ := reopened.GetFacts(context.Background(), "survives", nil, "", 10); len(found) != 1 {
+		t.Errorf("expected the fact to stay readable, got %v", found)
+	}
+}
+
+func TestSetEncryptionKey_UnpromotedKeyStillOpens(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(ctx, "encrypted with the staged key", nil, "/project")
+	_ = encryptWithKeyFile(t, store)
+
+	// Stopping after the commit leaves the new key staged
+	newKey, _ := GenerateKey()
+	saver := &testKeySaver{next: NewKeySaver(store.dataDir, newKey, KeySourceFile), promoteErr: errors.New("interrupted")}
+	if err := store.SetEncryptionKey(ctx, newKey, saver); err == nil {
+		t.Fatal("expected the failed promotion to be reported")
+	}
+	_ = store.Close()
+
+	reopened, err := NewSQLiteStore(store.dataDir)
+	if err != nil {
+		t.Fatalf("failed to open with the staged key: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if found, _ := reopened.GetFacts(ctx, "staged", nil, "", 10); len(found) != 1 {
+		t.Errorf("expected the fact to stay readable, got %v", found)
+	}
+}
+
+func TestStoreAndLoadKeyFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "clauder-key-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "keys", "clauder.key")
+	t.Setenv(EncryptionKeyEnv, "")
+	t.Setenv(EncryptionKeyFile, path)
+
+	if key, _, err := LoadKey(dir); err != nil || key != nil {
+		t.Fatalf("expected no key, got %v, %v", key, err)
+	}
+
+	key, _ := GenerateKey()
+	if err := StoreKey(dir, key, KeySourceFile); err != nil {
+		t.Fatalf("StoreKey failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected key file with mode 0600, got %v", info)
+	}
+
+	loaded, source, err := LoadKey(dir)
+	if err != nil || source != KeySourceFile || string(loaded) != string(key) {
+		t.Errorf("expected key from file, got %v from %s (%v)", loaded, source, err)
+	}
+
+	if _, err := DecodeKey("c2hvcnQ="); err == nil {
+		t.Error("expected error for a short key")
+	}
+}
@@ -0,0 +1,316 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DuplicateMode controls what RememberFact does when a new fact is similar to
+// one already stored in the same scope
+type DuplicateMode int
+
+const (
+	// DuplicateWarn stores the fact and reports the similar facts
+	DuplicateWarn DuplicateMode = iota
+	// DuplicateReject refuses to store the fact
+	DuplicateReject
+	// DuplicateMerge bumps the most similar fact and adds the new tags to it
+	// instead of storing a new fact
+	DuplicateMerge
+	// DuplicateAllow skips the similarity check
+	DuplicateAllow
+)
+
+// SimilarityThreshold is the minimum term overlap (Dice coefficient) for two
+// facts to count as near-duplicates
+const SimilarityThreshold = 0.6
+
+// maxSimilarityTerms caps the terms used to look up duplicate candidates
+const maxSimilarityTerms = 100
+
+// ParseDuplicateMode parses "warn", "reject", "merge" or "allow"
+func ParseDuplicateMode(s string) (DuplicateMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "warn":
+		return DuplicateWarn, nil
+	case "reject":
+		return DuplicateReject, nil
+	case "merge":
+		return DuplicateMerge, nil
+	case "allow":
+		return DuplicateAllow, nil
+	}
+	return DuplicateWarn, fmt.Errorf("invalid duplicate mode %q (use warn, reject, merge or allow)", s)
+}
+
+// DuplicateError is returned by RememberFact in DuplicateReject mode
+type DuplicateError struct {
+	Similar []Fact
+}
+
+func (e *DuplicateError) Error() string {
+	return "similar to existing fact(s) " + FormatFactIDs(e.Similar)
+}
+
+// FormatFactIDs renders the IDs of facts as "#1, #2"
+func FormatFactIDs(facts []Fact) string {
+	ids := make([]string, len(facts))
+	for i, f := range facts {
+		ids[i] = fmt.Sprintf("#%d", f.ID)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// RememberFact stores f after checking its scope for near-duplicates
+// according to mode. It returns the stored fact, or the existing fact it was
+// merged into, along with the similar facts found, most similar first.
+func (s *SQLiteStore) RememberFact(ctx context.Context, f Fact, mode DuplicateMode) (*Fact, []Fact, error) {
+	if mode == DuplicateAllow {
+		stored, err := s.InsertFact(ctx, f)
+		return stored, nil, err
+	}
+
+	similar, err := s.FindSimilarFacts(ctx, f, SimilarityThreshold)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(similar) > 0 {
+		switch mode {
+		case DuplicateReject:
+			return nil, similar, &DuplicateError{Similar: similar}
+		case DuplicateMerge:
+			merged, err := s.mergeFacts(ctx, similar[0].ID, nil, f.Tags)
+			return merged, similar, err
+		}
+	}
+
+	stored, err := s.InsertFact(ctx, f)
+	return stored, similar, err
+}
+
+// FindSimilarFacts returns facts in the same scope as f (its directory, or
+// the same path in its repository) whose content overlaps f's by at least
+// threshold, most similar first. Each fact's Score is its similarity.
+func (s *SQLiteStore) FindSimilarFacts(ctx context.Context, f Fact, threshold float64) ([]Fact, error) {
+	terms := contentTerms(f.Content)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var match []string
+	for term := range terms {
+		match = append(match, sanitizeFTSQuery(term))
+	}
+	sort.Strings(match)
+	if len(match) > maxSimilarityTerms {
+		match = match[:maxSimilarityTerms]
+	}
+	if err := s.syncSearchIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+factColumns+" FROM facts f JOIN facts_fts ON f.id = facts_fts.rowid"+
+			" WHERE facts_fts MATCH ? AND f.id != ?"+
+			" AND (f.source_dir = ? OR (f.repo_id != '' AND f.repo_id = ? AND f.repo_path = ?))"+
+			" AND (f.expires_at IS NULL OR f.expires_at > ?)"+
+			" ORDER BY bm25(facts_fts) LIMIT 50",
+		strings.Join(match, " OR "), f.ID, f.SourceDir, f.RepoID, f.RepoPath, time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var similar []Fact
+	for rows.Next() {
+		var c Fact
+		if err := s.scanFact(rows, &c); err != nil {
+			return nil, err
+		}
+		if c.Score = similarity(terms, contentTerms(c.Content)); c.Score >= threshold {
+			similar = append(similar, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool { return similar[i].Score > similar[j].Score })
+	return similar, nil
+}
+
+// FindDuplicateGroups clusters the stored facts into groups of near-duplicates
+// within the same scope. Each group is ordered most recently updated first.
+func (s *SQLiteStore) FindDuplicateGroups(ctx context.Context, threshold float64) ([][]Fact, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+factColumns+" FROM facts f WHERE f.expires_at IS NULL OR f.expires_at > ? ORDER BY f.id",
+		time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	scopes := make(map[string][]Fact)
+	var scopeOrder []string
+	for rows.Next() {
+		var f Fact
+		if err := s.scanFact(rows, &f); err != nil {
+			return nil, err
+		}
+		key := "dir:" + f.SourceDir
+		if f.RepoID != "" {
+			key = "repo:" + f.RepoID + ":" + f.RepoPath
+		}
+		if _, ok := scopes[key]; !ok {
+			scopeOrder = append(scopeOrder, key)
+		}
+		scopes[key] = append(scopes[key], f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var groups [][]Fact
+	for _, key := range scopeOrder {
+		groups = append(groups, clusterSimilar(scopes[key], threshold)...)
+	}
+	return groups, nil
+}
+
+// clusterSimilar groups facts that are transitively similar to each other
+func clusterSimilar(facts []Fact, threshold float64) [][]Fact {
+	terms := make([]map[string]bool, len(facts))
+	for i, f := range facts {
+		terms[i] = contentTerms(f.Content)
+	}
+
+	parent := make([]int, len(facts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range facts {
+		for j := i + 1; j < len(facts); j++ {
+			if similarity(terms[i], terms[j]) >= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	members := make(map[int][]Fact)
+	var roots []int
+	for i, f := range facts {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], f)
+	}
+
+	var groups [][]Fact
+	for _, root := range roots {
+		group := members[root]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool { return group[i].UpdatedAt.After(group[j].UpdatedAt) })
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// MergeFacts folds duplicates into the fact keepID: it gains their tags, stays
+// pinned if any of them was, and is marked as updated now. The duplicates are
+// deleted. Returns nil if keepID does not exist.
+func (s *SQLiteStore) MergeFacts(ctx context.Context, keepID int64, duplicateIDs []int64) (*Fact, error) {
+	return s.mergeFacts(ctx, keepID, duplicateIDs, nil)
+}
+
+func (s *SQLiteStore) mergeFacts(ctx context.Context, keepID int64, duplicateIDs []int64, tags []string) (*Fact, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM facts WHERE id = ?)", keepID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	for _, id := range duplicateIDs {
+		if id == keepID {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO fact_tags (fact_id, tag) SELECT ?, tag FROM fact_tags WHERE fact_id = ? ORDER BY id", keepID, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE facts SET pinned = 1 WHERE id = ? AND (SELECT pinned FROM facts WHERE id = ?) = 1", keepID, id); err != nil {
+			return nil, err
+		}
+		if err := moveLinks(ctx, tx, id, keepID); err != nil {
+			return nil, err
+		}
+		for _, table := range []string{"fact_revisions", "fact_tags"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE fact_id = ?", id); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM facts WHERE id = ?", id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := insertTags(ctx, tx, keepID, normalizeTags(tags)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE facts SET updated_at = ? WHERE id = ?", time.Now(), keepID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.PeekFact(ctx, keepID)
+}
+
+// contentTerms returns the set of lowercased words in content
+func contentTerms(content string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		terms[word] = true
+	}
+	return terms
+}
+
+// similarity is the Dice coefficient of two term sets
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for term := range a {
+		if b[term] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		min  float64
+		max  float64
+	}{
+		{"use pnpm, not npm", "Use pnpm, not npm!", 1, 1},
+		{"use pnpm, not npm", "use pnpm instead of npm", 0.6, 0.7},
+		{"use pnpm, not npm", "the API is served over gRPC", 0, 0},
+		{"", "anything", 0, 0},
+	}
+	for _, tt := range tests {
+		got := similarity(contentTerms(tt.a), contentTerms(tt.b))
+		if got < tt.min || got > tt.max {
+			t.Errorf("similarity(%q, %q) = %.2f, want [%.2f, %.2f]", tt.a, tt.b, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRememberFact_Modes(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	existing, _ := store.AddFact(ctx, "use pnpm, not npm", []string{"tooling"}, "/project")
+	_, _ = store.AddFact(ctx, "use pnpm, not npm", nil, "/elsewhere")
+
+	dup := Fact{Content: "Use pnpm instead of npm", Tags: []string{"js"}, SourceDir: "/project"}
+
+	// Reject
+	stored, similar, err := store.RememberFact(ctx, dup, DuplicateReject)
+	var dupErr *DuplicateError
+	if !errors.As(err, &dupErr) || stored != nil {
+		t.Fatalf("expected DuplicateError, got %v", err)
+	}
+	if len(similar) != 1 || similar[0].ID != existing.ID {
+		t.Errorf("expected only the fact in the same scope, got %v", similar)
+	}
+
+	// Warn
+	stored, similar, err = store.RememberFact(ctx, dup, DuplicateWarn)
+	if err != nil || stored == nil {
+		t.Fatalf("RememberFact failed: %v", err)
+	}
+	if stored.ID == existing.ID || len(similar) != 1 {
+		t.Errorf("expected a new fact with one warning, got #%d and %d similar", stored.ID, len(similar))
+	}
+	_ = store.DeleteFact(ctx, stored.ID)
+
+	// Merge
+	stored, _, err = store.RememberFact(ctx, dup, DuplicateMerge)
+	if err != nil {
+		t.Fatalf("RememberFact failed: %v", err)
+	}
+	if stored.ID != existing.ID {
+		t.Fatalf("expected merge into #%d, got #%d", existing.ID, stored.ID)
+	}
+	if len(stored.Tags) != 2 || stored.Tags[0] != "tooling" || stored.Tags[1] != "js" {
+		t.Errorf("expected tags to be unioned, got %v", stored.Tags)
+	}
+	if !stored.UpdatedAt.After(existing.UpdatedAt) {
+		t.Error("expected updated_at to be bumped")
+	}
+	if stored.Content != existing.Content {
+		t.Error("expected merge to keep the existing content")
+	}
+
+	// Allow
+	_, similar, _ = store.RememberFact(ctx, dup, DuplicateAllow)
+	if similar != nil {
+		t.Error("expected no similarity check in allow mode")
+	}
+}
+
+func TestFindDuplicateGroups(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	a, _ := store.AddFact(ctx, "use pnpm, not npm", []string{"a"}, "/project")
+	b, _ := store.AddFact(ctx, "Use pnpm instead of npm", []string{"b"}, "/project")
+	c, _ := store.AddFact(ctx, "always use pnpm not npm", nil, "/project")
+	_, _ = store.AddFact(ctx, "use pnpm, not npm", nil, "/elsewhere")
+	_, _ = store.AddFact(ctx, "the API is served over gRPC", nil, "/project")
+	_, _ = store.SetPinned(ctx, a.ID, true)
+
+	groups, err := store.FindDuplicateGroups(ctx, SimilarityThreshold)
+	if err != nil {
+		t.Fatalf("FindDuplicateGroups failed: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("expected one group of three, got %v", groups)
+	}
+	if groups[0][0].ID != c.ID {
+		t.Errorf("expected most recently updated fact first, got #%d", groups[0][0].ID)
+	}
+
+	merged, err := store.MergeFacts(ctx, c.ID, []int64{a.ID, b.ID})
+	if err != nil {
+		t.Fatalf("MergeFacts failed: %v", err)
+	}
+	if !merged.Pinned || len(merged.Tags) != 2 {
+		t.Errorf("expected merged fact to be pinned with both tags, got %v", merged)
+	}
+	if f, _ := store.GetFactByID(ctx, a.ID); f != nil {
+		t.Error("expected duplicate to be deleted")
+	}
+
+	groups, _ = store.FindDuplicateGroups(ctx, SimilarityThreshold)
+	if len(groups) != 0 {
+		t.Errorf("expected no duplicates after merge, got %d group(s)", len(groups))
+	}
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder turns text into a vector for semantic search
+type Embedder interface {
+	// Name identifies the embedding model. Vectors from differently named
+	// embedders are never compared with each other.
+	Name() string
+	Embed(text string) ([]float32, error)
+}
+
+// EmbedCommandEnv names the environment variable that configures a local
+// command to compute embeddings instead of the built-in HashEmbedder
+const EmbedCommandEnv = "CLAUDER_EMBED_COMMAND"
+
+// DefaultEmbeddingDims is the vector size of the built-in HashEmbedder
+const DefaultEmbeddingDims = 512
+
+// DefaultEmbedder returns a CommandEmbedder if CLAUDER_EMBED_COMMAND is set,
+// and a HashEmbedder otherwise
+func DefaultEmbedder() Embedder {
+	if command := strings.TrimSpace(os.Getenv(EmbedCommandEnv)); command != "" {
+		return NewCommandEmbedder(command)
+	}
+	return NewHashEmbedder(DefaultEmbeddingDims)
+}
+
+// HashEmbedder is an offline embedder using the hashing trick: word stems and
+// character trigrams are hashed into a fixed number of dimensions with
+// sublinear term frequency weights. It matches reworded text and word forms
+// ("deploy", "deployment") but not synonyms; configure a CommandEmbedder for
+// a real model.
+type HashEmbedder struct {
+	Dims int
+}
+
+func NewHashEmbedder(dims int) *HashEmbedder {
+	return &HashEmbedder{Dims: dims}
+}
+
+func (h *HashEmbedder) Name() string {
+	return fmt.Sprintf("hash-v1-%d", h.Dims)
+}
+
+func (h *HashEmbedder) Embed(text string) ([]float32, error) {
+	counts := make(map[string]float64)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if stopWords[word] {
+			continue
+		}
+		stem := stemWord(word)
+		counts["w:"+stem]++
+		padded := []rune("^" + stem + "$")
+		for i := 0; i+3 <= len(padded); i++ {
+			counts["g:"+string(padded[i:i+3])] += 0.25
+		}
+	}
+
+	vec := make([]float32, h.Dims)
+	for feature, tf := range counts {
+		hasher := fnv.New64a()
+		_, _ = hasher.Write([]byte(feature))
+		sum := hasher.Sum64()
+		weight := 1 + math.Log(1+tf)
+		if sum&(1<<63) != 0 {
+			weight = -weight
+		}
+		vec[sum%uint64(h.Dims)] += float32(weight)
+	}
+	return normalize(vec), nil
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "do": true, "does": true, "for": true, "from": true,
+	"how": true, "i": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "our": true, "that": true, "the": true, "this": true,
+	"to": true, "us": true, "we": true, "what": true, "when": true, "where": true,
+	"which": true, "with": true, "you": true,
+}
+
+// stemWord strips common English suffixes so that word forms share features
+func stemWord(word string) string {
+	for _, suffix := range []string{"ments", "ment", "ings", "ing", "ies", "ed", "es", "s"} {
+		if len(word) > len(suffix)+2 && strings.HasSuffix(word, suffix) {
+			if suffix == "ies" {
+				return strings.TrimSuffix(word, suffix) + "y"
+			}
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// CommandEmbedder computes embeddings with a user-configured local command.
+// The text is written to the command's stdin and it must print a JSON array
+// of numbers.
+type CommandEmbedder struct {
+	Command string
+	Timeout time.Duration
+}
+
+func NewCommandEmbedder(command string) *CommandEmbedder {
+	return &CommandEmbedder{Command: command, Timeout: 30 * time.Second}
+}
+
+func (c *CommandEmbedder) Name() string {
+	return "cmd:" + c.Command
+}
+
+func (c *CommandEmbedder) Embed(text string) ([]float32, error) {
+	fields := strings.Fields(c.Command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("embedding command is empty")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("embedding command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var vec []float32
+	if err := json.Unmarshal(out, &vec); err != nil {
+		return nil, fmt.Errorf("embedding command returned invalid output: %w", err)
+	}
+	if len(vec) == 0 {
+		return nil, fmt.Errorf("embedding command returned an empty vector")
+	}
+	return vec, nil
+}
+
+func normalize(vec []float32) []float32 {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vec
+	}
+	norm = math.Sqrt(norm)
+	for i := range vec {
+		vec[i] = float32(float64(vec[i]) / norm)
+	}
+	return vec
+}
+
+// cosine returns the cosine similarity of two vectors, or 0 if their sizes
+// differ
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
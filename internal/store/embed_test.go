@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"math"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// conceptEmbedder maps words onto shared concept dimensions, standing in for a
+// real model that knows synonyms
+type conceptEmbedder struct{}
+
+func (conceptEmbedder) Name() string { return "concepts" }
+
+func (conceptEmbedder) Embed(text string) ([]float32, error) {
+	concepts := map[string]int{"deploy": 0, "release": 0, "argocd": 0, "database": 1, "sqlite": 1, "test": 2}
+	vec := make([]float32, 3)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if dim, ok := concepts[strings.TrimSuffix(word, "s")]; ok {
+			vec[dim]++
+		}
+	}
+	return vec, nil
+}
+
+func TestHashEmbedder(t *testing.T) {
+	e := NewHashEmbedder(DefaultEmbeddingDims)
+
+	a, _ := e.Embed("We deploy through the pipeline")
+	b, _ := e.Embed("deployments go through a pipeline")
+	c, _ := e.Embed("sqlite runs in WAL mode")
+
+	if len(a) != DefaultEmbeddingDims {
+		t.Fatalf("expected %d dims, got %d", DefaultEmbeddingDims, len(a))
+	}
+	var norm float64
+	for _, v := range a {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-4 {
+		t.Errorf("expected unit vector, got norm %f", norm)
+	}
+	if again, _ := e.Embed("We deploy through the pipeline"); cosine(a, again) < 0.9999 {
+		t.Error("expected embeddings to be deterministic")
+	}
+	if cosine(a, b) <= cosine(a, c) {
+		t.Errorf("expected related text to be closer: %.2f vs %.2f", cosine(a, b), cosine(a, c))
+	}
+}
+
+func TestCommandEmbedder(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("echo not available")
+	}
+
+	vec, err := NewCommandEmbedder("echo [0.5,0.25]").Embed("ignored")
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vec) != 2 || vec[0] != 0.5 || vec[1] != 0.25 {
+		t.Errorf("unexpected vector: %v", vec)
+	}
+
+	if _, err := NewCommandEmbedder("echo not json").Embed("x"); err == nil {
+		t.Error("expected error for invalid output")
+	}
+}
+
+func TestParseSearchMode(t *testing.T) {
+	for input, expected := range map[string]SearchMode{"": SearchKeyword, "keyword": SearchKeyword, "Semantic": SearchSemantic, "hybrid": SearchHybrid} {
+		mode, err := ParseSearchMode(input)
+		if err != nil || mode != expected {
+			t.Errorf("ParseSearchMode(%q) = %v, %v", input, mode, err)
+		}
+	}
+	if _, err := ParseSearchMode("fuzzy"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestSearchFacts_Semantic(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// Stored with the default embedder, re-embedded after the switch
+	deploy, _ := store.AddFact(ctx, "releases go out through the ArgoCD pipeline", nil, "/project")
+	_, _ = store.AddFact(ctx, "facts live in SQLite", nil, "/project")
+	store.SetEmbedder(conceptEmbedder{})
+	flaky, _ := store.AddFact(ctx, "the deploy test is flaky", []string{"ci"}, "/project")
+
+	q, _ := ParseQuery("how do we deploy")
+	keyword, _ := store.SearchFacts(ctx, q)
+	if len(keyword) != 0 {
+		t.Fatalf("expected no keyword matches, got %d", len(keyword))
+	}
+
+	q.Mode = SearchSemantic
+	facts, err := store.SearchFacts(ctx, q)
+	if err != nil {
+		t.Fatalf("SearchFacts failed: %v", err)
+	}
+	if len(facts) != 2 || facts[0].ID != deploy.ID || facts[0].Score <= 0 {
+		t.Fatalf("expected the paraphrased fact first, got %v", facts)
+	}
+
+	// Filters still apply
+	q.Tags = []string{"ci"}
+	facts, _ = store.SearchFacts(ctx, q)
+	if len(facts) != 1 || facts[0].ID != flaky.ID {
+		t.Errorf("expected tag filter to apply, got %v", facts)
+	}
+
+	// Hybrid mode favours facts that also match the keywords
+	q, _ = ParseQuery("deploy")
+	q.Mode = SearchHybrid
+	facts, _ = store.SearchFacts(ctx, q)
+	if len(facts) != 2 || facts[0].ID != flaky.ID || facts[0].Snippet == "" {
+		t.Errorf("expected keyword match ranked first with a snippet, got %v", facts)
+	}
+}
+
+func TestSearchFacts_SemanticEncrypted(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	store.SetEmbedder(conceptEmbedder{})
+	deploy, _ := store.AddFact(ctx, "releases go out through the ArgoCD pipeline", nil, "/project")
+	key, _ := GenerateKey()
+	t.Setenv(EncryptionKeyEnv, EncodeKey(key))
+	if err := store.SetEncryptionKey(ctx, key, nil); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+	_, _ = store.AddFact(ctx, "the deploy test is flaky", nil, "/project")
+
+	q, _ := ParseQuery("how do we deploy")
+	q.Mode = SearchSemantic
+	facts, err := store.SearchFacts(ctx, q)
+	if err != nil {
+		t.Fatalf("SearchFacts failed: %v", err)
+	}
+	if len(facts) != 2 || facts[0].ID != deploy.ID {
+		t.Errorf("expected semantic search to work on encrypted vectors, got %v", facts)
+	}
+
+	var plaintext int
+	_ = store.db.QueryRow("SELECT COUNT(*) FROM fact_embeddings WHERE vector NOT LIKE ?", encryptedPrefix+"%").Scan(&plaintext)
+	if plaintext != 0 {
+		t.Errorf("expected every vector to be encrypted, %d are not", plaintext)
+	}
+}
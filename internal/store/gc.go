@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StaleInstanceAge is how long an instance may miss heartbeats before it is
+// considered gone
+const StaleInstanceAge = 5 * time.Minute
+
+// DefaultReadMessageAge is how long GC keeps messages after they are read
+const DefaultReadMessageAge = 30 * 24 * time.Hour
+
+// GCOptions controls what GC deletes
+type GCOptions struct {
+	// ReadMessageAge deletes messages read longer ago than this. Zero keeps
+	// read messages.
+	ReadMessageAge time.Duration
+}
+
+// GCResult reports what GC removed
+type GCResult struct {
+	ExpiredFacts   int64
+	StaleInstances int64
+	ReadMessages   int64
+	// OrphanedMessages were sent to instances that are gone, so they can
+	// never be read
+	OrphanedMessages int64
+	// SizeBefore and SizeAfter are the bytes on disk of the database and
+	// its write-ahead log
+	SizeBefore int64
+	SizeAfter  int64
+}
+
+// Reclaimed is the number of bytes GC freed on disk
+func (r *GCResult) Reclaimed() int64 {
+	return r.SizeBefore - r.SizeAfter
+}
+
+// GC deletes expired facts, stale instances, old read messages and messages
+// to instances that are gone, then optimizes the query planner statistics and
+// full-text index and compacts the database.
+func (s *SQLiteStore) GC(ctx context.Context, opts GCOptions) (*GCResult, error) {
+	result := &GCResult{SizeBefore: s.diskSize()}
+
+	var err error
+	if result.ExpiredFacts, err = s.PurgeExpiredFacts(ctx); err != nil {
+		return nil, fmt.Errorf("failed to purge expired facts: %w", err)
+	}
+	if result.StaleInstances, err = s.execCount(ctx, "DELETE FROM instances WHERE last_heartbeat < ?", time.Now().Add(-StaleInstanceAge)); err != nil {
+		return nil, fmt.Errorf("failed to remove stale instances: %w", err)
+	}
+	if opts.ReadMessageAge > 0 {
+		if result.ReadMessages, err = s.execCount(ctx, "DELETE FROM messages WHERE read_at IS NOT NULL AND read_at < ?", time.Now().Add(-opts.ReadMessageAge)); err != nil {
+			return nil, fmt.Errorf("failed to delete read messages: %w", err)
+		}
+	}
+	if result.OrphanedMessages, err = s.execCount(ctx, "DELETE FROM messages WHERE to_instance NOT IN (SELECT id FROM instances)"); err != nil {
+		return nil, fmt.Errorf("failed to delete orphaned messages: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, "PRAGMA optimize"); err != nil {
+		return nil, fmt.Errorf("failed to optimize database: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	// Encrypted databases keep their full-text index in memory
+	if s.cipher == nil {
+		if _, err := s.db.ExecContext(ctx, "INSERT INTO facts_fts(facts_fts) VALUES ('optimize')"); err != nil {
+			return nil, fmt.Errorf("failed to optimize search index: %w", err)
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return nil, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	// Vacuuming goes through the log, so empty it again
+	if _, err := s.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+
+	result.SizeAfter = s.diskSize()
+	return result, nil
+}
+
+func (s *SQLiteStore) execCount(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// diskSize returns the bytes on disk of the database and its write-ahead log
+func (s *SQLiteStore) diskSize() int64 {
+	var size int64
+	for _, name := range []string{"clauder.db", "clauder.db-wal"} {
+		if info, err := os.Stat(filepath.Join(s.dataDir, name)); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_ = store.RegisterInstance(ctx, "live", 1, "/project")
+	_ = store.RegisterInstance(ctx, "stale", 2, "/project")
+	_, _ = store.db.Exec("UPDATE instances SET last_heartbeat = ? WHERE id = 'stale'", time.Now().Add(-time.Hour))
+
+	oldRead, _ := store.SendMessage(ctx, "a", "live", "old and read")
+	newRead, _ := store.SendMessage(ctx, "a", "live", "new and read")
+	unread, _ := store.SendMessage(ctx, "a", "live", "old but unread")
+	_, _ = store.SendMessage(ctx, "a", "stale", "never delivered")
+	_, _ = store.SendMessage(ctx, "a", "gone", "never delivered")
+	_ = store.MarkMessageRead(ctx, oldRead.ID)
+	_ = store.MarkMessageRead(ctx, newRead.ID)
+	_, _ = store.db.Exec("UPDATE messages SET read_at = ? WHERE id = ?", time.Now().Add(-48*time.Hour), oldRead.ID)
+	_, _ = store.db.Exec("UPDATE messages SET created_at = ? WHERE id = ?", time.Now().Add(-48*time.Hour), unread.ID)
+
+	// Give VACUUM something to reclaim
+	fact, _ := store.AddFact(ctx, "filler "+strings.Repeat("x", 200000), nil, "/project")
+	_ = store.DeleteFact(ctx, fact.ID)
+
+	result, err := store.GC(ctx, GCOptions{ReadMessageAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if result.StaleInstances != 1 || result.ReadMessages != 1 || result.OrphanedMessages != 2 {
+		t.Errorf("expected 1 stale instance, 1 read and 2 orphaned messages, got %+v", result)
+	}
+	if result.SizeBefore == 0 || result.Reclaimed() <= 0 {
+		t.Errorf("expected GC to reclaim space, got %d -> %d", result.SizeBefore, result.SizeAfter)
+	}
+
+	messages, _ := store.GetMessages(ctx, "live", false)
+	if len(messages) != 2 {
+		t.Errorf("expected the new read and the unread message to remain, got %v", messages)
+	}
+	if facts, _ := store.GetFacts(ctx, "filler", nil, "", 10); len(facts) != 0 {
+		t.Error("expected the deleted fact to stay out of search after GC")
+	}
+}
+
+func TestGC_KeepsReadMessages(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_ = store.RegisterInstance(ctx, "live", 1, "/project")
+	msg, _ := store.SendMessage(ctx, "a", "live", "read long ago")
+	_ = store.MarkMessageRead(ctx, msg.ID)
+	_, _ = store.db.Exec("UPDATE messages SET read_at = ? WHERE id = ?", time.Now().Add(-365*24*time.Hour), msg.ID)
+
+	result, err := store.GC(ctx, GCOptions{})
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if result.ReadMessages != 0 {
+		t.Errorf("expected read messages to be kept without an age, got %d deleted", result.ReadMessages)
+	}
+}
+
+func TestGC_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(ctx, "the queue is rabbitmq", nil, "/project")
+	key, _ := GenerateKey()
+	t.Setenv(EncryptionKeyEnv, EncodeKey(key))
+	if err := store.SetEncryptionKey(ctx, key, nil); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+
+	if _, err := store.GC(ctx, GCOptions{}); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if facts, _ := store.GetFacts(ctx, "rabbitmq", nil, "", 10); len(facts) != 1 {
+		t.Error("expected facts to stay searchable after GC")
+	}
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Environment variables that supply the content encryption key
+const (
+	EncryptionKeyEnv  = "CLAUDER_ENCRYPTION_KEY"
+	EncryptionKeyFile = "CLAUDER_KEY_FILE"
+)
+
+// keyringService is the service name of keys stored in the OS keyring
+const keyringService = "clauder"
+
+// KeySource is where an encryption key is kept
+type KeySource int
+
+const (
+	KeySourceFile KeySource = iota
+	KeySourceKeyring
+	KeySourceEnv
+)
+
+func (k KeySource) String() string {
+	switch k {
+	case KeySourceKeyring:
+		return "keyring"
+	case KeySourceEnv:
+		return "env"
+	}
+	return "file"
+}
+
+// ParseKeySource parses "file", "keyring" or "env"
+func ParseKeySource(s string) (KeySource, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "file":
+		return KeySourceFile, nil
+	case "keyring":
+		return KeySourceKeyring, nil
+	case "env":
+		return KeySourceEnv, nil
+	}
+	return KeySourceFile, fmt.Errorf("invalid key source %q (use file, keyring or env)", s)
+}
+
+// KeyFilePath returns the key file for dataDir: $CLAUDER_KEY_FILE if set,
+// otherwise clauder.key in dataDir
+func KeyFilePath(dataDir string) string {
+	if path := os.Getenv(EncryptionKeyFile); path != "" {
+		return path
+	}
+	return filepath.Join(dataDir, "clauder.key")
+}
+
+// LoadKey returns the encryption key for dataDir from $CLAUDER_ENCRYPTION_KEY,
+// the key file or the OS keyring, in that order. It returns a nil key if
+// none of them has one.
+func LoadKey(dataDir string) ([]byte, KeySource, error) {
+	if encoded := os.Getenv(EncryptionKeyEnv); encoded != "" {
+		key, err := DecodeKey(encoded)
+		return key, KeySourceEnv, err
+	}
+
+	data, err := os.ReadFile(KeyFilePath(dataDir))
+	if err == nil {
+		key, err := DecodeKey(string(data))
+		return key, KeySourceFile, err
+	}
+	if !os.IsNotExist(err) {
+		return nil, KeySourceFile, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	// A missing keyring tool or entry just means there is no key there
+	if encoded, err := keyringGet(dataDir); err == nil && encoded != "" {
+		key, err := DecodeKey(encoded)
+		return key, KeySourceKeyring, err
+	}
+	return nil, KeySourceFile, nil
+}
+
+// StoreKey saves key for dataDir in the key file or the OS keyring. Keys
+// supplied through the environment cannot be stored.
+func StoreKey(dataDir string, key []byte, source KeySource) error {
+	switch source {
+	case KeySourceKeyring:
+		return keyringSet(dataDir, EncodeKey(key))
+	case KeySourceEnv:
+		return fmt.Errorf("keys in %s must be set by the user", EncryptionKeyEnv)
+	}
+	return writeKeyFile(KeyFilePath(dataDir), key)
+}
+
+func writeKeyFile(path string, key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(EncodeKey(key)+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// A new key is first saved to a staging slot next to the current key, the
+// key file with a .new suffix or a second keyring entry, and only replaces
+// the current key once the database is encrypted with it. Until then the
+// database keeps opening with the current key, and if clauder stops in
+// between, the database opens with whichever of the two keys matches it.
+
+// stagedKeyringAccount is the keyring account of the staged key of dataDir
+func stagedKeyringAccount(dataDir string) string {
+	return dataDir + " (staged)"
+}
+
+func stagedKeyFilePath(dataDir string) string {
+	return KeyFilePath(dataDir) + ".new"
+}
+
+// NewKeySaver returns a KeySaver that saves key for dataDir in the key file
+// or the OS keyring. Keys supplied through the environment cannot be stored.
+func NewKeySaver(dataDir string, key []byte, source KeySource) KeySaver {
+	return &stagedKey{dataDir: dataDir, key: key, source: source}
+}
+
+type stagedKey struct {
+	dataDir string
+	key     []byte
+	source  KeySource
+}
+
+func (k *stagedKey) Stage() error {
+	switch k.source {
+	case KeySourceKeyring:
+		return keyringSet(stagedKeyringAccount(k.dataDir), EncodeKey(k.key))
+	case KeySourceEnv:
+		return fmt.Errorf("keys in %s must be set by the user", EncryptionKeyEnv)
+	}
+	return writeKeyFile(stagedKeyFilePath(k.dataDir), k.key)
+}
+
+func (k *stagedKey) Promote() error {
+	if k.source == KeySourceKeyring {
+		if err := keyringSet(k.dataDir, EncodeKey(k.key)); err != nil {
+			return err
+		}
+		// A leftover staged entry is harmless
+		_ = keyringDelete(stagedKeyringAccount(k.dataDir))
+		return nil
+	}
+	return os.Rename(stagedKeyFilePath(k.dataDir), KeyFilePath(k.dataDir))
+}
+
+func (k *stagedKey) Discard() error {
+	if k.source == KeySourceKeyring {
+		return keyringDelete(stagedKeyringAccount(k.dataDir))
+	}
+	if err := os.Remove(stagedKeyFilePath(k.dataDir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// loadStagedKeys returns the keys staged for dataDir that were never
+// promoted, from the key file slot and the keyring
+func loadStagedKeys(dataDir string) [][]byte {
+	var keys [][]byte
+	if data, err := os.ReadFile(stagedKeyFilePath(dataDir)); err == nil {
+		if key, err := DecodeKey(string(data)); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	if encoded, err := keyringGet(stagedKeyringAccount(dataDir)); err == nil && encoded != "" {
+		if key, err := DecodeKey(encoded); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// The keyring is reached through the platform's command line tools: security
+// on macOS and secret-tool (libsecret) elsewhere. Keys are stored per data
+// directory, under an account named after it.
+
+func keyringGet(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	case "windows":
+		return "", fmt.Errorf("the OS keyring is not supported on windows")
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keyringSet(account, encoded string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// With -w last and no value, security prompts for the key and its
+		// confirmation, so the key never appears in the process list
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keyringService, "-a", account, "-w")
+		cmd.Stdin = strings.NewReader(encoded + "\n" + encoded + "\n")
+	case "windows":
+		return fmt.Errorf("the OS keyring is not supported on windows")
+	default:
+		cmd = exec.Command("secret-tool", "store", "--label", "clauder encryption key", "service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(encoded)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to store key in keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func keyringDelete(account string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", account)
+	case "windows":
+		return fmt.Errorf("the OS keyring is not supported on windows")
+	default:
+		cmd = exec.Command("secret-tool", "clear", "service", keyringService, "account", account)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to remove key from keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
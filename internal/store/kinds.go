@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FactKind classifies a fact. Kinds other than KindNote may carry
+// structured FactDetails.
+type FactKind string
+
+const (
+	KindNote       FactKind = "note"
+	KindDecision   FactKind = "decision"
+	KindConvention FactKind = "convention"
+	KindGotcha     FactKind = "gotcha"
+	KindTodo       FactKind = "todo"
+	KindCommand    FactKind = "command"
+)
+
+// FactKinds lists every fact kind
+var FactKinds = []FactKind{KindNote, KindDecision, KindConvention, KindGotcha, KindTodo, KindCommand}
+
+// ParseFactKind parses a kind name. An empty string is KindNote.
+func ParseFactKind(s string) (FactKind, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return KindNote, nil
+	}
+	for _, k := range FactKinds {
+		if FactKind(s) == k {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("invalid kind %q (use note, decision, convention, gotcha, todo or command)", s)
+}
+
+// FactDetails holds the optional structured fields of a fact. Each field
+// belongs to one kind; see Validate.
+type FactDetails struct {
+	// Decisions
+	Rationale    string   `json:"rationale,omitempty"`
+	Alternatives []string `json:"alternatives,omitempty"`
+	// Conventions
+	Example string `json:"example,omitempty"`
+	// Gotchas
+	Workaround string `json:"workaround,omitempty"`
+	// Todos
+	Done bool `json:"done,omitempty"`
+	// Commands
+	Command string `json:"command,omitempty"`
+	Cwd     string `json:"cwd,omitempty"`
+}
+
+// TextFields returns pointers to the text fields of d, e.g. for secret
+// scanning
+func (d *FactDetails) TextFields() []*string {
+	if d == nil {
+		return nil
+	}
+	fields := []*string{&d.Rationale, &d.Example, &d.Workaround, &d.Command, &d.Cwd}
+	for i := range d.Alternatives {
+		fields = append(fields, &d.Alternatives[i])
+	}
+	return fields
+}
+
+// IsZero reports whether no field is set
+func (d *FactDetails) IsZero() bool {
+	return d == nil || (d.Rationale == "" && len(d.Alternatives) == 0 && d.Example == "" &&
+		d.Workaround == "" && !d.Done && d.Command == "" && d.Cwd == "")
+}
+
+// Merge returns a copy of d with the fields set in other replacing its own
+func (d *FactDetails) Merge(other *FactDetails) *FactDetails {
+	var merged FactDetails
+	if d != nil {
+		merged = *d
+	}
+	if other == nil {
+		if d == nil {
+			return nil
+		}
+		return &merged
+	}
+	if other.Rationale != "" {
+		merged.Rationale = other.Rationale
+	}
+	if len(other.Alternatives) > 0 {
+		merged.Alternatives = other.Alternatives
+	}
+	if other.Example != "" {
+		merged.Example = other.Example
+	}
+	if other.Workaround != "" {
+		merged.Workaround = other.Workaround
+	}
+	if other.Done {
+		merged.Done = true
+	}
+	if other.Command != "" {
+		merged.Command = other.Command
+	}
+	if other.Cwd != "" {
+		merged.Cwd = other.Cwd
+	}
+	return &merged
+}
+
+// Validate checks that every field set belongs to kind, and that command
+// facts have a command line
+func (d *FactDetails) Validate(kind FactKind) error {
+	if kind == KindCommand && (d == nil || d.Command == "") {
+		return fmt.Errorf("a command fact needs a command line")
+	}
+	if d == nil {
+		return nil
+	}
+	fields := []struct {
+		name string
+		set  bool
+		kind FactKind
+	}{
+		{"rationale", d.Rationale != "", KindDecision},
+		{"alternatives", len(d.Alternatives) > 0, KindDecision},
+		{"example", d.Example != "", KindConvention},
+		{"workaround", d.Workaround != "", KindGotcha},
+		{"done", d.Done, KindTodo},
+		{"command", d.Command != "", KindCommand},
+		{"cwd", d.Cwd != "", KindCommand},
+	}
+	for _, field := range fields {
+		if field.set && field.kind != kind {
+			return fmt.Errorf("%s only applies to %s facts", field.name, field.kind)
+		}
+	}
+	return nil
+}
+
+// Lines renders the fields that are set as "Label: value" lines
+func (d *FactDetails) Lines() []string {
+	if d == nil {
+		return nil
+	}
+	var lines []string
+	if d.Rationale != "" {
+		lines = append(lines, "Rationale: "+d.Rationale)
+	}
+	if len(d.Alternatives) > 0 {
+		lines = append(lines, "Alternatives considered: "+strings.Join(d.Alternatives, ", "))
+	}
+	if d.Example != "" {
+		lines = append(lines, "Example: "+d.Example)
+	}
+	if d.Workaround != "" {
+		lines = append(lines, "Workaround: "+d.Workaround)
+	}
+	if d.Command != "" {
+		lines = append(lines, "Command: "+d.Command)
+	}
+	if d.Cwd != "" {
+		lines = append(lines, "Run in: "+d.Cwd)
+	}
+	return lines
+}
+
+// sealDetails encodes details for the details column, encrypting them like
+// fact content. Empty details are stored as NULL.
+func (s *SQLiteStore) sealDetails(d *FactDetails) (interface{}, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return s.cipher.seal(string(data))
+}
+
+func (s *SQLiteStore) openDetails(stored string) (*FactDetails, error) {
+	if stored == "" {
+		return nil, nil
+	}
+	plaintext, err := s.cipher.open(stored)
+	if err != nil {
+		return nil, err
+	}
+	var d FactDetails
+	if err := json.Unmarshal([]byte(plaintext), &d); err != nil {
+		return nil, fmt.Errorf("invalid fact details: %w", err)
+	}
+	return &d, nil
+}
+
+// SetFactKind changes the kind and details of a fact, archiving the previous
+// version. Returns nil if the fact does not exist.
+func (s *SQLiteStore) SetFactKind(ctx context.Context, id int64, kind FactKind, details *FactDetails) (*Fact, error) {
+	return s.EditFact(ctx, id, FactEdit{SetKind: true, Kind: kind, Details: details})
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInsertFact_Kinds(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	decision, err := store.InsertFact(ctx, Fact{
+		Content:   "use NATS for the job queue",
+		Kind:      KindDecision,
+		Details:   &FactDetails{Rationale: "persistent streams", Alternatives: []string{"Redis", "SQS"}},
+		SourceDir: "/project",
+	})
+	if err != nil {
+		t.Fatalf("InsertFact failed: %v", err)
+	}
+	_, _ = store.InsertFact(ctx, Fact{
+		Content:   "run the unit tests",
+		Kind:      KindCommand,
+		Details:   &FactDetails{Command: "go test -tags fts5 ./...", Cwd: "/project"},
+		SourceDir: "/project",
+	})
+	_, _ = store.AddFact(ctx, "the queue is fast", nil, "/project")
+
+	got, _ := store.GetFactByID(ctx, decision.ID)
+	if got.Kind != KindDecision || got.Details == nil || got.Details.Rationale != "persistent streams" || len(got.Details.Alternatives) != 2 {
+		t.Errorf("expected decision details to round trip, got %+v", got)
+	}
+
+	q, _ := ParseQuery("kind:command")
+	commands, _ := store.SearchFacts(ctx, q)
+	if len(commands) != 1 || commands[0].Details.Command != "go test -tags fts5 ./..." {
+		t.Errorf("expected one command fact, got %v", commands)
+	}
+	notes, _ := store.SearchFacts(ctx, FactQuery{Kinds: []FactKind{KindNote}})
+	if len(notes) != 1 || notes[0].Kind != KindNote || notes[0].Details != nil {
+		t.Errorf("expected one plain note, got %v", notes)
+	}
+}
+
+func TestInsertFact_InvalidDetails(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	if _, err := store.InsertFact(ctx, Fact{Content: "run tests", Kind: KindCommand, SourceDir: "/project"}); err == nil {
+		t.Error("expected error for a command fact without a command line")
+	}
+	_, err := store.InsertFact(ctx, Fact{Content: "use tabs", Kind: KindConvention, Details: &FactDetails{Rationale: "why"}, SourceDir: "/project"})
+	if err == nil || !strings.Contains(err.Error(), "rationale only applies to decision facts") {
+		t.Errorf("expected error for a field of another kind, got %v", err)
+	}
+	if _, err := ParseFactKind("idea"); err == nil {
+		t.Error("expected error for an unknown kind")
+	}
+}
+
+func TestSetFactKind(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	todo, _ := store.InsertFact(ctx, Fact{Content: "add retries to the uploader", Kind: KindTodo, SourceDir: "/project"})
+	done, err := store.SetFactKind(ctx, todo.ID, KindTodo, &FactDetails{Done: true})
+	if err != nil || done == nil || !done.Details.Done {
+		t.Fatalf("expected todo marked done, got %+v (%v)", done, err)
+	}
+	if missing, err := store.SetFactKind(ctx, 999, KindNote, nil); err != nil || missing != nil {
+		t.Errorf("expected nil for a missing fact, got %v (%v)", missing, err)
+	}
+}
+
+func TestEditFact(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	fact, _ := store.InsertFact(ctx, Fact{Content: "use NATS", Kind: KindDecision, Details: &FactDetails{Rationale: "streams"}, SourceDir: "/project"})
+	edited, err := store.EditFact(ctx, fact.ID, FactEdit{
+		Content: "use NATS for jobs",
+		Tags:    []string{"queue"},
+		SetKind: true,
+		Kind:    KindConvention,
+		Details: &FactDetails{Example: "jobs.publish()"},
+	})
+	if err != nil || edited == nil {
+		t.Fatalf("EditFact failed: %v", err)
+	}
+	if edited.Content != "use NATS for jobs" || edited.Kind != KindConvention || len(edited.Tags) != 1 {
+		t.Errorf("expected every field updated, got %+v", edited)
+	}
+
+	revisions, _ := store.GetFactRevisions(ctx, fact.ID)
+	if len(revisions) != 1 {
+		t.Fatalf("expected one revision for one edit, got %d", len(revisions))
+	}
+	if r := revisions[0]; r.Content != "use NATS" || r.Kind != KindDecision || r.Details == nil || r.Details.Rationale != "streams" {
+		t.Errorf("expected the previous kind and details archived, got %+v", r)
+	}
+
+	// An invalid kind change leaves the fact untouched
+	if _, err := store.EditFact(ctx, fact.ID, FactEdit{Content: "changed", SetKind: true, Kind: KindCommand}); err == nil {
+		t.Error("expected an error for a command without a command line")
+	}
+	got, _ := store.PeekFact(ctx, fact.ID)
+	if got.Content != "use NATS for jobs" {
+		t.Errorf("expected no partial update, got %q", got.Content)
+	}
+}
+
+func TestSetEncryptionKey_Details(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	fact, _ := store.InsertFact(ctx, Fact{
+		Content:   "the staging deploy",
+		Kind:      KindCommand,
+		Details:   &FactDetails{Command: "deploy --token s3cr3t"},
+		SourceDir: "/project",
+	})
+
+	key, _ := GenerateKey()
+	t.Setenv(EncryptionKeyEnv, EncodeKey(key))
+	if err := store.SetEncryptionKey(ctx, key, nil); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+
+	var stored string
+	_ = store.db.QueryRow("SELECT details FROM facts WHERE id = ?", fact.ID).Scan(&stored)
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		t.Errorf("expected details to be encrypted, got %q", stored)
+	}
+	got, _ := store.GetFactByID(ctx, fact.ID)
+	if got.Details == nil || got.Details.Command != "deploy --token s3cr3t" {
+		t.Errorf("expected decrypted details, got %+v", got.Details)
+	}
+}
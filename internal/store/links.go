@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkKind is the type of a relationship from one fact to another
+type LinkKind string
+
+const (
+	// LinkSupersedes means the source fact replaces the target, which is
+	// then hidden from searches unless FactQuery.IncludeSuperseded is set
+	LinkSupersedes LinkKind = "supersedes"
+	LinkRelatesTo  LinkKind = "relates-to"
+	LinkDependsOn  LinkKind = "depends-on"
+)
+
+// LinkKinds lists every link kind
+var LinkKinds = []LinkKind{LinkSupersedes, LinkRelatesTo, LinkDependsOn}
+
+// ParseLinkKind parses "supersedes", "relates-to" or "depends-on"
+func ParseLinkKind(s string) (LinkKind, error) {
+	kind := LinkKind(strings.ToLower(strings.TrimSpace(s)))
+	for _, k := range LinkKinds {
+		if kind == k {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("invalid link kind %q (use supersedes, relates-to or depends-on)", s)
+}
+
+// FactLink is a typed relationship: FromID <Kind> ToID
+type FactLink struct {
+	FromID    int64     `json:"from_id"`
+	ToID      int64     `json:"to_id"`
+	Kind      LinkKind  `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// factSupersededByColumn selects the comma separated IDs of the facts that
+// supersede f
+const factSupersededByColumn = "(SELECT group_concat(l.from_id) FROM (SELECT from_id FROM fact_links WHERE to_id = f.id AND kind = 'supersedes' ORDER BY from_id) l)"
+
+// FormatIDs renders fact IDs as "#1, #2"
+func FormatIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("#%d", id)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func parseIDList(s string) []int64 {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// LinkFacts records that fromID <kind> toID. Linking facts that are already
+// linked this way returns the existing link.
+func (s *SQLiteStore) LinkFacts(ctx context.Context, fromID, toID int64, kind LinkKind) (*FactLink, error) {
+	if fromID == toID {
+		return nil, fmt.Errorf("a fact cannot be linked to itself")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, id := range []int64{fromID, toID} {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM facts WHERE id = ?)", id).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("fact #%d not found", id)
+		}
+	}
+
+	if kind == LinkSupersedes {
+		// Reject links that close a supersedes cycle, which would hide every
+		// fact on it
+		var cycle bool
+		if err := tx.QueryRowContext(ctx, `
+			WITH RECURSIVE chain(id) AS (
+				SELECT ?
+				UNION
+				SELECT l.to_id FROM fact_links l JOIN chain c ON l.from_id = c.id WHERE l.kind = ?
+			)
+			SELECT EXISTS (SELECT 1 FROM chain WHERE id = ?)`,
+			toID, string(LinkSupersedes), fromID,
+		).Scan(&cycle); err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, fmt.Errorf("fact #%d already supersedes #%d", toID, fromID)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO fact_links (from_id, to_id, kind, created_at) VALUES (?, ?, ?, ?)",
+		fromID, toID, string(kind), time.Now(),
+	); err != nil {
+		return nil, err
+	}
+
+	link := FactLink{FromID: fromID, ToID: toID, Kind: kind}
+	if err := tx.QueryRowContext(ctx,
+		"SELECT created_at FROM fact_links WHERE from_id = ? AND to_id = ? AND kind = ?",
+		fromID, toID, string(kind),
+	).Scan(&link.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &link, tx.Commit()
+}
+
+// UnlinkFacts removes a link and reports whether it existed
+func (s *SQLiteStore) UnlinkFacts(ctx context.Context, fromID, toID int64, kind LinkKind) (bool, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM fact_links WHERE from_id = ? AND to_id = ? AND kind = ?", fromID, toID, string(kind))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetFactLinks returns the links from and to a fact, oldest first
+func (s *SQLiteStore) GetFactLinks(ctx context.Context, id int64) ([]FactLink, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT from_id, to_id, kind, created_at FROM fact_links WHERE from_id = ? OR to_id = ? ORDER BY created_at, id",
+		id, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var links []FactLink
+	for rows.Next() {
+		var l FactLink
+		var kind string
+		if err := rows.Scan(&l.FromID, &l.ToID, &kind, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		l.Kind = LinkKind(kind)
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// moveLinks repoints the links of fact oldID to newID, dropping any that
+// would then link newID to itself
+func moveLinks(ctx context.Context, tx *sql.Tx, oldID, newID int64) error {
+	for _, column := range []string{"from_id", "to_id"} {
+		if _, err := tx.ExecContext(ctx, "UPDATE OR IGNORE fact_links SET "+column+" = ? WHERE "+column+" = ?", newID, oldID); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, "DELETE FROM fact_links WHERE from_id = to_id")
+	return err
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLinkFacts_Supersedes(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	redis, _ := store.AddFact(ctx, "we chose Redis for the queue", []string{"decision"}, "/project")
+	nats, _ := store.AddFact(ctx, "we moved the queue to NATS", []string{"decision"}, "/project")
+
+	if _, err := store.LinkFacts(ctx, nats.ID, redis.ID, LinkSupersedes); err != nil {
+		t.Fatalf("LinkFacts failed: %v", err)
+	}
+	// Linking again is a no-op
+	if _, err := store.LinkFacts(ctx, nats.ID, redis.ID, LinkSupersedes); err != nil {
+		t.Fatalf("repeated LinkFacts failed: %v", err)
+	}
+
+	facts, _ := store.GetFacts(ctx, "queue", nil, "", 10)
+	if len(facts) != 1 || facts[0].ID != nats.ID {
+		t.Errorf("expected only the current fact, got %v", facts)
+	}
+
+	all, _ := store.SearchFacts(ctx, FactQuery{Query: "queue", IncludeSuperseded: true})
+	if len(all) != 2 {
+		t.Fatalf("expected superseded fact with IncludeSuperseded, got %d", len(all))
+	}
+	old, _ := store.GetFactByID(ctx, redis.ID)
+	if len(old.SupersededBy) != 1 || old.SupersededBy[0] != nats.ID {
+		t.Errorf("expected SupersededBy [%d], got %v", nats.ID, old.SupersededBy)
+	}
+
+	if _, err := store.LinkFacts(ctx, redis.ID, nats.ID, LinkSupersedes); err == nil {
+		t.Error("expected error for a supersedes cycle")
+	}
+
+	removed, err := store.UnlinkFacts(ctx, nats.ID, redis.ID, LinkSupersedes)
+	if err != nil || !removed {
+		t.Fatalf("UnlinkFacts failed: %v", err)
+	}
+	if facts, _ := store.GetFacts(ctx, "queue", nil, "", 10); len(facts) != 2 {
+		t.Errorf("expected both facts after unlinking, got %d", len(facts))
+	}
+}
+
+func TestLinkFacts_Invalid(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	f, _ := store.AddFact(ctx, "a fact", nil, "/project")
+	if _, err := store.LinkFacts(ctx, f.ID, f.ID, LinkRelatesTo); err == nil {
+		t.Error("expected error linking a fact to itself")
+	}
+	if _, err := store.LinkFacts(ctx, f.ID, 999, LinkRelatesTo); err == nil {
+		t.Error("expected error linking to a missing fact")
+	}
+	if _, err := ParseLinkKind("blocks"); err == nil {
+		t.Error("expected error for an unknown link kind")
+	}
+}
+
+func TestLinkFacts_SupersedesCycle(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	a, _ := store.AddFact(ctx, "fact a", nil, "/project")
+	b, _ := store.AddFact(ctx, "fact b", nil, "/project")
+	c, _ := store.AddFact(ctx, "fact c", nil, "/project")
+	if _, err := store.LinkFacts(ctx, a.ID, b.ID, LinkSupersedes); err != nil {
+		t.Fatalf("LinkFacts failed: %v", err)
+	}
+	if _, err := store.LinkFacts(ctx, b.ID, c.ID, LinkSupersedes); err != nil {
+		t.Fatalf("LinkFacts failed: %v", err)
+	}
+	if _, err := store.LinkFacts(ctx, c.ID, a.ID, LinkSupersedes); err == nil {
+		t.Error("expected error closing a supersedes cycle")
+	}
+	if _, err := store.LinkFacts(ctx, c.ID, a.ID, LinkRelatesTo); err != nil {
+		t.Errorf("expected other link kinds to be allowed, got %v", err)
+	}
+	if facts, _ := store.GetFacts(ctx, "fact", nil, "", 10); len(facts) != 1 || facts[0].ID != a.ID {
+		t.Errorf("expected only the head of the chain to be visible, got %v", facts)
+	}
+}
+
+func TestGetFactLinks_FollowDeleteAndMerge(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	api, _ := store.AddFact(ctx, "the API uses the auth service", nil, "/project")
+	auth, _ := store.AddFact(ctx, "auth service issues JWTs", nil, "/project")
+	authDup, _ := store.AddFact(ctx, "the auth service issues JWTs", nil, "/project")
+	docs, _ := store.AddFact(ctx, "docs live in the wiki", nil, "/project")
+
+	_, _ = store.LinkFacts(ctx, api.ID, authDup.ID, LinkDependsOn)
+	_, _ = store.LinkFacts(ctx, docs.ID, api.ID, LinkRelatesTo)
+
+	links, err := store.GetFactLinks(ctx, api.ID)
+	if err != nil || len(links) != 2 {
+		t.Fatalf("expected 2 links, got %v (%v)", links, err)
+	}
+
+	if _, err := store.MergeFacts(ctx, auth.ID, []int64{authDup.ID}); err != nil {
+		t.Fatalf("MergeFacts failed: %v", err)
+	}
+	links, _ = store.GetFactLinks(ctx, auth.ID)
+	if len(links) != 1 || links[0].FromID != api.ID || links[0].Kind != LinkDependsOn {
+		t.Errorf("expected link moved to the kept fact, got %v", links)
+	}
+
+	_ = store.DeleteFact(ctx, docs.ID)
+	if links, _ := store.GetFactLinks(ctx, api.ID); len(links) != 1 {
+		t.Errorf("expected links of a deleted fact to be removed, got %v", links)
+	}
+}
+
+func TestImportFacts_KeepsSupersedes(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	stats, err := store.ImportFacts(ctx, []Fact{
+		{ID: 10, Content: "old decision", SourceDir: "/project", SupersededBy: []int64{11}},
+		{ID: 11, Content: "new decision", SourceDir: "/project"},
+	}, ImportRemap)
+	if err != nil {
+		t.Fatalf("ImportFacts failed: %v", err)
+	}
+
+	old, _ := store.GetFactByID(ctx, stats.IDs[10])
+	if len(old.SupersededBy) != 1 || old.SupersededBy[0] != stats.IDs[11] {
+		t.Errorf("expected imported fact superseded by #%d, got %v", stats.IDs[11], old.SupersededBy)
+	}
+}
+
+func TestImportFacts_KeepsAllLinkKinds(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	api, _ := store.AddFact(ctx, "the api calls the auth service", nil, "/project")
+	auth, _ := store.AddFact(ctx, "the auth service issues JWTs", nil, "/project")
+	docs, _ := store.AddFact(ctx, "docs live in the wiki", nil, "/project")
+	newer, _ := store.AddFact(ctx, "docs moved to the repo", nil, "/project")
+	_, _ = store.LinkFacts(ctx, api.ID, auth.ID, LinkDependsOn)
+	_, _ = store.LinkFacts(ctx, docs.ID, api.ID, LinkRelatesTo)
+	_, _ = store.LinkFacts(ctx, newer.ID, docs.ID, LinkSupersedes)
+
+	exported := exportAll(t, store, FactQuery{})
+	stats, err := store.ImportFacts(ctx, exported, ImportRemap)
+	if err != nil {
+		t.Fatalf("ImportFacts failed: %v", err)
+	}
+
+	for _, f := range []*Fact{api, docs, newer} {
+		want, _ := store.GetFactLinks(ctx, f.ID)
+		got, _ := store.GetFactLinks(ctx, stats.IDs[f.ID])
+		if len(got) != len(want) {
+			t.Fatalf("expected %d links on the copy of #%d, got %v", len(want), f.ID, got)
+		}
+		for i := range want {
+			if got[i].Kind != want[i].Kind || got[i].FromID != stats.IDs[want[i].FromID] || got[i].ToID != stats.IDs[want[i].ToID] {
+				t.Errorf("expected %v to be restored through the ID map, got %v", want[i], got[i])
+			}
+		}
+	}
+}
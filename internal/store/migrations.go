@@ -0,0 +1,452 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when opening a database written by a newer
+// version of clauder
+var ErrSchemaTooNew = errors.New("database was written by a newer version of clauder")
+
+// Migration is a single schema change, applied in a transaction. The schema
+// version is stored in PRAGMA user_version.
+type Migration struct {
+	Version     int
+	Description string
+	up          func(tx *sql.Tx) error
+}
+
+// Databases created before versioned migrations have user_version 0 but may
+// already contain any of the first seven changes, so those steps are
+// idempotent. Later steps can assume the previous version exactly.
+var migrations = []Migration{
+	{1, "initial schema", migrateInitialSchema},
+	{2, "fact revision history", migrateFactRevisions},
+	{3, "normalized fact tags", migrateFactTags},
+	{4, "repository identity on facts", migrateFactRepo},
+	{5, "fact expiry", migrateFactExpiry},
+	{6, "pinned facts", migrateFactPinned},
+	{7, "fact embeddings", migrateFactEmbeddings},
+	{8, "settings", migrateSettings},
+	{9, "fact links", migrateFactLinks},
+	{10, "fact kinds", migrateFactKinds},
+	{11, "fact usage", migrateFactUsage},
+	{12, "fact provenance", migrateFactProvenance},
+	{13, "sessions", migrateSessions},
+	{14, "fact kinds in revisions", migrateRevisionKinds},
+	{15, "encrypted fact embeddings", migrateEncryptedEmbeddings},
+}
+
+// Migrations lists every schema migration in order
+func Migrations() []Migration {
+	return migrations
+}
+
+// LatestSchemaVersion is the schema version this build migrates to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ReadSchemaVersion returns the schema version of the database in dataDir
+// without migrating it. A missing database is at version 0.
+func ReadSchemaVersion(dataDir string) (int, error) {
+	dbPath := filepath.Join(dataDir, "clauder.db")
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return 0, nil
+	}
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = db.Close() }()
+	return schemaVersion(db)
+}
+
+func schemaVersion(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}) (int, error) {
+	var version int
+	err := q.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// migrate applies every pending migration, each in its own transaction.
+// Existing databases are backed up first, and the oldest pre-migrate backups
+// are pruned once the migrations succeed.
+func (s *SQLiteStore) migrate() error {
+	version, err := schemaVersion(s.db)
+	if err != nil {
+		return err
+	}
+	latest := LatestSchemaVersion()
+	if version > latest {
+		return fmt.Errorf("%w (schema version %d, this version supports up to %d); upgrade clauder", ErrSchemaTooNew, version, latest)
+	}
+	if version == latest {
+		return nil
+	}
+
+	backupDir := ""
+	if s.dataDir != "" {
+		var existing bool
+		if err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'facts')").Scan(&existing); err != nil {
+			return err
+		}
+		if existing {
+			backupDir = filepath.Join(s.dataDir, "backups")
+			backup := filepath.Join(backupDir, fmt.Sprintf("%sv%d-%s%s", preMigratePrefix, version, time.Now().Format(backupTimeFormat), backupSuffix))
+			if err := s.Backup(context.Background(), backup); err != nil {
+				return fmt.Errorf("failed to back up database before migrating: %w", err)
+			}
+		}
+	}
+
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+	}
+
+	// Best effort: keep as many pre-migrate backups as rotating ones
+	if backupDir != "" {
+		_ = prunePreMigrateBackups(backupDir, DefaultBackupRetention)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) applyMigration(m Migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Another process may have migrated since we checked
+	version, err := schemaVersion(tx)
+	if err != nil {
+		return err
+	}
+	if version >= m.Version {
+		return nil
+	}
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", m.Version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func migrateInitialSchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS facts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		content TEXT NOT NULL,
+		tags TEXT DEFAULT '[]',
+		source_dir TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_facts_source_dir ON facts(source_dir);
+	CREATE INDEX IF NOT EXISTS idx_facts_created_at ON facts(created_at);
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS facts_fts USING fts5(content, content=facts, content_rowid=id);
+
+	CREATE TRIGGER IF NOT EXISTS facts_ai AFTER INSERT ON facts BEGIN
+		INSERT INTO facts_fts(rowid, content) VALUES (new.id, new.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS facts_ad AFTER DELETE ON facts BEGIN
+		INSERT INTO facts_fts(facts_fts, rowid, content) VALUES('delete', old.id, old.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS facts_au AFTER UPDATE ON facts BEGIN
+		INSERT INTO facts_fts(facts_fts, rowid, content) VALUES('delete', old.id, old.content);
+		INSERT INTO facts_fts(rowid, content) VALUES (new.id, new.content);
+	END;
+
+	CREATE TABLE IF NOT EXISTS instances (
+		id TEXT PRIMARY KEY,
+		pid INTEGER NOT NULL,
+		directory TEXT NOT NULL,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_heartbeat DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		from_instance TEXT NOT NULL,
+		to_instance TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		read_at DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_messages_to ON messages(to_instance);
+	CREATE INDEX IF NOT EXISTS idx_messages_unread ON messages(to_instance, read_at);
+	`)
+	return err
+}
+
+func migrateFactRevisions(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS fact_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		fact_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		tags TEXT DEFAULT '[]',
+		created_at DATETIME NOT NULL,
+		replaced_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_fact_revisions_fact_id ON fact_revisions(fact_id);
+	`)
+	return err
+}
+
+// migrateFactTags moves tags from the legacy facts.tags JSON column into
+// fact_tags. Migrated rows are reset to '[]'.
+func migrateFactTags(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS fact_tags (
+		id INTEGER PRIMARY KEY,
+		fact_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		UNIQUE(fact_id, tag)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_fact_tags_tag ON fact_tags(tag);
+	`); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id, tags FROM facts WHERE tags IS NOT NULL AND tags != '[]'")
+	if err != nil {
+		return err
+	}
+	legacy := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var tagsJSON string
+		if err := rows.Scan(&id, &tagsJSON); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		var tags []string
+		// Corrupted tags are dropped, matching how they were read before
+		_ = json.Unmarshal([]byte(tagsJSON), &tags)
+		legacy[id] = tags
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, tags := range legacy {
+		if err := insertTags(context.Background(), tx, id, normalizeTags(tags)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE facts SET tags = '[]' WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateFactRepo(tx *sql.Tx) error {
+	for _, column := range []string{"repo_id", "repo_path"} {
+		if err := addColumnIfMissing(tx, "facts", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_facts_repo ON facts(repo_id, repo_path)")
+	return err
+}
+
+func migrateFactExpiry(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "facts", "expires_at", "DATETIME"); err != nil {
+		return err
+	}
+	_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_facts_expires_at ON facts(expires_at)")
+	return err
+}
+
+func migrateFactPinned(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "facts", "pinned", "INTEGER NOT NULL DEFAULT 0")
+}
+
+func migrateFactEmbeddings(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS fact_embeddings (
+		fact_id INTEGER PRIMARY KEY,
+		model TEXT NOT NULL,
+		vector BLOB NOT NULL
+	);
+
+	CREATE TRIGGER IF NOT EXISTS facts_embeddings_ad AFTER DELETE ON facts BEGIN
+		DELETE FROM fact_embeddings WHERE fact_id = old.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS facts_embeddings_au AFTER UPDATE OF content ON facts BEGIN
+		DELETE FROM fact_embeddings WHERE fact_id = old.id;
+	END;
+	`)
+	return err
+}
+
+func migrateSettings(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	`)
+	return err
+}
+
+func migrateFactLinks(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE fact_links (
+		id INTEGER PRIMARY KEY,
+		from_id INTEGER NOT NULL,
+		to_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(from_id, to_id, kind)
+	);
+
+	CREATE INDEX idx_fact_links_to ON fact_links(to_id, kind);
+
+	CREATE TRIGGER facts_links_ad AFTER DELETE ON facts BEGIN
+		DELETE FROM fact_links WHERE from_id = old.id OR to_id = old.id;
+	END;
+	`)
+	return err
+}
+
+func migrateFactKinds(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE facts ADD COLUMN kind TEXT NOT NULL DEFAULT 'note';
+	ALTER TABLE facts ADD COLUMN details TEXT;
+
+	CREATE INDEX idx_facts_kind ON facts(kind);
+	`)
+	return err
+}
+
+func migrateFactUsage(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+	ALTER TABLE facts ADD COLUMN recall_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE facts ADD COLUMN last_recalled_at DATETIME;
+	`); err != nil {
+		return err
+	}
+
+	// Recording a recall must not reindex the fact. Encrypted databases have
+	// no on-disk index and no trigger to replace.
+	var indexed bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'trigger' AND name = 'facts_au')").Scan(&indexed); err != nil {
+		return err
+	}
+	if !indexed {
+		return nil
+	}
+	_, err := tx.Exec(`
+	DROP TRIGGER facts_au;
+	CREATE TRIGGER facts_au AFTER UPDATE OF content ON facts BEGIN
+		INSERT INTO facts_fts(facts_fts, rowid, content) VALUES('delete', old.id, old.content);
+		INSERT INTO facts_fts(rowid, content) VALUES (new.id, new.content);
+	END;
+	`)
+	return err
+}
+
+func migrateFactProvenance(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE facts ADD COLUMN client TEXT NOT NULL DEFAULT '';
+	ALTER TABLE facts ADD COLUMN client_version TEXT NOT NULL DEFAULT '';
+	ALTER TABLE facts ADD COLUMN instance_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE facts ADD COLUMN commit_sha TEXT NOT NULL DEFAULT '';
+
+	CREATE INDEX idx_facts_client ON facts(client);
+	`)
+	return err
+}
+
+func migrateSessions(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		instance_id TEXT NOT NULL UNIQUE,
+		directory TEXT NOT NULL,
+		client TEXT NOT NULL DEFAULT '',
+		client_version TEXT NOT NULL DEFAULT '',
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME,
+		summary TEXT,
+		summarized_at DATETIME
+	);
+
+	CREATE INDEX idx_sessions_directory ON sessions(directory, summarized_at);
+	CREATE INDEX idx_sessions_started_at ON sessions(started_at);
+	`)
+	return err
+}
+
+func migrateRevisionKinds(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE fact_revisions ADD COLUMN kind TEXT NOT NULL DEFAULT '';
+	ALTER TABLE fact_revisions ADD COLUMN details TEXT;
+	`)
+	return err
+}
+
+// Vectors of encrypted databases used to be stored in plaintext. They are
+// recomputed, encrypted, on the next semantic search.
+func migrateEncryptedEmbeddings(tx *sql.Tx) error {
+	_, err := tx.Exec(
+		"DELETE FROM fact_embeddings WHERE typeof(vector) = 'blob' AND EXISTS (SELECT 1 FROM settings WHERE key = ? AND value != '')",
+		settingKeyID,
+	)
+	return err
+}
+
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_ = rows.Close()
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// applyMigrationStep runs a single migration step in a transaction
+func applyMigrationStep(t *testing.T, s *SQLiteStore, up func(tx *sql.Tx) error) {
+	t.Helper()
+	tx, err := s.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if err := up(tx); err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("migration step failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+}
+
+func TestMigrations_Ordered(t *testing.T) {
+	for i, m := range Migrations() {
+		if m.Version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.Description, m.Version, i+1)
+		}
+	}
+}
+
+func TestMigrate_FreshDatabase(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	version, err := schemaVersion(store.db)
+	if err != nil {
+		t.Fatalf("failed to read version: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("expected version %d, got %d", LatestSchemaVersion(), version)
+	}
+
+	// Nothing to back up for a new database
+	if backups, _ := os.ReadDir(filepath.Join(store.dataDir, "backups")); len(backups) != 0 {
+		t.Errorf("expected no pre-migration backup, got %d", len(backups))
+	}
+}
+
+func TestMigrate_LegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+	tmpDir, err := os.MkdirTemp("", "clauder-migrate-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	// A database from before versioned migrations: version 0, JSON tags
+	db, err := sql.Open("sqlite3", filepath.Join(tmpDir, "clauder.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	legacy := &SQLiteStore{db: db}
+	applyMigrationStep(t, legacy, migrateInitialSchema)
+	if _, err := db.Exec(`INSERT INTO facts (content, tags, source_dir) VALUES ('legacy fact', '["Arch"]', '/project')`); err != nil {
+		t.Fatalf("failed to insert legacy fact: %v", err)
+	}
+	_ = db.Close()
+
+	if v, _ := ReadSchemaVersion(tmpDir); v != 0 {
+		t.Fatalf("expected legacy version 0, got %d", v)
+	}
+
+	store, err := NewSQLiteStore(tmpDir)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	if v, _ := ReadSchemaVersion(tmpDir); v != LatestSchemaVersion() {
+		t.Errorf("expected version %d after migrating, got %d", LatestSchemaVersion(), v)
+	}
+	facts, _ := store.GetFacts(ctx, "legacy", []string{"arch"}, "", 10)
+	if len(facts) != 1 {
+		t.Errorf("expected migrated fact with its tag, got %d facts", len(facts))
+	}
+
+	backups, _ := os.ReadDir(filepath.Join(tmpDir, "backups"))
+	if len(backups) != 1 || !strings.HasPrefix(backups[0].Name(), "pre-migrate-v0-") {
+		t.Fatalf("expected a pre-migration backup, got %v", backups)
+	}
+	if err := VerifyBackup(filepath.Join(tmpDir, "backups", backups[0].Name())); err != nil {
+		t.Errorf("expected a valid backup: %v", err)
+	}
+}
+
+func TestMigrate_PrunesPreMigrateBackups(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "clauder-migrate-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	// Backups left by earlier migrations, and a rotating backup
+	backupDir := filepath.Join(tmpDir, "backups")
+	_ = os.MkdirAll(backupDir, 0755)
+	for i := 0; i < DefaultBackupRetention; i++ {
+		path := filepath.Join(backupDir, fmt.Sprintf("pre-migrate-v%d-20240101-000000.db", i+1))
+		_ = os.WriteFile(path, []byte("old"), 0644)
+		old := time.Now().Add(-time.Duration(DefaultBackupRetention-i) * time.Hour)
+		_ = os.Chtimes(path, old, old)
+	}
+	_ = os.WriteFile(filepath.Join(backupDir, "clauder-20240101-000000.db"), []byte("rotating"), 0644)
+
+	db, err := sql.Open("sqlite3", filepath.Join(tmpDir, "clauder.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	applyMigrationStep(t, &SQLiteStore{db: db}, migrateInitialSchema)
+	_ = db.Close()
+
+	store, err := NewSQLiteStore(tmpDir)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	entries, _ := os.ReadDir(backupDir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	joined := strings.Join(names, " ")
+	if len(names) != DefaultBackupRetention+1 || !strings.Contains(joined, "clauder-20240101") || !strings.Contains(joined, "pre-migrate-v0-") {
+		t.Errorf("expected the newest %d pre-migrate backups and the rotating one, got %v", DefaultBackupRetention, names)
+	}
+	if strings.Contains(joined, "pre-migrate-v1-") {
+		t.Errorf("expected the oldest pre-migrate backup to be pruned, got %v", names)
+	}
+}
+
+func TestMigrate_NewerSchemaFails(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	if _, err := store.db.Exec("PRAGMA user_version = 999"); err != nil {
+		t.Fatalf("failed to set version: %v", err)
+	}
+
+	_, err := NewSQLiteStore(store.dataDir)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "upgrade clauder") {
+		t.Errorf("expected a clear error, got %v", err)
+	}
+
+	// The database is left untouched
+	if v, _ := schemaVersion(store.db); v != 999 {
+		t.Errorf("expected version to stay 999, got %d", v)
+	}
+}
+
+func TestMigrate_FailedStepRollsBack(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	failing := Migration{
+		Version:     LatestSchemaVersion() + 1,
+		Description: "broken",
+		up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	}
+	migrations = append(migrations, failing)
+	defer func() { migrations = migrations[:len(migrations)-1] }()
+
+	err := store.migrate()
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("migration %d (broken) failed", failing.Version)) {
+		t.Fatalf("expected migration error, got %v", err)
+	}
+
+	var exists bool
+	_ = store.db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = 'half_done')").Scan(&exists)
+	if exists {
+		t.Error("expected failed migration to be rolled back")
+	}
+	if v, _ := schemaVersion(store.db); v != failing.Version-1 {
+		t.Errorf("expected version to stay at %d, got %d", failing.Version-1, v)
+	}
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for a FactQuery.Cursor that was not returned
+// by SearchFactsPage
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorPrefix versions the cursor encoding
+const cursorPrefix = "o1:"
+
+// FactPage is one page of search results
+type FactPage struct {
+	Facts []Fact `json:"facts"`
+	// NextCursor fetches the next page when set as FactQuery.Cursor. It is
+	// empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total is the number of facts matching the query across all pages
+	Total int `json:"total"`
+	// Offset is the number of matching facts on earlier pages
+	Offset int `json:"offset"`
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
+
+// SearchFactsPage returns a page of up to q.Limit facts matching q, starting
+// after q.Cursor, with the total number of matches. Facts added or removed
+// between requests can shift results across page boundaries.
+func (s *SQLiteStore) SearchFactsPage(ctx context.Context, q FactQuery) (*FactPage, error) {
+	offset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := clampLimit(q.Limit)
+
+	// Fetch one more fact than the page holds to know if another page follows
+	facts, err := s.searchFacts(ctx, q, offset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &FactPage{Facts: facts, Offset: offset}
+	if len(facts) > limit {
+		page.Facts = facts[:limit]
+		page.NextCursor = encodeCursor(offset + limit)
+	}
+
+	if page.Total, err = s.CountFacts(ctx, q); err != nil {
+		return nil, fmt.Errorf("failed to count facts: %w", err)
+	}
+	return page, nil
+}
+
+// CountFacts returns the number of facts SearchFacts would return for q
+// without a limit or cursor
+func (s *SQLiteStore) CountFacts(ctx context.Context, q FactQuery) (int, error) {
+	if q.Mode != SearchKeyword && q.HasText() {
+		facts, err := s.semanticSearch(ctx, q)
+		return len(facts), err
+	}
+	if err := s.syncSearchIndex(ctx); err != nil {
+		return 0, err
+	}
+
+	from, args := keywordFrom(q)
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&n)
+	return n, err
+}
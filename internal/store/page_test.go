@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSearchFactsPage(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for i := 0; i < 7; i++ {
+		_, _ = store.AddFact(ctx, fmt.Sprintf("paged fact %d", i), nil, "/project")
+	}
+	_, _ = store.AddFact(ctx, "unrelated", nil, "/project")
+
+	for _, q := range []FactQuery{
+		{Query: "paged", Limit: 3},
+		{SourceDir: "/project", Limit: 3},
+		{Query: "paged", Mode: SearchHybrid, Limit: 3},
+	} {
+		seen := make(map[int64]bool)
+		pages := 0
+		for {
+			page, err := store.SearchFactsPage(ctx, q)
+			if err != nil {
+				t.Fatalf("SearchFactsPage(%+v) failed: %v", q, err)
+			}
+			pages++
+			for _, f := range page.Facts {
+				if seen[f.ID] {
+					t.Errorf("fact #%d returned on two pages", f.ID)
+				}
+				seen[f.ID] = true
+			}
+			if page.Total != len(seen) && page.NextCursor == "" {
+				t.Errorf("expected total %d to match the facts paged through, got %d", len(seen), page.Total)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		want := 7
+		if q.Query == "" {
+			want = 8
+		}
+		if len(seen) != want || pages != (want+2)/3 {
+			t.Errorf("expected %d facts on %d pages, got %d on %d", want, (want+2)/3, len(seen), pages)
+		}
+	}
+}
+
+func TestSearchFacts_InvalidCursor(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for _, cursor := range []string{"not a cursor", encodeCursor(-1)} {
+		if _, err := store.SearchFactsPage(ctx, FactQuery{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor for %q, got %v", cursor, err)
+		}
+	}
+}
+
+func TestCountFacts(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for i := 0; i < DefaultLimit+5; i++ {
+		_, _ = store.AddFact(ctx, fmt.Sprintf("fact %d", i), nil, "/project")
+	}
+	_, _ = store.AddFact(ctx, "elsewhere", nil, "/other")
+
+	if n, err := store.CountFacts(ctx, FactQuery{}); err != nil || n != DefaultLimit+6 {
+		t.Errorf("expected %d facts, got %d (%v)", DefaultLimit+6, n, err)
+	}
+	if n, _ := store.CountFacts(ctx, FactQuery{SourceDir: "/other"}); n != 1 {
+		t.Errorf("expected 1 fact in /other, got %d", n)
+	}
+	if n, _ := store.CountFacts(ctx, FactQuery{Query: "elsewhere"}); n != 1 {
+		t.Errorf("expected 1 fact matching the query, got %d", n)
+	}
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ParseQuery parses a recall search string into a FactQuery.
+//
+// Supported syntax:
+//
+//	word            facts containing word
+//	prefix*         facts containing a word starting with prefix
+//	"exact phrase"  facts containing the phrase
+//	a OR b          facts containing a or b (adjacent terms are ANDed)
+//	-word           facts not containing word
+//	tag:arch        facts tagged arch (-tag:arch excludes them)
+//	kind:decision   facts of that kind (repeat to match any of several)
+//	dir:~/src/api   facts stored from that directory or below it
+//	since:7d        facts updated in the last 7 days (or since a YYYY-MM-DD date)
+//	before:30d      facts last updated more than 30 days ago (or before a date)
+//
+// Every search term is quoted before it reaches FTS5, so user input can never
+// inject FTS5 operators or column filters.
+func ParseQuery(input string) (FactQuery, error) {
+	return parseQuery(input, time.Now())
+}
+
+func parseQuery(input string, now time.Time) (FactQuery, error) {
+	var q FactQuery
+	var match []string
+	var exclude []string
+	var words []string
+	pendingOR := false
+
+	for _, tok := range tokenizeQuery(input) {
+		negated := false
+		text := tok.text
+		if !tok.quoted && strings.HasPrefix(text, "-") && len(text) > 1 {
+			negated = true
+			text = text[1:]
+		}
+
+		if !tok.quoted {
+			if key, value, ok := splitFilter(text); ok {
+				if err := applyFilter(&q, key, value, negated, now); err != nil {
+					return FactQuery{}, err
+				}
+				continue
+			}
+			if text == "OR" && !negated {
+				// Only an operator between two terms; otherwise ignored
+				if len(match) > 0 {
+					pendingOR = true
+				}
+				continue
+			}
+		}
+
+		term, ok := ftsTerm(text, tok.quoted)
+		if !ok {
+			continue
+		}
+		if negated {
+			exclude = append(exclude, term)
+			continue
+		}
+		if pendingOR {
+			match = append(match, "OR")
+			pendingOR = false
+		}
+		match = append(match, term)
+		words = append(words, strings.TrimRight(text, "*"))
+	}
+
+	q.match = strings.Join(match, " ")
+	q.exclude = strings.Join(exclude, " OR ")
+	q.text = strings.Join(words, " ")
+	return q, nil
+}
+
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeQuery splits input on whitespace, keeping "quoted phrases" and
+// key:"quoted values" together. An unterminated quote runs to the end.
+func tokenizeQuery(input string) []queryToken {
+	var tokens []queryToken
+	var cur strings.Builder
+	inQuote := false
+	quotedToken := false
+
+	flush := func() {
+		if cur.Len() > 0 || quotedToken {
+			tokens = append(tokens, queryToken{text: cur.String(), quoted: quotedToken})
+		}
+		cur.Reset()
+		quotedToken = false
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			if inQuote {
+				inQuote = false
+				continue
+			}
+			inQuote = true
+			// A quote at the start of a token makes the whole token a phrase;
+			// inside a token (key:"value") it only groups the value.
+			if cur.Len() == 0 {
+				quotedToken = true
+			}
+		case unicode.IsSpace(r) && !inQuote:
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func splitFilter(text string) (key, value string, ok bool) {
+	key, value, found := strings.Cut(text, ":")
+	if !found || value == "" {
+		return "", "", false
+	}
+	switch key {
+	case "tag", "kind", "dir", "since", "before":
+		return key, value, true
+	}
+	return "", "", false
+}
+
+func applyFilter(q *FactQuery, key, value string, negated bool, now time.Time) error {
+	if negated && key != "tag" {
+		return fmt.Errorf("%s: filters cannot be negated", key)
+	}
+	switch key {
+	case "tag":
+		if negated {
+			q.ExcludeTags = append(q.ExcludeTags, value)
+		} else {
+			q.Tags = append(q.Tags, value)
+		}
+	case "kind":
+		kind, err := ParseFactKind(value)
+		if err != nil {
+			return err
+		}
+		q.Kinds = append(q.Kinds, kind)
+	case "dir":
+		dir, err := expandDir(value)
+		if err != nil {
+			return err
+		}
+		q.SourceDir = dir
+		q.DirMode = DirDescendants
+	case "since":
+		t, err := parseQueryTime(value, now)
+		if err != nil {
+			return fmt.Errorf("since: %w", err)
+		}
+		q.Since = t
+	case "before":
+		t, err := parseQueryTime(value, now)
+		if err != nil {
+			return fmt.Errorf("before: %w", err)
+		}
+		q.Before = t
+	}
+	return nil
+}
+
+// ftsTerm quotes text as a single FTS5 string, keeping a trailing * as a
+// prefix match. Terms without any letters or digits are dropped.
+func ftsTerm(text string, quoted bool) (string, bool) {
+	prefix := false
+	if !quoted && strings.HasSuffix(text, "*") {
+		prefix = true
+		text = strings.TrimRight(text, "*")
+	}
+	if !strings.ContainsFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) {
+		return "", false
+	}
+	term := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+	if prefix {
+		term += " *"
+	}
+	return term, true
+}
+
+// expandDir resolves ~ and relative paths to an absolute directory
+func expandDir(dir string) (string, error) {
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("dir: %w", err)
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("dir: %w", err)
+	}
+	return abs, nil
+}
+
+// parseQueryTime accepts a YYYY-MM-DD date or an age such as 36h, 7d or 2w
+func parseQueryTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	age, err := ParseAge(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(-age), nil
+}
+
+// ParseAge parses a duration that may also use d (days) and w (weeks) units
+func ParseAge(value string) (time.Duration, error) {
+	if n := len(value); n > 1 {
+		unit := value[n-1]
+		if unit == 'd' || unit == 'w' {
+			count, err := strconv.Atoi(value[:n-1])
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			unitDuration := 24 * time.Hour
+			if unit == 'w' {
+				unitDuration *= 7
+			}
+			if int64(count) > math.MaxInt64/int64(unitDuration) {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(count) * unitDuration, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 12h, 7d, 2w or YYYY-MM-DD)", value)
+	}
+	return d, nil
+}
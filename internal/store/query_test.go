@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input   string
+		match   string
+		exclude string
+	}{
+		{"", "", ""},
+		{"sqlite migration", `"sqlite" "migration"`, ""},
+		{`"exact phrase" word`, `"exact phrase" "word"`, ""},
+		{"migr*", `"migr" *`, ""},
+		{"redis OR nats", `"redis" OR "nats"`, ""},
+		{"OR redis", `"redis"`, ""},
+		{"redis OR", `"redis"`, ""},
+		{"deploy -staging -legacy", `"deploy"`, `"staging" OR "legacy"`},
+		{`-"old phrase"`, "", `"old phrase"`},
+		{`say "hi`, `"say" "hi"`, ""},
+		{"- * --", "", ""},
+		// FTS5 syntax is always quoted
+		{"content:secret", `"content:secret"`, ""},
+		{"NEAR(a b) AND NOT c", `"NEAR(a" "b)" "AND" "NOT" "c"`, ""},
+		{`a"b`, `"ab"`, ""},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.input)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if q.match != tt.match {
+			t.Errorf("ParseQuery(%q) match = %q, want %q", tt.input, q.match, tt.match)
+		}
+		if q.exclude != tt.exclude {
+			t.Errorf("ParseQuery(%q) exclude = %q, want %q", tt.input, q.exclude, tt.exclude)
+		}
+	}
+}
+
+func TestParseQuery_Filters(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+
+	q, err := parseQuery(`tag:arch -tag:old dir:/src/api since:7d before:2026-03-01 cache`, now)
+	if err != nil {
+		t.Fatalf("parseQuery failed: %v", err)
+	}
+	if len(q.Tags) != 1 || q.Tags[0] != "arch" {
+		t.Errorf("unexpected tags: %v", q.Tags)
+	}
+	if len(q.ExcludeTags) != 1 || q.ExcludeTags[0] != "old" {
+		t.Errorf("unexpected excluded tags: %v", q.ExcludeTags)
+	}
+	if q.SourceDir != "/src/api" {
+		t.Errorf("unexpected dir: %s", q.SourceDir)
+	}
+	if !q.Since.Equal(now.Add(-7 * 24 * time.Hour)) {
+		t.Errorf("unexpected since: %v", q.Since)
+	}
+	if !q.Before.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("unexpected before: %v", q.Before)
+	}
+	if q.match != `"cache"` {
+		t.Errorf("unexpected match: %s", q.match)
+	}
+
+	// Unknown keys are plain search terms
+	q, _ = parseQuery("http://example.com", now)
+	if q.match != `"http://example.com"` {
+		t.Errorf("unexpected match for unknown key: %s", q.match)
+	}
+}
+
+func TestParseQuery_HomeDir(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	q, err := ParseQuery(`dir:"~/my src/api"`)
+	if err != nil {
+		t.Fatalf("ParseQuery failed: %v", err)
+	}
+	if q.SourceDir != filepath.Join(home, "my src/api") {
+		t.Errorf("unexpected dir: %s", q.SourceDir)
+	}
+}
+
+func TestParseQuery_Errors(t *testing.T) {
+	for _, input := range []string{"since:yesterday", "before:-3d", "-dir:/tmp"} {
+		if _, err := ParseQuery(input); err == nil {
+			t.Errorf("ParseQuery(%q) expected error", input)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"90m", 90 * time.Minute},
+		{"36h", 36 * time.Hour},
+		{"7d", 7 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		d, err := ParseAge(tt.input)
+		if err != nil {
+			t.Errorf("ParseAge(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if d != tt.expected {
+			t.Errorf("ParseAge(%q) = %v, want %v", tt.input, d, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"-3d", "200000w", "99999999999d", "soon"} {
+		if _, err := ParseAge(input); err == nil {
+			t.Errorf("expected ParseAge(%q) to fail", input)
+		}
+	}
+}
+
+func TestSearchFacts_ParsedQuery(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	migration, _ := store.AddFact(ctx, "migration to SQLite finished", []string{"db"}, "/project")
+	redis, _ := store.AddFact(ctx, "we cache sessions in redis", []string{"arch"}, "/project")
+	nats, _ := store.AddFact(ctx, "events go through nats", []string{"arch", "old"}, "/other")
+	old, _ := store.AddFact(ctx, "legacy deploy script", nil, "/project")
+	_, _ = store.db.Exec("UPDATE facts SET updated_at = ? WHERE id = ?", time.Now().Add(-60*24*time.Hour), old.ID)
+
+	tests := []struct {
+		query    string
+		expected []int64
+	}{
+		{"sqlite migration", []int64{migration.ID}},
+		{"migr*", []int64{migration.ID}},
+		{`"SQLite migration"`, nil},
+		{"redis OR nats", []int64{redis.ID, nats.ID}},
+		{"tag:arch -tag:old", []int64{redis.ID}},
+		{"tag:arch -redis", []int64{nats.ID}},
+		{"dir:/other", []int64{nats.ID}},
+		{"since:30d deploy", nil},
+		{"before:30d", []int64{old.ID}},
+		{"content:redis", nil},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) failed: %v", tt.query, err)
+		}
+		facts, err := store.SearchFacts(ctx, q)
+		if err != nil {
+			t.Fatalf("SearchFacts(%q) failed: %v", tt.query, err)
+		}
+		got := make(map[int64]bool)
+		for _, f := range facts {
+			got[f.ID] = true
+		}
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: expected %d facts, got %d", tt.query, len(tt.expected), len(got))
+			continue
+		}
+		for _, id := range tt.expected {
+			if !got[id] {
+				t.Errorf("%q: expected fact #%d in results", tt.query, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maorbril/clauder/internal/gitrepo"
+)
+
+// RelocateFacts rewrites the source directory of facts stored in oldDir or
+// below it to the same location under newDir, e.g. after moving a checkout.
+// It returns the number of facts rewritten.
+func (s *SQLiteStore) RelocateFacts(ctx context.Context, oldDir, newDir string) (int64, error) {
+	oldDir, newDir = filepath.Clean(oldDir), filepath.Clean(newDir)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	dirs, err := factDirsUnder(ctx, tx, oldDir, "")
+	if err != nil {
+		return 0, err
+	}
+
+	for id, dir := range dirs {
+		relocated := newDir + strings.TrimPrefix(dir, oldDir)
+		if _, err := tx.ExecContext(ctx, "UPDATE facts SET source_dir = ? WHERE id = ?", relocated, id); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(dirs)), tx.Commit()
+}
+
+// AssignRepo records repoID and the repo-relative path on facts stored inside
+// the checkout at root that have no repository identity yet, so that they
+// follow the repository to other clones. Facts inside repositories nested in
+// root, such as submodules or vendored clones, are left for those. It returns
+// the number of facts updated.
+func (s *SQLiteStore) AssignRepo(ctx context.Context, root, repoID string) (int64, error) {
+	if root == "" || repoID == "" {
+		return 0, nil
+	}
+	root = filepath.Clean(root)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	dirs, err := factDirsUnder(ctx, tx, root, "AND repo_id = ''")
+	if err != nil {
+		return 0, err
+	}
+
+	var assigned int64
+	nested := make(map[string]bool)
+	for id, dir := range dirs {
+		inNested, ok := nested[dir]
+		if !ok {
+			inNested = inNestedRepo(root, dir)
+			nested[dir] = inNested
+		}
+		if inNested {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE facts SET repo_id = ?, repo_path = ? WHERE id = ?",
+			repoID, gitrepo.RelPath(root, dir), id,
+		); err != nil {
+			return 0, err
+		}
+		assigned++
+	}
+	return assigned, tx.Commit()
+}
+
+// inNestedRepo reports whether dir, inside root, belongs to another working
+// tree: a .git entry in dir or one of its parents below root
+func inNestedRepo(root, dir string) bool {
+	for dir != root {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+	return false
+}
+
+// factDirsUnder returns the source directory of each fact stored at or below
+// dir, keyed by fact ID
+func factDirsUnder(ctx context.Context, tx *sql.Tx, dir, extraCond string) (map[int64]string, error) {
+	sep := string(filepath.Separator)
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, source_dir FROM facts WHERE (source_dir = ? OR source_dir LIKE ? ESCAPE '\') `+extraCond,
+		dir, escapeLike(strings.TrimSuffix(dir, sep))+sep+"%",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	dirs := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var d string
+		if err := rows.Scan(&id, &d); err != nil {
+			return nil, err
+		}
+		dirs[id] = d
+	}
+	return dirs, rows.Err()
+}
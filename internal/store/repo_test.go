@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelocateFacts(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	root, _ := store.AddFact(ctx, "root fact", nil, "/old/app")
+	nested, _ := store.AddFact(ctx, "nested fact", nil, "/old/app/api")
+	lookalike, _ := store.AddFact(ctx, "lookalike fact", nil, "/old/application")
+
+	n, err := store.RelocateFacts(ctx, "/old/app", "/new/app")
+	if err != nil {
+		t.Fatalf("RelocateFacts failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 relocated facts, got %d", n)
+	}
+
+	if f, _ := store.GetFactByID(ctx, root.ID); f.SourceDir != "/new/app" {
+		t.Errorf("unexpected root dir: %s", f.SourceDir)
+	}
+	if f, _ := store.GetFactByID(ctx, nested.ID); f.SourceDir != "/new/app/api" {
+		t.Errorf("unexpected nested dir: %s", f.SourceDir)
+	}
+	if f, _ := store.GetFactByID(ctx, lookalike.ID); f.SourceDir != "/old/application" {
+		t.Errorf("expected lookalike dir to be untouched, got %s", f.SourceDir)
+	}
+
+	// Moving into a subdirectory of itself rewrites each fact once
+	_, _ = store.RelocateFacts(ctx, "/new/app", "/new/app/v1")
+	if f, _ := store.GetFactByID(ctx, nested.ID); f.SourceDir != "/new/app/v1/api" {
+		t.Errorf("unexpected nested dir after second move: %s", f.SourceDir)
+	}
+}
+
+func TestAssignRepo(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	root, _ := store.AddFact(ctx, "root fact", nil, "/src/app")
+	nested, _ := store.AddFact(ctx, "nested fact", nil, "/src/app/services/api")
+	outside, _ := store.AddFact(ctx, "outside fact", nil, "/src/other")
+
+	n, err := store.AssignRepo(ctx, "/src/app", "github.com/org/app")
+	if err != nil {
+		t.Fatalf("AssignRepo failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 facts assigned, got %d", n)
+	}
+
+	if f, _ := store.GetFactByID(ctx, root.ID); f.RepoID != "github.com/org/app" || f.RepoPath != "." {
+		t.Errorf("unexpected root repo: %q %q", f.RepoID, f.RepoPath)
+	}
+	if f, _ := store.GetFactByID(ctx, nested.ID); f.RepoPath != "services/api" {
+		t.Errorf("unexpected nested repo path: %q", f.RepoPath)
+	}
+	if f, _ := store.GetFactByID(ctx, outside.ID); f.RepoID != "" {
+		t.Errorf("expected outside fact to be untouched, got %q", f.RepoID)
+	}
+
+	// Already assigned facts are left alone
+	n, _ = store.AssignRepo(ctx, "/src/app", "github.com/org/fork")
+	if n != 0 {
+		t.Errorf("expected no reassignment, got %d", n)
+	}
+}
+
+func TestAssignRepo_SkipsNestedRepos(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// A dotfiles repo at the root, with a project cloned inside it
+	root := t.TempDir()
+	project := filepath.Join(root, "src", "project")
+	_ = os.MkdirAll(filepath.Join(project, ".git"), 0755)
+	_ = os.MkdirAll(filepath.Join(root, ".git"), 0755)
+
+	outer, _ := store.AddFact(ctx, "outer fact", nil, filepath.Join(root, "src"))
+	inner, _ := store.AddFact(ctx, "inner fact", nil, filepath.Join(project, "cmd"))
+
+	n, err := store.AssignRepo(ctx, root, "github.com/me/dotfiles")
+	if err != nil {
+		t.Fatalf("AssignRepo failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 fact assigned, got %d", n)
+	}
+	if f, _ := store.PeekFact(ctx, outer.ID); f.RepoID != "github.com/me/dotfiles" {
+		t.Errorf("expected the outer fact to be assigned, got %q", f.RepoID)
+	}
+	if f, _ := store.PeekFact(ctx, inner.ID); f.RepoID != "" {
+		t.Errorf("expected the fact in the nested repo to be left alone, got %q", f.RepoID)
+	}
+
+	// The nested repo can still claim it
+	if n, _ := store.AssignRepo(ctx, project, "github.com/me/project"); n != 1 {
+		t.Errorf("expected the nested repo to claim its fact, got %d", n)
+	}
+}
+
+func TestSearchFacts_MatchesOtherCheckouts(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// Stored from a teammate's clone at a different path
+	shared, _ := store.InsertFact(ctx, Fact{Content: "repo convention", SourceDir: "/home/alice/app", RepoID: "github.com/org/app", RepoPath: "."})
+	api, _ := store.InsertFact(ctx, Fact{Content: "api convention", SourceDir: "/home/alice/app/api", RepoID: "github.com/org/app", RepoPath: "api"})
+	_, _ = store.InsertFact(ctx, Fact{Content: "web convention", SourceDir: "/home/alice/app/web", RepoID: "github.com/org/app", RepoPath: "web"})
+	_, _ = store.InsertFact(ctx, Fact{Content: "other repo", SourceDir: "/home/alice/lib", RepoID: "github.com/org/lib", RepoPath: "."})
+
+	facts, err := store.SearchFacts(ctx, FactQuery{
+		SourceDir: "/work/app/api",
+		DirMode:   DirAncestors,
+		ScopeRoot: "/work/app",
+		RepoID:    "github.com/org/app",
+	})
+	if err != nil {
+		t.Fatalf("SearchFacts failed: %v", err)
+	}
+	got := make(map[int64]bool)
+	for _, f := range facts {
+		got[f.ID] = true
+	}
+	if len(got) != 2 || !got[shared.ID] || !got[api.ID] {
+		t.Errorf("expected repo root and api facts from the other clone, got %v", facts)
+	}
+
+	facts, _ = store.SearchFacts(ctx, FactQuery{
+		SourceDir: "/work/app",
+		DirMode:   DirDescendants,
+		ScopeRoot: "/work/app",
+		RepoID:    "github.com/org/app",
+	})
+	if len(facts) != 3 {
+		t.Errorf("expected every fact of the repository, got %d", len(facts))
+	}
+}
@@ -0,0 +1,236 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Semantic ranking tuning
+const (
+	// MinSemanticScore is the cosine similarity below which facts are not
+	// considered semantic matches
+	MinSemanticScore = 0.1
+	// hybridKeywordWeight is the share of normalized BM25 in hybrid scores
+	hybridKeywordWeight = 0.5
+)
+
+// ParseSearchMode parses "keyword", "semantic" or "hybrid"
+func ParseSearchMode(s string) (SearchMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "keyword":
+		return SearchKeyword, nil
+	case "semantic":
+		return SearchSemantic, nil
+	case "hybrid":
+		return SearchHybrid, nil
+	}
+	return SearchKeyword, fmt.Errorf("invalid search mode %q (use keyword, semantic or hybrid)", s)
+}
+
+// SetEmbedder replaces the embedder used for semantic search. Vectors stored
+// by a different embedder are recomputed on the next semantic search.
+func (s *SQLiteStore) SetEmbedder(e Embedder) {
+	s.embedder = e
+}
+
+// embedFact computes and stores the embedding of a fact's content
+func (s *SQLiteStore) embedFact(ctx context.Context, id int64, content string) error {
+	vec, err := s.embedder.Embed(content)
+	if err != nil {
+		return err
+	}
+	sealed, err := s.sealVector(vec)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO fact_embeddings (fact_id, model, vector) VALUES (?, ?, ?)",
+		id, s.embedder.Name(), sealed,
+	)
+	return err
+}
+
+// sealVector encodes vec for storage, encrypted if the database is: vectors
+// of hashed words reveal which words a fact contains
+func (s *SQLiteStore) sealVector(vec []float32) (interface{}, error) {
+	if s.cipher == nil {
+		return encodeVector(vec), nil
+	}
+	return s.cipher.seal(string(encodeVector(vec)))
+}
+
+// openVector decodes a stored vector, decrypting it if needed
+func (s *SQLiteStore) openVector(stored []byte) ([]float32, error) {
+	if s.cipher == nil {
+		return decodeVector(stored), nil
+	}
+	plaintext, err := s.cipher.open(string(stored))
+	if err != nil {
+		return nil, err
+	}
+	return decodeVector([]byte(plaintext)), nil
+}
+
+// embedMissing computes embeddings for facts that have none from the current
+// embedder, e.g. facts stored before semantic search or under another model
+func (s *SQLiteStore) embedMissing(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT f.id, f.content FROM facts f WHERE NOT EXISTS (SELECT 1 FROM fact_embeddings e WHERE e.fact_id = f.id AND e.model = ?)",
+		s.embedder.Name(),
+	)
+	if err != nil {
+		return err
+	}
+	pending := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if content, err = s.cipher.open(content); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		pending[id] = content
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, content := range pending {
+		if err := s.embedFact(ctx, id, content); err != nil {
+			return fmt.Errorf("failed to embed fact #%d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// semanticSearch ranks all the facts matching q's filters by cosine
+// similarity to the query text, blended with BM25 in hybrid mode
+func (s *SQLiteStore) semanticSearch(ctx context.Context, q FactQuery) ([]Fact, error) {
+	if err := s.embedMissing(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.syncSearchIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	text := q.text
+	if text == "" {
+		text = q.Query
+	}
+	queryVec, err := s.embedder.Embed(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	conditions, filterArgs := filterConditions(q)
+	args := append([]interface{}{s.embedder.Name()}, filterArgs...)
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+factColumns+", e.vector FROM facts f JOIN fact_embeddings e ON e.fact_id = f.id AND e.model = ?"+
+			" WHERE "+strings.Join(conditions, " AND "),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var candidates []Fact
+	for rows.Next() {
+		var f Fact
+		var blob []byte
+		if err := s.scanFact(rows, &f, &blob); err != nil {
+			return nil, err
+		}
+		vec, err := s.openVector(blob)
+		if err != nil {
+			return nil, err
+		}
+		f.Score = cosine(queryVec, vec)
+		candidates = append(candidates, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Keyword matches by ID, with BM25 normalized to [0, 1]
+	keywordScores := make(map[int64]float64)
+	snippets := make(map[int64]string)
+	if q.Mode == SearchHybrid {
+		kq := q
+		kq.Mode = SearchKeyword
+		kq.RecencyHalfLife = 0
+		kq.PinnedFirst = false
+		keyword, err := s.keywordSearch(ctx, kq, 0, MaxLimit)
+		if err != nil {
+			return nil, err
+		}
+		maxScore := 0.0
+		for _, f := range keyword {
+			maxScore = math.Max(maxScore, f.Score)
+		}
+		for _, f := range keyword {
+			if maxScore > 0 {
+				keywordScores[f.ID] = f.Score / maxScore
+			}
+			snippets[f.ID] = f.Snippet
+		}
+	}
+
+	var facts []Fact
+	for _, f := range candidates {
+		_, keywordMatch := snippets[f.ID]
+		if f.Score < MinSemanticScore && !keywordMatch {
+			continue
+		}
+		if q.Mode == SearchHybrid {
+			f.Score = (1-hybridKeywordWeight)*f.Score + hybridKeywordWeight*keywordScores[f.ID]
+			f.Snippet = snippets[f.ID]
+		}
+		f.Score *= usageFactor(f.RecallCount)
+		if q.RecencyHalfLife > 0 {
+			age := time.Since(f.UpdatedAt)
+			f.Score /= 1 + float64(age)/float64(q.RecencyHalfLife)
+		}
+		facts = append(facts, f)
+	}
+
+	sort.SliceStable(facts, func(i, j int) bool {
+		if q.PinnedFirst && facts[i].Pinned != facts[j].Pinned {
+			return facts[i].Pinned
+		}
+		if facts[i].Score != facts[j].Score {
+			return facts[i].Score > facts[j].Score
+		}
+		if !facts[i].UpdatedAt.Equal(facts[j].UpdatedAt) {
+			return facts[i].UpdatedAt.After(facts[j].UpdatedAt)
+		}
+		return facts[i].ID > facts[j].ID
+	})
+	return facts, nil
+}
+
+func encodeVector(vec []float32) []byte {
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	vec := make([]float32, len(buf)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vec
+}
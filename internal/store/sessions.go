@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrNoSession is returned when summarizing a session that was never started
+var ErrNoSession = errors.New("no session recorded for this instance")
+
+// sessionColumns lists the session columns read by scanSession
+const sessionColumns = "id, instance_id, directory, client, client_version, started_at, ended_at, summary, summarized_at"
+
+// StartSession records that instanceID started serving in directory
+func (s *SQLiteStore) StartSession(ctx context.Context, instanceID, directory string) (*Session, error) {
+	now := time.Now()
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO sessions (instance_id, directory, started_at) VALUES (?, ?, ?)",
+		instanceID, directory, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{ID: id, InstanceID: instanceID, Directory: directory, StartedAt: now}, nil
+}
+
+// SetSessionClient records the MCP client connected to the session of
+// instanceID
+func (s *SQLiteStore) SetSessionClient(ctx context.Context, instanceID, client, version string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET client = ?, client_version = ? WHERE instance_id = ?",
+		client, version, instanceID,
+	)
+	return err
+}
+
+// SummarizeSession stores a handoff summary for the next session, replacing
+// any earlier summary of the session of instanceID
+func (s *SQLiteStore) SummarizeSession(ctx context.Context, instanceID, summary string) error {
+	sealed, err := s.cipher.seal(summary)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET summary = ?, summarized_at = ? WHERE instance_id = ?",
+		sealed, time.Now(), instanceID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoSession
+	}
+	return nil
+}
+
+// EndSession records that the session of instanceID ended
+func (s *SQLiteStore) EndSession(ctx context.Context, instanceID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET ended_at = ? WHERE instance_id = ? AND ended_at IS NULL",
+		time.Now(), instanceID,
+	)
+	return err
+}
+
+// ListSessions returns up to limit sessions, newest first. A non-empty
+// directory only returns sessions started there.
+func (s *SQLiteStore) ListSessions(ctx context.Context, directory string, limit int) ([]Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions"
+	var args []interface{}
+	if directory != "" {
+		query += " WHERE directory = ?"
+		args = append(args, directory)
+	}
+	query += " ORDER BY started_at DESC, id DESC LIMIT ?"
+	args = append(args, clampLimit(limit))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		if err := s.scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// LastSession returns the most recently summarized session in directory
+// other than that of exceptInstanceID, or nil if there is none
+func (s *SQLiteStore) LastSession(ctx context.Context, directory, exceptInstanceID string) (*Session, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+sessionColumns+" FROM sessions WHERE directory = ? AND instance_id != ? AND summary IS NOT NULL ORDER BY summarized_at DESC, id DESC LIMIT 1",
+		directory, exceptInstanceID,
+	)
+	var session Session
+	if err := s.scanSession(row, &session); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// scanSession scans a row selected with sessionColumns into session,
+// decrypting its summary
+func (s *SQLiteStore) scanSession(row rowScanner, session *Session) error {
+	var endedAt, summarizedAt sql.NullTime
+	var summary sql.NullString
+	if err := row.Scan(&session.ID, &session.InstanceID, &session.Directory, &session.Client, &session.ClientVersion,
+		&session.StartedAt, &endedAt, &summary, &summarizedAt); err != nil {
+		return err
+	}
+	if endedAt.Valid {
+		session.EndedAt = &endedAt.Time
+	}
+	if summarizedAt.Valid {
+		session.SummarizedAt = &summarizedAt.Time
+	}
+	var err error
+	session.Summary, err = s.cipher.open(summary.String)
+	return err
+}
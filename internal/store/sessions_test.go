@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.StartSession(ctx, "first", "/project")
+	_ = store.SetSessionClient(ctx, "first", "claude-code", "2.0")
+	if err := store.SummarizeSession(ctx, "first", "refactored the parser"); err != nil {
+		t.Fatalf("SummarizeSession failed: %v", err)
+	}
+	_ = store.EndSession(ctx, "first")
+	_, _ = store.StartSession(ctx, "elsewhere", "/other")
+	_ = store.SummarizeSession(ctx, "elsewhere", "worked on something else")
+	_, _ = store.StartSession(ctx, "second", "/project")
+
+	last, err := store.LastSession(ctx, "/project", "second")
+	if err != nil {
+		t.Fatalf("LastSession failed: %v", err)
+	}
+	if last == nil || last.InstanceID != "first" || last.Summary != "refactored the parser" || last.SummarizedAt == nil {
+		t.Fatalf("expected the summary of the first session, got %+v", last)
+	}
+	if last.Client != "claude-code" || last.ClientVersion != "2.0" || last.EndedAt == nil {
+		t.Errorf("expected client and end to be recorded, got %+v", last)
+	}
+	if last, _ := store.LastSession(ctx, "/project", "first"); last != nil {
+		t.Errorf("expected a session not to see its own summary, got %+v", last)
+	}
+
+	sessions, _ := store.ListSessions(ctx, "", 10)
+	if len(sessions) != 3 || sessions[0].InstanceID != "second" {
+		t.Errorf("expected 3 sessions, newest first, got %+v", sessions)
+	}
+	if sessions, _ := store.ListSessions(ctx, "/project", 10); len(sessions) != 2 {
+		t.Errorf("expected 2 sessions in /project, got %d", len(sessions))
+	}
+
+	if err := store.SummarizeSession(ctx, "unknown", "summary"); !errors.Is(err, ErrNoSession) {
+		t.Errorf("expected ErrNoSession, got %v", err)
+	}
+}
+
+func TestSessions_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.StartSession(ctx, "first", "/project")
+	_ = store.SummarizeSession(ctx, "first", "customer acme is on the beta")
+
+	key, _ := GenerateKey()
+	t.Setenv(EncryptionKeyEnv, EncodeKey(key))
+	if err := store.SetEncryptionKey(ctx, key, nil); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+
+	var stored string
+	_ = store.db.QueryRow("SELECT summary FROM sessions").Scan(&stored)
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		t.Errorf("expected the summary to be encrypted, got %q", stored)
+	}
+	if last, _ := store.LastSession(ctx, "/project", ""); last == nil || last.Summary != "customer acme is on the beta" {
+		t.Errorf("expected the decrypted summary, got %+v", last)
+	}
+}
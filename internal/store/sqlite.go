@@ -1,14 +1,18 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/maorbril/clauder/internal/gitrepo"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -18,8 +22,28 @@ const (
 	DefaultLimit = 100
 )
 
+// Snippet formatting for full-text search results
+const (
+	SnippetOpen     = "**"
+	SnippetClose    = "**"
+	SnippetEllipsis = "..."
+	snippetTokens   = 24
+)
+
 type SQLiteStore struct {
-	db *sql.DB
+	db       *sql.DB
+	dataDir  string
+	embedder Embedder
+	cipher   *contentCipher
+	index    searchIndex
+}
+
+// searchIndex tracks the in-memory full-text index of an encrypted database
+type searchIndex struct {
+	mu          sync.Mutex
+	ready       bool
+	dataVersion int64
+	changes     int64
 }
 
 func NewSQLiteStore(dataDir string) (*SQLiteStore, error) {
@@ -33,69 +57,19 @@ func NewSQLiteStore(dataDir string) (*SQLiteStore, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	store := &SQLiteStore{db: db}
+	store := &SQLiteStore{db: db, dataDir: dataDir, embedder: DefaultEmbedder()}
 	if err := store.migrate(); err != nil {
 		_ = db.Close()
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+	if err := store.initEncryption(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
 
 	return store, nil
 }
 
-func (s *SQLiteStore) migrate() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS facts (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		content TEXT NOT NULL,
-		tags TEXT DEFAULT '[]',
-		source_dir TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_facts_source_dir ON facts(source_dir);
-	CREATE INDEX IF NOT EXISTS idx_facts_created_at ON facts(created_at);
-
-	CREATE VIRTUAL TABLE IF NOT EXISTS facts_fts USING fts5(content, content=facts, content_rowid=id);
-
-	CREATE TRIGGER IF NOT EXISTS facts_ai AFTER INSERT ON facts BEGIN
-		INSERT INTO facts_fts(rowid, content) VALUES (new.id, new.content);
-	END;
-
-	CREATE TRIGGER IF NOT EXISTS facts_ad AFTER DELETE ON facts BEGIN
-		INSERT INTO facts_fts(facts_fts, rowid, content) VALUES('delete', old.id, old.content);
-	END;
-
-	CREATE TRIGGER IF NOT EXISTS facts_au AFTER UPDATE ON facts BEGIN
-		INSERT INTO facts_fts(facts_fts, rowid, content) VALUES('delete', old.id, old.content);
-		INSERT INTO facts_fts(rowid, content) VALUES (new.id, new.content);
-	END;
-
-	CREATE TABLE IF NOT EXISTS instances (
-		id TEXT PRIMARY KEY,
-		pid INTEGER NOT NULL,
-		directory TEXT NOT NULL,
-		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		last_heartbeat DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS messages (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		from_instance TEXT NOT NULL,
-		to_instance TEXT NOT NULL,
-		content TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		read_at DATETIME
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_messages_to ON messages(to_instance);
-	CREATE INDEX IF NOT EXISTS idx_messages_unread ON messages(to_instance, read_at);
-	`
-
-	_, err := s.db.Exec(schema)
-	return err
-}
-
 // sanitizeFTSQuery escapes special FTS5 operators to prevent query injection
 func sanitizeFTSQuery(query string) string {
 	// Escape double quotes by doubling them
@@ -104,86 +78,210 @@ func sanitizeFTSQuery(query string) string {
 	return `"` + query + `"`
 }
 
+// escapeLike escapes LIKE wildcards for use with ESCAPE '\'
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+// AncestorDirs returns dir followed by each of its parents up to and including
+// root. If root is empty or does not contain dir, only dir is returned.
+func AncestorDirs(dir, root string) []string {
+	dir = filepath.Clean(dir)
+	dirs := []string{dir}
+	if root == "" {
+		return dirs
+	}
+	root = filepath.Clean(root)
+	if rel, err := filepath.Rel(root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return dirs
+	}
+	for dir != root {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// factColumns lists the fact columns read by scanFact, with facts aliased as f
+const factColumns = "f.id, f.content, f.kind, f.details, " + factTagsColumn + ", f.source_dir, f.repo_id, f.repo_path, f.client, f.client_version, f.instance_id, f.commit_sha, f.created_at, f.updated_at, f.expires_at, f.pinned, f.recall_count, f.last_recalled_at, " + factSupersededByColumn
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanFact scans a row selected with factColumns into f, followed by any
+// extra columns, decrypting its content.
+func (s *SQLiteStore) scanFact(row rowScanner, f *Fact, extra ...interface{}) error {
+	var tagsJSON string
+	var expiresAt, lastRecalledAt sql.NullTime
+	var supersededBy, details sql.NullString
+	var kind string
+	dest := []interface{}{&f.ID, &f.Content, &kind, &details, &tagsJSON, &f.SourceDir, &f.RepoID, &f.RepoPath, &f.Client, &f.ClientVersion, &f.InstanceID, &f.CommitSHA, &f.CreatedAt, &f.UpdatedAt, &expiresAt, &f.Pinned, &f.RecallCount, &lastRecalledAt, &supersededBy}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	f.Kind = FactKind(kind)
+	f.SupersededBy = parseIDList(supersededBy.String)
+	if expiresAt.Valid {
+		f.ExpiresAt = &expiresAt.Time
+	}
+	if lastRecalledAt.Valid {
+		f.LastRecalledAt = &lastRecalledAt.Time
+	}
+	if err := json.Unmarshal([]byte(tagsJSON), &f.Tags); err != nil {
+		// If tags are corrupted, initialize to empty slice
+		f.Tags = []string{}
+	}
+	var err error
+	if f.Details, err = s.openDetails(details.String); err != nil {
+		return err
+	}
+	f.Content, err = s.cipher.open(f.Content)
+	return err
+}
+
 // Facts
 
-func (s *SQLiteStore) AddFact(content string, tags []string, sourceDir string) (*Fact, error) {
-	if tags == nil {
-		tags = []string{}
+func (s *SQLiteStore) AddFact(ctx context.Context, content string, tags []string, sourceDir string) (*Fact, error) {
+	return s.InsertFact(ctx, Fact{Content: content, Tags: tags, SourceDir: sourceDir})
+}
+
+// InsertFact stores a new fact with all of its optional attributes. The ID
+// and timestamps of f are ignored and set by the store.
+func (s *SQLiteStore) InsertFact(ctx context.Context, f Fact) (*Fact, error) {
+	f.Tags = normalizeTags(f.Tags)
+	if f.Kind == "" {
+		f.Kind = KindNote
+	}
+	if err := f.Details.Validate(f.Kind); err != nil {
+		return nil, err
+	}
+	sealed, err := s.cipher.seal(f.Content)
+	if err != nil {
+		return nil, err
+	}
+	details, err := s.sealDetails(f.Details)
+	if err != nil {
+		return nil, err
 	}
-	tagsJSON, err := json.Marshal(tags)
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer func() { _ = tx.Rollback() }()
 
 	now := time.Now()
-	result, err := s.db.Exec(
-		"INSERT INTO facts (content, tags, source_dir, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
-		content, string(tagsJSON), sourceDir, now, now,
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO facts (content, kind, details, source_dir, repo_id, repo_path, client, client_version, instance_id, commit_sha, created_at, updated_at, expires_at, pinned) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		sealed, string(f.Kind), details, f.SourceDir, f.RepoID, f.RepoPath, f.Client, f.ClientVersion, f.InstanceID, f.CommitSHA, now, now, f.ExpiresAt, f.Pinned,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	f.ID, err = result.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Fact{
-		ID:        id,
-		Content:   content,
+	if err := insertTags(ctx, tx, f.ID, f.Tags); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Best effort: missing embeddings are computed when searching
+	_ = s.embedFact(ctx, f.ID, f.Content)
+
+	f.CreatedAt = now
+	f.UpdatedAt = now
+	return &f, nil
+}
+
+// GetFacts returns up to limit facts matching query, tags and sourceDir. Use
+// SearchFactsPage to page through more.
+func (s *SQLiteStore) GetFacts(ctx context.Context, query string, tags []string, sourceDir string, limit int) ([]Fact, error) {
+	return s.SearchFacts(ctx, FactQuery{
+		Query:     query,
 		Tags:      tags,
 		SourceDir: sourceDir,
-		CreatedAt: now,
-		UpdatedAt: now,
-	}, nil
+		Limit:     limit,
+	})
 }
 
-func (s *SQLiteStore) GetFacts(query string, tags []string, sourceDir string, limit int) ([]Fact, error) {
-	var args []interface{}
-	var conditions []string
-
-	baseQuery := "SELECT f.id, f.content, f.tags, f.source_dir, f.created_at, f.updated_at FROM facts f"
-
-	if query != "" {
-		baseQuery = "SELECT f.id, f.content, f.tags, f.source_dir, f.created_at, f.updated_at FROM facts f JOIN facts_fts fts ON f.id = fts.rowid WHERE fts.content MATCH ?"
-		// Sanitize FTS query to prevent operator injection
-		args = append(args, sanitizeFTSQuery(query))
+// SearchFacts returns facts matching q. Full-text queries are ranked by BM25
+// (boosted for often recalled facts, and optionally blended with recency) and
+// carry a highlighted snippet; other
+// searches are ordered by most recently updated. Semantic and hybrid modes
+// rank text queries by embedding similarity instead. Expired facts are never
+// returned. A q.Cursor from SearchFactsPage resumes after an earlier page.
+func (s *SQLiteStore) SearchFacts(ctx context.Context, q FactQuery) ([]Fact, error) {
+	offset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
 	}
+	return s.searchFacts(ctx, q, offset, clampLimit(q.Limit))
+}
 
-	if sourceDir != "" {
-		conditions = append(conditions, "f.source_dir = ?")
-		args = append(args, sourceDir)
+// searchFacts returns up to limit facts matching q, skipping the first offset
+func (s *SQLiteStore) searchFacts(ctx context.Context, q FactQuery, offset, limit int) ([]Fact, error) {
+	if q.Mode != SearchKeyword && q.HasText() {
+		facts, err := s.semanticSearch(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		if offset >= len(facts) {
+			return nil, nil
+		}
+		facts = facts[offset:]
+		if len(facts) > limit {
+			facts = facts[:limit]
+		}
+		return facts, nil
 	}
+	return s.keywordSearch(ctx, q, offset, limit)
+}
 
-	if len(tags) > 0 {
-		for _, tag := range tags {
-			// Escape any quotes in tag for LIKE pattern safety
-			safeTag := strings.ReplaceAll(tag, `"`, `""`)
-			conditions = append(conditions, "f.tags LIKE ?")
-			args = append(args, "%\""+safeTag+"\"%")
-		}
+func (s *SQLiteStore) keywordSearch(ctx context.Context, q FactQuery, offset, limit int) ([]Fact, error) {
+	if err := s.syncSearchIndex(ctx); err != nil {
+		return nil, err
 	}
 
-	if len(conditions) > 0 {
-		if query != "" {
-			baseQuery += " AND " + strings.Join(conditions, " AND ")
-		} else {
-			baseQuery += " WHERE " + strings.Join(conditions, " AND ")
+	var args []interface{}
+	selectColumns := factColumns + ", 0.0, ''"
+	orderBy := " ORDER BY f.updated_at DESC"
+
+	if q.ftsMatch() != "" {
+		score := "(-bm25(facts_fts) * " + usageFactorColumn + ")"
+		if q.RecencyHalfLife > 0 {
+			score = "(-bm25(facts_fts) * " + usageFactorColumn + " / (1.0 + (julianday('now') - julianday(f.updated_at)) / ?))"
+			args = append(args, q.RecencyHalfLife.Hours()/24)
 		}
+		selectColumns = factColumns + ", " + score + " AS score, " +
+			fmt.Sprintf("snippet(facts_fts, 0, '%s', '%s', '%s', %d)", SnippetOpen, SnippetClose, SnippetEllipsis, snippetTokens)
+		orderBy = " ORDER BY score DESC, f.updated_at DESC"
 	}
 
-	baseQuery += " ORDER BY f.updated_at DESC"
+	from, whereArgs := keywordFrom(q)
+	args = append(args, whereArgs...)
 
-	// Apply limit bounds
-	if limit <= 0 {
-		limit = DefaultLimit
-	} else if limit > MaxLimit {
-		limit = MaxLimit
+	if q.PinnedFirst {
+		orderBy = strings.Replace(orderBy, " ORDER BY ", " ORDER BY f.pinned DESC, ", 1)
 	}
-	baseQuery += fmt.Sprintf(" LIMIT %d", limit)
+	// Break ties by ID so pages of the same query never overlap
+	orderBy += ", f.id DESC"
 
-	rows, err := s.db.Query(baseQuery, args...)
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+selectColumns+from+orderBy+fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset),
+		args...,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -192,68 +290,432 @@ func (s *SQLiteStore) GetFacts(query string, tags []string, sourceDir string, li
 	var facts []Fact
 	for rows.Next() {
 		var f Fact
-		var tagsJSON string
-		if err := rows.Scan(&f.ID, &f.Content, &tagsJSON, &f.SourceDir, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		if err := s.scanFact(rows, &f, &f.Score, &f.Snippet); err != nil {
 			return nil, err
 		}
-		if err := json.Unmarshal([]byte(tagsJSON), &f.Tags); err != nil {
-			// If tags are corrupted, initialize to empty slice
-			f.Tags = []string{}
-		}
 		facts = append(facts, f)
 	}
 
 	return facts, rows.Err()
 }
 
-func (s *SQLiteStore) GetFactByID(id int64) (*Fact, error) {
+// keywordFrom builds the FROM and WHERE clauses selecting the facts that
+// match q's full-text query, if any, and its filters
+func keywordFrom(q FactQuery) (string, []interface{}) {
+	from := " FROM facts f"
+	var conditions []string
+	var args []interface{}
+
+	if match := q.ftsMatch(); match != "" {
+		from += " JOIN facts_fts ON f.id = facts_fts.rowid"
+		conditions = append(conditions, "facts_fts MATCH ?")
+		args = append(args, match)
+	}
+
+	filters, filterArgs := filterConditions(q)
+	conditions = append(conditions, filters...)
+	args = append(args, filterArgs...)
+
+	return from + " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// filterConditions builds the SQL conditions for every filter of q other than
+// the full-text match. It always excludes expired facts, and superseded facts
+// unless q.IncludeSuperseded is set.
+func filterConditions(q FactQuery) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if q.SourceDir != "" {
+		cond, condArgs := dirCondition(q)
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+
+	if len(q.Kinds) > 0 {
+		conditions = append(conditions, "f.kind IN ("+placeholders(len(q.Kinds))+")")
+		for _, kind := range q.Kinds {
+			args = append(args, string(kind))
+		}
+	}
+
+	if q.Client != "" {
+		conditions = append(conditions, `f.client LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(q.Client)+"%")
+	}
+
+	for _, tag := range q.Tags {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM fact_tags t WHERE t.fact_id = f.id AND t.tag = ?)")
+		args = append(args, normalizeTag(tag))
+	}
+
+	for _, tag := range q.ExcludeTags {
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM fact_tags t WHERE t.fact_id = f.id AND t.tag = ?)")
+		args = append(args, normalizeTag(tag))
+	}
+
+	if q.exclude != "" {
+		conditions = append(conditions, "f.id NOT IN (SELECT rowid FROM facts_fts WHERE facts_fts MATCH ?)")
+		args = append(args, q.exclude)
+	}
+
+	if !q.IncludeSuperseded {
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM fact_links l WHERE l.to_id = f.id AND l.kind = ?)")
+		args = append(args, string(LinkSupersedes))
+	}
+
+	if !q.UnusedSince.IsZero() {
+		conditions = append(conditions, "f.created_at < ? AND (f.last_recalled_at IS NULL OR f.last_recalled_at < ?)")
+		args = append(args, q.UnusedSince, q.UnusedSince)
+	}
+
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "f.updated_at >= ?")
+		args = append(args, q.Since)
+	}
+
+	if !q.Before.IsZero() {
+		conditions = append(conditions, "f.updated_at < ?")
+		args = append(args, q.Before)
+	}
+
+	conditions = append(conditions, "(f.expires_at IS NULL OR f.expires_at > ?)")
+	args = append(args, time.Now())
+
+	return conditions, args
+}
+
+// clampLimit applies the default and maximum result limits
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+// dirCondition builds the SQL condition matching q.SourceDir according to
+// q.DirMode. With a RepoID and ScopeRoot it also matches facts recorded at the
+// same repo-relative paths in other checkouts of the repository.
+func dirCondition(q FactQuery) (string, []interface{}) {
+	var dirCond string
+	var args []interface{}
+
+	switch q.DirMode {
+	case DirDescendants:
+		dirCond = `(f.source_dir = ? OR f.source_dir LIKE ? ESCAPE '\')`
+		sep := string(filepath.Separator)
+		args = append(args, q.SourceDir, escapeLike(strings.TrimSuffix(q.SourceDir, sep))+sep+"%")
+	case DirAncestors:
+		dirs := AncestorDirs(q.SourceDir, q.ScopeRoot)
+		dirCond = "f.source_dir IN (" + placeholders(len(dirs)) + ")"
+		for _, dir := range dirs {
+			args = append(args, dir)
+		}
+	default:
+		dirCond = "f.source_dir = ?"
+		args = append(args, q.SourceDir)
+	}
+
+	if q.RepoID == "" || q.ScopeRoot == "" {
+		return dirCond, args
+	}
+	repoPath := gitrepo.RelPath(q.ScopeRoot, q.SourceDir)
+	if repoPath == "" {
+		return dirCond, args
+	}
+
+	var repoCond string
+	switch q.DirMode {
+	case DirDescendants:
+		if repoPath == "." {
+			repoCond = "f.repo_id = ?"
+			args = append(args, q.RepoID)
+		} else {
+			repoCond = `f.repo_id = ? AND (f.repo_path = ? OR f.repo_path LIKE ? ESCAPE '\')`
+			args = append(args, q.RepoID, repoPath, escapeLike(repoPath)+"/%")
+		}
+	case DirAncestors:
+		paths := repoAncestorPaths(repoPath)
+		repoCond = "f.repo_id = ? AND f.repo_path IN (" + placeholders(len(paths)) + ")"
+		args = append(args, q.RepoID)
+		for _, p := range paths {
+			args = append(args, p)
+		}
+	default:
+		repoCond = "f.repo_id = ? AND f.repo_path = ?"
+		args = append(args, q.RepoID, repoPath)
+	}
+
+	return "(" + dirCond + " OR (" + repoCond + "))", args
+}
+
+// repoAncestorPaths returns a repo-relative path followed by its parents,
+// ending with "."
+func repoAncestorPaths(repoPath string) []string {
+	paths := []string{repoPath}
+	for repoPath != "." {
+		repoPath = path.Dir(repoPath)
+		paths = append(paths, repoPath)
+	}
+	return paths
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// GetFactByID returns the fact with the given ID, or nil if there is none,
+// and records it as recalled
+func (s *SQLiteStore) GetFactByID(ctx context.Context, id int64) (*Fact, error) {
+	f, err := s.PeekFact(ctx, id)
+	if err != nil || f == nil {
+		return f, err
+	}
+	// Best effort: a fact that was found is returned even if its usage
+	// could not be recorded
+	_ = s.RecordRecalls(ctx, []int64{id})
+	return f, nil
+}
+
+// PeekFact returns the fact with the given ID, or nil, without recording a
+// recall. Use it for lookups that are not the fact being read, such as
+// checks before an edit or delete.
+func (s *SQLiteStore) PeekFact(ctx context.Context, id int64) (*Fact, error) {
 	var f Fact
-	var tagsJSON string
-	err := s.db.QueryRow(
-		"SELECT id, content, tags, source_dir, created_at, updated_at FROM facts WHERE id = ?",
-		id,
-	).Scan(&f.ID, &f.Content, &tagsJSON, &f.SourceDir, &f.CreatedAt, &f.UpdatedAt)
+	err := s.scanFact(s.db.QueryRowContext(ctx, "SELECT "+factColumns+" FROM facts f WHERE f.id = ?", id), &f)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(tagsJSON), &f.Tags); err != nil {
-		// If tags are corrupted, initialize to empty slice
-		f.Tags = []string{}
+	return &f, nil
+}
+
+// UpdateFact replaces the content and tags of an existing fact, archiving the
+// previous version in fact_revisions. An empty content or nil tags keeps the
+// current value. Returns nil if the fact does not exist.
+func (s *SQLiteStore) UpdateFact(ctx context.Context, id int64, content string, tags []string) (*Fact, error) {
+	return s.EditFact(ctx, id, FactEdit{Content: content, Tags: tags})
+}
+
+// EditFact applies edit to an existing fact in a single transaction,
+// archiving the previous content, tags, kind and details in fact_revisions.
+// Returns nil if the fact does not exist.
+func (s *SQLiteStore) EditFact(ctx context.Context, id int64, edit FactEdit) (*Fact, error) {
+	if edit.SetKind {
+		if err := edit.Details.Validate(edit.Kind); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var f Fact
+	err = s.scanFact(tx.QueryRowContext(ctx, "SELECT "+factColumns+" FROM facts f WHERE f.id = ?", id), &f)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.archiveRevision(ctx, tx, f); err != nil {
+		return nil, err
+	}
+
+	content := edit.Content
+	if content == "" {
+		content = f.Content
+	}
+	sealed, err := s.cipher.seal(content)
+	if err != nil {
+		return nil, err
+	}
+	kind, details := f.Kind, f.Details
+	if edit.SetKind {
+		kind, details = edit.Kind, edit.Details
+	}
+	sealedDetails, err := s.sealDetails(details)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE facts SET content = ?, kind = ?, details = ?, updated_at = ? WHERE id = ?",
+		sealed, string(kind), sealedDetails, now, id,
+	); err != nil {
+		return nil, err
+	}
+	if edit.Tags != nil {
+		tags := normalizeTags(edit.Tags)
+		if _, err := tx.ExecContext(ctx, "DELETE FROM fact_tags WHERE fact_id = ?", id); err != nil {
+			return nil, err
+		}
+		if err := insertTags(ctx, tx, id, tags); err != nil {
+			return nil, err
+		}
+		f.Tags = tags
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if content != f.Content {
+		_ = s.embedFact(ctx, id, content)
 	}
+
+	f.Content = content
+	f.Kind, f.Details = kind, details
+	f.UpdatedAt = now
 	return &f, nil
 }
 
-func (s *SQLiteStore) DeleteFact(id int64) error {
-	_, err := s.db.Exec("DELETE FROM facts WHERE id = ?", id)
+// archiveRevision stores the current version of f in fact_revisions
+func (s *SQLiteStore) archiveRevision(ctx context.Context, tx *sql.Tx, f Fact) error {
+	tagsJSON, err := json.Marshal(f.Tags)
+	if err != nil {
+		return err
+	}
+	sealed, err := s.cipher.seal(f.Content)
+	if err != nil {
+		return err
+	}
+	details, err := s.sealDetails(f.Details)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO fact_revisions (fact_id, content, tags, kind, details, created_at, replaced_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		f.ID, sealed, string(tagsJSON), string(f.Kind), details, f.UpdatedAt, time.Now(),
+	)
 	return err
 }
 
+// GetFactRevisions returns the archived versions of a fact, oldest first.
+func (s *SQLiteStore) GetFactRevisions(ctx context.Context, id int64) ([]FactRevision, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, fact_id, content, tags, kind, details, created_at, replaced_at FROM fact_revisions WHERE fact_id = ? ORDER BY id ASC",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var revisions []FactRevision
+	for rows.Next() {
+		var r FactRevision
+		var tagsJSON, kind string
+		var details sql.NullString
+		if err := rows.Scan(&r.ID, &r.FactID, &r.Content, &tagsJSON, &kind, &details, &r.CreatedAt, &r.ReplacedAt); err != nil {
+			return nil, err
+		}
+		r.Kind = FactKind(kind)
+		if r.Details, err = s.openDetails(details.String); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tagsJSON), &r.Tags); err != nil {
+			r.Tags = []string{}
+		}
+		if r.Content, err = s.cipher.open(r.Content); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteFact(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fact_revisions WHERE fact_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fact_tags WHERE fact_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM facts WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetPinned pins or unpins a fact without changing its content or update
+// time. Returns nil if the fact does not exist.
+func (s *SQLiteStore) SetPinned(ctx context.Context, id int64, pinned bool) (*Fact, error) {
+	result, err := s.db.ExecContext(ctx, "UPDATE facts SET pinned = ? WHERE id = ?", pinned, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+	return s.PeekFact(ctx, id)
+}
+
+// PurgeExpiredFacts deletes every fact whose expiry has passed, along with
+// its tags and revisions, and returns the number of facts deleted.
+func (s *SQLiteStore) PurgeExpiredFacts(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	expired := "SELECT id FROM facts WHERE expires_at IS NOT NULL AND expires_at <= ?"
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fact_revisions WHERE fact_id IN ("+expired+")", now); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fact_tags WHERE fact_id IN ("+expired+")", now); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM facts WHERE id IN ("+expired+")", now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
 // Instances
 
-func (s *SQLiteStore) RegisterInstance(id string, pid int, directory string) error {
+func (s *SQLiteStore) RegisterInstance(ctx context.Context, id string, pid int, directory string) error {
 	now := time.Now()
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		"INSERT OR REPLACE INTO instances (id, pid, directory, started_at, last_heartbeat) VALUES (?, ?, ?, ?, ?)",
 		id, pid, directory, now, now,
 	)
 	return err
 }
 
-func (s *SQLiteStore) Heartbeat(id string) error {
-	_, err := s.db.Exec("UPDATE instances SET last_heartbeat = ? WHERE id = ?", time.Now(), id)
+func (s *SQLiteStore) Heartbeat(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE instances SET last_heartbeat = ? WHERE id = ?", time.Now(), id)
 	return err
 }
 
-func (s *SQLiteStore) UnregisterInstance(id string) error {
-	_, err := s.db.Exec("DELETE FROM instances WHERE id = ?", id)
+func (s *SQLiteStore) UnregisterInstance(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM instances WHERE id = ?", id)
 	return err
 }
 
-func (s *SQLiteStore) GetInstances() ([]Instance, error) {
-	rows, err := s.db.Query("SELECT id, pid, directory, started_at, last_heartbeat FROM instances ORDER BY started_at DESC")
+func (s *SQLiteStore) GetInstances(ctx context.Context) ([]Instance, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, pid, directory, started_at, last_heartbeat FROM instances ORDER BY started_at DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -270,9 +732,9 @@ func (s *SQLiteStore) GetInstances() ([]Instance, error) {
 	return instances, rows.Err()
 }
 
-func (s *SQLiteStore) GetInstance(id string) (*Instance, error) {
+func (s *SQLiteStore) GetInstance(ctx context.Context, id string) (*Instance, error) {
 	var i Instance
-	err := s.db.QueryRow(
+	err := s.db.QueryRowContext(ctx,
 		"SELECT id, pid, directory, started_at, last_heartbeat FROM instances WHERE id = ?",
 		id,
 	).Scan(&i.ID, &i.PID, &i.Directory, &i.StartedAt, &i.LastHeartbeat)
@@ -285,19 +747,24 @@ func (s *SQLiteStore) GetInstance(id string) (*Instance, error) {
 	return &i, nil
 }
 
-func (s *SQLiteStore) CleanupStaleInstances(maxAge time.Duration) error {
+func (s *SQLiteStore) CleanupStaleInstances(ctx context.Context, maxAge time.Duration) error {
 	cutoff := time.Now().Add(-maxAge)
-	_, err := s.db.Exec("DELETE FROM instances WHERE last_heartbeat < ?", cutoff)
+	_, err := s.db.ExecContext(ctx, "DELETE FROM instances WHERE last_heartbeat < ?", cutoff)
 	return err
 }
 
 // Messages
 
-func (s *SQLiteStore) SendMessage(from, to, content string) (*Message, error) {
+func (s *SQLiteStore) SendMessage(ctx context.Context, from, to, content string) (*Message, error) {
+	sealed, err := s.cipher.seal(content)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
-	result, err := s.db.Exec(
+	result, err := s.db.ExecContext(ctx,
 		"INSERT INTO messages (from_instance, to_instance, content, created_at) VALUES (?, ?, ?, ?)",
-		from, to, content, now,
+		from, to, sealed, now,
 	)
 	if err != nil {
 		return nil, err
@@ -317,36 +784,54 @@ func (s *SQLiteStore) SendMessage(from, to, content string) (*Message, error) {
 	}, nil
 }
 
-func (s *SQLiteStore) GetMessages(toInstance string, unreadOnly bool) ([]Message, error) {
+func (s *SQLiteStore) GetMessages(ctx context.Context, toInstance string, unreadOnly bool) ([]Message, error) {
 	query := "SELECT id, from_instance, to_instance, content, created_at, read_at FROM messages WHERE to_instance = ?"
 	if unreadOnly {
 		query += " AND read_at IS NULL"
 	}
 	query += " ORDER BY created_at ASC"
 
-	rows, err := s.db.Query(query, toInstance)
+	var messages []Message
+	err := s.queryMessages(ctx, func(m Message) error {
+		messages = append(messages, m)
+		return nil
+	}, query, toInstance)
+	return messages, err
+}
+
+// ExportMessages calls fn for every message, oldest first
+func (s *SQLiteStore) ExportMessages(ctx context.Context, fn func(Message) error) error {
+	return s.queryMessages(ctx, fn, "SELECT id, from_instance, to_instance, content, created_at, read_at FROM messages ORDER BY id")
+}
+
+func (s *SQLiteStore) queryMessages(ctx context.Context, fn func(Message) error, query string, args ...interface{}) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer func() { _ = rows.Close() }()
 
-	var messages []Message
 	for rows.Next() {
 		var m Message
 		var readAt sql.NullTime
 		if err := rows.Scan(&m.ID, &m.FromInstance, &m.ToInstance, &m.Content, &m.CreatedAt, &readAt); err != nil {
-			return nil, err
+			return err
 		}
 		if readAt.Valid {
 			m.ReadAt = &readAt.Time
 		}
-		messages = append(messages, m)
+		if m.Content, err = s.cipher.open(m.Content); err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
 	}
-	return messages, rows.Err()
+	return rows.Err()
 }
 
-func (s *SQLiteStore) MarkMessageRead(id int64) error {
-	_, err := s.db.Exec("UPDATE messages SET read_at = ? WHERE id = ?", time.Now(), id)
+func (s *SQLiteStore) MarkMessageRead(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE messages SET read_at = ? WHERE id = ?", time.Now(), id)
 	return err
 }
 
@@ -1,8 +1,11 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -28,10 +31,11 @@ func setupTestStore(t *testing.T) (*SQLiteStore, func()) {
 // Fact tests
 
 func TestAddFact(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	fact, err := store.AddFact("test fact content", nil, "/test/dir")
+	fact, err := store.AddFact(ctx, "test fact content", nil, "/test/dir")
 	if err != nil {
 		t.Fatalf("AddFact failed: %v", err)
 	}
@@ -50,11 +54,12 @@ func TestAddFact(t *testing.T) {
 }
 
 func TestAddFact_WithTags(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
 	tags := []string{"architecture", "decision"}
-	fact, err := store.AddFact("use sqlite for persistence", tags, "/project")
+	fact, err := store.AddFact(ctx, "use sqlite for persistence", tags, "/project")
 	if err != nil {
 		t.Fatalf("AddFact failed: %v", err)
 	}
@@ -67,14 +72,15 @@ func TestAddFact_WithTags(t *testing.T) {
 }
 
 func TestGetFacts_ByQuery(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	_, _ = store.AddFact("golang is great for CLI tools", nil, "/project1")
-	_, _ = store.AddFact("python is great for scripts", nil, "/project2")
-	_, _ = store.AddFact("rust has great memory safety", nil, "/project3")
+	_, _ = store.AddFact(ctx, "golang is great for CLI tools", nil, "/project1")
+	_, _ = store.AddFact(ctx, "python is great for scripts", nil, "/project2")
+	_, _ = store.AddFact(ctx, "rust has great memory safety", nil, "/project3")
 
-	facts, err := store.GetFacts("golang", nil, "", 10)
+	facts, err := store.GetFacts(ctx, "golang", nil, "", 10)
 	if err != nil {
 		t.Fatalf("GetFacts failed: %v", err)
 	}
@@ -87,14 +93,15 @@ func TestGetFacts_ByQuery(t *testing.T) {
 }
 
 func TestGetFacts_ByDirectory(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	_, _ = store.AddFact("fact in project1", nil, "/project1")
-	_, _ = store.AddFact("fact in project2", nil, "/project2")
-	_, _ = store.AddFact("another fact in project1", nil, "/project1")
+	_, _ = store.AddFact(ctx, "fact in project1", nil, "/project1")
+	_, _ = store.AddFact(ctx, "fact in project2", nil, "/project2")
+	_, _ = store.AddFact(ctx, "another fact in project1", nil, "/project1")
 
-	facts, err := store.GetFacts("", nil, "/project1", 10)
+	facts, err := store.GetFacts(ctx, "", nil, "/project1", 10)
 	if err != nil {
 		t.Fatalf("GetFacts failed: %v", err)
 	}
@@ -103,15 +110,79 @@ func TestGetFacts_ByDirectory(t *testing.T) {
 	}
 }
 
+func TestAncestorDirs(t *testing.T) {
+	tests := []struct {
+		dir      string
+		root     string
+		expected []string
+	}{
+		{"/src/app/services/api", "/src/app", []string{"/src/app/services/api", "/src/app/services", "/src/app"}},
+		{"/src/app", "/src/app", []string{"/src/app"}},
+		{"/src/app/api", "", []string{"/src/app/api"}},
+		{"/elsewhere", "/src/app", []string{"/elsewhere"}},
+		{"/src/application", "/src/app", []string{"/src/application"}},
+	}
+
+	for _, tt := range tests {
+		dirs := AncestorDirs(tt.dir, tt.root)
+		if strings.Join(dirs, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("AncestorDirs(%q, %q) = %v, want %v", tt.dir, tt.root, dirs, tt.expected)
+		}
+	}
+}
+
+func TestSearchFacts_DirModes(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	root, _ := store.AddFact(ctx, "repo root fact", nil, "/src/app")
+	mid, _ := store.AddFact(ctx, "services fact", nil, "/src/app/services")
+	leaf, _ := store.AddFact(ctx, "api fact", nil, "/src/app/services/api")
+	_, _ = store.AddFact(ctx, "sibling fact", nil, "/src/app/web")
+	_, _ = store.AddFact(ctx, "outside fact", nil, "/src")
+	_, _ = store.AddFact(ctx, "wildcard lookalike", nil, "/src/appX/services")
+
+	ids := func(facts []Fact) map[int64]bool {
+		m := make(map[int64]bool)
+		for _, f := range facts {
+			m[f.ID] = true
+		}
+		return m
+	}
+
+	facts, err := store.SearchFacts(ctx, FactQuery{SourceDir: "/src/app/services/api", DirMode: DirAncestors, ScopeRoot: "/src/app"})
+	if err != nil {
+		t.Fatalf("SearchFacts failed: %v", err)
+	}
+	got := ids(facts)
+	if len(got) != 3 || !got[root.ID] || !got[mid.ID] || !got[leaf.ID] {
+		t.Errorf("expected leaf and its ancestors up to the root, got %v", facts)
+	}
+
+	facts, _ = store.SearchFacts(ctx, FactQuery{SourceDir: "/src/app/services", DirMode: DirDescendants})
+	got = ids(facts)
+	if len(got) != 2 || !got[mid.ID] || !got[leaf.ID] {
+		t.Errorf("expected services and below, got %v", facts)
+	}
+
+	_, _ = store.AddFact(ctx, "underscore dir", nil, "/src/a_p")
+	facts, _ = store.SearchFacts(ctx, FactQuery{SourceDir: "/src/a_p", DirMode: DirDescendants})
+	if len(facts) != 1 {
+		t.Errorf("expected LIKE wildcards in the directory to be escaped, got %v", facts)
+	}
+}
+
 func TestGetFacts_ByTags(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	_, _ = store.AddFact("architectural decision", []string{"architecture"}, "/project")
-	_, _ = store.AddFact("bug fix note", []string{"bugfix"}, "/project")
-	_, _ = store.AddFact("another arch note", []string{"architecture", "important"}, "/project")
+	_, _ = store.AddFact(ctx, "architectural decision", []string{"architecture"}, "/project")
+	_, _ = store.AddFact(ctx, "bug fix note", []string{"bugfix"}, "/project")
+	_, _ = store.AddFact(ctx, "another arch note", []string{"architecture", "important"}, "/project")
 
-	facts, err := store.GetFacts("", []string{"architecture"}, "", 10)
+	facts, err := store.GetFacts(ctx, "", []string{"architecture"}, "", 10)
 	if err != nil {
 		t.Fatalf("GetFacts failed: %v", err)
 	}
@@ -121,14 +192,15 @@ func TestGetFacts_ByTags(t *testing.T) {
 }
 
 func TestGetFacts_FTSSpecialChars(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	_, _ = store.AddFact("normal fact", nil, "/project")
-	_, _ = store.AddFact("fact with OR keyword", nil, "/project")
+	_, _ = store.AddFact(ctx, "normal fact", nil, "/project")
+	_, _ = store.AddFact(ctx, "fact with OR keyword", nil, "/project")
 
 	// Query with FTS operator should be safely escaped
-	facts, err := store.GetFacts("OR", nil, "", 10)
+	facts, err := store.GetFacts(ctx, "OR", nil, "", 10)
 	if err != nil {
 		t.Fatalf("GetFacts with special chars failed: %v", err)
 	}
@@ -138,24 +210,143 @@ func TestGetFacts_FTSSpecialChars(t *testing.T) {
 	}
 
 	// Test with quotes
-	_, _ = store.AddFact(`fact with "quotes"`, nil, "/project")
-	_, err = store.GetFacts(`"quotes"`, nil, "", 10)
+	_, _ = store.AddFact(ctx, `fact with "quotes"`, nil, "/project")
+	_, err = store.GetFacts(ctx, `"quotes"`, nil, "", 10)
 	if err != nil {
 		t.Fatalf("GetFacts with quotes failed: %v", err)
 	}
 }
 
+func TestSearchFacts_RankedByRelevance(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	best, _ := store.AddFact(ctx, "sqlite migration: sqlite schema changes need a sqlite backup", nil, "/project")
+	_, _ = store.AddFact(ctx, "the frontend talks to an API that is backed by a database, sometimes sqlite, in tests", nil, "/project")
+	// Newer but weaker match must not outrank the best match
+	time.Sleep(10 * time.Millisecond)
+	_, _ = store.AddFact(ctx, "unrelated notes mentioning sqlite once among many many other words about deployment", nil, "/project")
+
+	facts, err := store.SearchFacts(ctx, FactQuery{Query: "sqlite", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchFacts failed: %v", err)
+	}
+	if len(facts) != 3 {
+		t.Fatalf("expected 3 facts, got %d", len(facts))
+	}
+	if facts[0].ID != best.ID {
+		t.Errorf("expected best match #%d first, got #%d", best.ID, facts[0].ID)
+	}
+	for i := 1; i < len(facts); i++ {
+		if facts[i].Score > facts[i-1].Score {
+			t.Errorf("results not sorted by score: %v > %v", facts[i].Score, facts[i-1].Score)
+		}
+	}
+	if facts[0].Score <= 0 {
+		t.Errorf("expected positive relevance score, got %v", facts[0].Score)
+	}
+	if !strings.Contains(facts[0].Snippet, SnippetOpen+"sqlite"+SnippetClose) {
+		t.Errorf("expected highlighted snippet, got %q", facts[0].Snippet)
+	}
+}
+
+func TestSearchFacts_RecencyBlend(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	old, _ := store.AddFact(ctx, "deploy deploy deploy with argo", nil, "/project")
+	recent, _ := store.AddFact(ctx, "deploy with argo and then check the dashboards", nil, "/project")
+	_, _ = store.db.Exec("UPDATE facts SET updated_at = ? WHERE id = ?", time.Now().Add(-365*24*time.Hour), old.ID)
+
+	facts, _ := store.SearchFacts(ctx, FactQuery{Query: "deploy", Limit: 10})
+	if len(facts) != 2 || facts[0].ID != old.ID {
+		t.Fatalf("expected stronger match first without recency, got %v", facts)
+	}
+
+	facts, err := store.SearchFacts(ctx, FactQuery{Query: "deploy", Limit: 10, RecencyHalfLife: 7 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("SearchFacts failed: %v", err)
+	}
+	if len(facts) != 2 || facts[0].ID != recent.ID {
+		t.Errorf("expected recent fact first with recency blending, got %v", facts)
+	}
+}
+
+func TestSearchFacts_NoQueryHasNoSnippet(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(ctx, "plain fact", nil, "/project")
+
+	facts, err := store.SearchFacts(ctx, FactQuery{})
+	if err != nil {
+		t.Fatalf("SearchFacts failed: %v", err)
+	}
+	if len(facts) != 1 || facts[0].Snippet != "" || facts[0].Score != 0 {
+		t.Errorf("expected unscored fact without snippet, got %+v", facts)
+	}
+}
+
+func TestSearchFacts_Cancelled(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(context.Background(), "a fact", nil, "/project")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.SearchFacts(ctx, FactQuery{Query: "fact"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from SearchFacts, got %v", err)
+	}
+	if _, err := store.AddFact(ctx, "another fact", nil, "/project"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from AddFact, got %v", err)
+	}
+}
+
+func TestSearchFacts_Client(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	codex, _ := store.InsertFact(ctx, Fact{
+		Content: "from codex", SourceDir: "/project",
+		Client: "codex-mcp-client", ClientVersion: "0.4.0", InstanceID: "instance-1", CommitSHA: "0123456789abcdef",
+	})
+	_, _ = store.InsertFact(ctx, Fact{Content: "from the cli", SourceDir: "/project", Client: CLIClient})
+	_, _ = store.AddFact(ctx, "from before provenance", nil, "/project")
+
+	got, _ := store.PeekFact(ctx, codex.ID)
+	if got.Client != "codex-mcp-client" || got.ClientVersion != "0.4.0" || got.InstanceID != "instance-1" || got.CommitSHA != "0123456789abcdef" {
+		t.Errorf("expected provenance to be stored, got %+v", got)
+	}
+	if p := got.Provenance(); p != "codex-mcp-client 0.4.0, instance instance-1, commit 0123456" {
+		t.Errorf("unexpected provenance %q", p)
+	}
+
+	facts, _ := store.SearchFacts(ctx, FactQuery{Client: "Codex"})
+	if len(facts) != 1 || facts[0].ID != codex.ID {
+		t.Errorf("expected a case-insensitive prefix match on the client, got %v", facts)
+	}
+	if facts, _ := store.SearchFacts(ctx, FactQuery{Client: "%"}); len(facts) != 0 {
+		t.Errorf("expected wildcards to match literally, got %v", facts)
+	}
+}
+
 func TestGetFacts_LimitBounds(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
 	// Add many facts
 	for i := 0; i < 150; i++ {
-		_, _ = store.AddFact("test fact", nil, "/project")
+		_, _ = store.AddFact(ctx, "test fact", nil, "/project")
 	}
 
 	// Default limit when 0
-	facts, err := store.GetFacts("", nil, "", 0)
+	facts, err := store.GetFacts(ctx, "", nil, "", 0)
 	if err != nil {
 		t.Fatalf("GetFacts failed: %v", err)
 	}
@@ -164,7 +355,7 @@ func TestGetFacts_LimitBounds(t *testing.T) {
 	}
 
 	// Explicit limit
-	facts, err = store.GetFacts("", nil, "", 50)
+	facts, err = store.GetFacts(ctx, "", nil, "", 50)
 	if err != nil {
 		t.Fatalf("GetFacts failed: %v", err)
 	}
@@ -173,7 +364,7 @@ func TestGetFacts_LimitBounds(t *testing.T) {
 	}
 
 	// Max limit cap
-	facts, err = store.GetFacts("", nil, "", 5000)
+	facts, err = store.GetFacts(ctx, "", nil, "", 5000)
 	if err != nil {
 		t.Fatalf("GetFacts failed: %v", err)
 	}
@@ -183,11 +374,12 @@ func TestGetFacts_LimitBounds(t *testing.T) {
 }
 
 func TestGetFactByID(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	created, _ := store.AddFact("findable fact", []string{"test"}, "/dir")
-	found, err := store.GetFactByID(created.ID)
+	created, _ := store.AddFact(ctx, "findable fact", []string{"test"}, "/dir")
+	found, err := store.GetFactByID(ctx, created.ID)
 	if err != nil {
 		t.Fatalf("GetFactByID failed: %v", err)
 	}
@@ -199,7 +391,7 @@ func TestGetFactByID(t *testing.T) {
 	}
 
 	// Non-existent ID
-	notFound, err := store.GetFactByID(99999)
+	notFound, err := store.GetFactByID(ctx, 99999)
 	if err != nil {
 		t.Fatalf("GetFactByID failed: %v", err)
 	}
@@ -209,35 +401,213 @@ func TestGetFactByID(t *testing.T) {
 }
 
 func TestDeleteFact(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	fact, _ := store.AddFact("to be deleted", nil, "/dir")
-	err := store.DeleteFact(fact.ID)
+	fact, _ := store.AddFact(ctx, "to be deleted", nil, "/dir")
+	err := store.DeleteFact(ctx, fact.ID)
 	if err != nil {
 		t.Fatalf("DeleteFact failed: %v", err)
 	}
 
-	found, _ := store.GetFactByID(fact.ID)
+	found, _ := store.GetFactByID(ctx, fact.ID)
 	if found != nil {
 		t.Error("expected fact to be deleted")
 	}
 }
 
+func TestUpdateFact(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	original, _ := store.AddFact(ctx, "we use redis for caching", []string{"arch"}, "/project")
+
+	updated, err := store.UpdateFact(ctx, original.ID, "we use memcached for caching", nil)
+	if err != nil {
+		t.Fatalf("UpdateFact failed: %v", err)
+	}
+	if updated.ID != original.ID {
+		t.Errorf("expected ID %d to be preserved, got %d", original.ID, updated.ID)
+	}
+	if updated.Content != "we use memcached for caching" {
+		t.Errorf("unexpected content: %s", updated.Content)
+	}
+	if len(updated.Tags) != 1 || updated.Tags[0] != "arch" {
+		t.Errorf("expected tags to be kept, got %v", updated.Tags)
+	}
+	if !updated.CreatedAt.Equal(original.CreatedAt) {
+		t.Error("expected created_at to be preserved")
+	}
+
+	// Search index follows the new content
+	facts, _ := store.GetFacts(ctx, "memcached", nil, "", 10)
+	if len(facts) != 1 {
+		t.Errorf("expected updated content to be searchable, got %d results", len(facts))
+	}
+	facts, _ = store.GetFacts(ctx, "redis", nil, "", 10)
+	if len(facts) != 0 {
+		t.Errorf("expected old content to leave the index, got %d results", len(facts))
+	}
+
+	// Tags only
+	retagged, err := store.UpdateFact(ctx, original.ID, "", []string{"cache"})
+	if err != nil {
+		t.Fatalf("UpdateFact failed: %v", err)
+	}
+	if retagged.Content != "we use memcached for caching" {
+		t.Errorf("expected content to be kept, got %s", retagged.Content)
+	}
+	if len(retagged.Tags) != 1 || retagged.Tags[0] != "cache" {
+		t.Errorf("unexpected tags: %v", retagged.Tags)
+	}
+
+	// Non-existent ID
+	missing, err := store.UpdateFact(ctx, 99999, "nothing", nil)
+	if err != nil {
+		t.Fatalf("UpdateFact failed: %v", err)
+	}
+	if missing != nil {
+		t.Error("expected nil for non-existent ID")
+	}
+}
+
+func TestGetFactRevisions(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	fact, _ := store.AddFact(ctx, "version one", []string{"v1"}, "/project")
+	_, _ = store.UpdateFact(ctx, fact.ID, "version two", []string{"v2"})
+	_, _ = store.UpdateFact(ctx, fact.ID, "version three", nil)
+
+	revisions, err := store.GetFactRevisions(ctx, fact.ID)
+	if err != nil {
+		t.Fatalf("GetFactRevisions failed: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Content != "version one" || revisions[1].Content != "version two" {
+		t.Errorf("unexpected revision order: %q, %q", revisions[0].Content, revisions[1].Content)
+	}
+	if len(revisions[0].Tags) != 1 || revisions[0].Tags[0] != "v1" {
+		t.Errorf("unexpected revision tags: %v", revisions[0].Tags)
+	}
+	if revisions[0].ReplacedAt.Before(revisions[0].CreatedAt) {
+		t.Error("expected replaced_at to be after created_at")
+	}
+
+	// Deleting the fact drops its history
+	_ = store.DeleteFact(ctx, fact.ID)
+	revisions, _ = store.GetFactRevisions(ctx, fact.ID)
+	if len(revisions) != 0 {
+		t.Errorf("expected revisions to be deleted, got %d", len(revisions))
+	}
+}
+
 // Instance tests
 
+func TestSetPinned(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	old, _ := store.AddFact(ctx, "old convention", nil, "/project")
+	_, _ = store.db.Exec("UPDATE facts SET updated_at = ? WHERE id = ?", time.Now().Add(-90*24*time.Hour), old.ID)
+	_, _ = store.AddFact(ctx, "newer fact", nil, "/project")
+	_, _ = store.AddFact(ctx, "newest fact", nil, "/project")
+
+	pinned, err := store.SetPinned(ctx, old.ID, true)
+	if err != nil {
+		t.Fatalf("SetPinned failed: %v", err)
+	}
+	if pinned == nil || !pinned.Pinned {
+		t.Fatal("expected pinned fact")
+	}
+	if pinned.UpdatedAt.After(time.Now().Add(-time.Hour)) {
+		t.Error("expected pinning not to touch updated_at")
+	}
+
+	facts, _ := store.SearchFacts(ctx, FactQuery{SourceDir: "/project", Limit: 1})
+	if facts[0].ID == old.ID {
+		t.Error("expected recency ordering without PinnedFirst")
+	}
+	facts, _ = store.SearchFacts(ctx, FactQuery{SourceDir: "/project", Limit: 1, PinnedFirst: true})
+	if facts[0].ID != old.ID {
+		t.Errorf("expected pinned fact first, got #%d", facts[0].ID)
+	}
+
+	unpinned, _ := store.SetPinned(ctx, old.ID, false)
+	if unpinned.Pinned {
+		t.Error("expected fact to be unpinned")
+	}
+
+	missing, err := store.SetPinned(ctx, 9999, true)
+	if err != nil || missing != nil {
+		t.Errorf("expected nil for missing fact, got %v, %v", missing, err)
+	}
+}
+
+func TestExpiredFacts(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	expired, _ := store.InsertFact(ctx, Fact{Content: "staging is down", Tags: []string{"ops"}, SourceDir: "/project", ExpiresAt: &past})
+	current, _ := store.InsertFact(ctx, Fact{Content: "staging is slow", SourceDir: "/project", ExpiresAt: &future})
+	permanent, _ := store.AddFact(ctx, "staging runs on k8s", nil, "/project")
+
+	facts, err := store.GetFacts(ctx, "staging", nil, "/project", 10)
+	if err != nil {
+		t.Fatalf("GetFacts failed: %v", err)
+	}
+	if len(facts) != 2 {
+		t.Fatalf("expected expired fact to be hidden, got %d facts", len(facts))
+	}
+	for _, f := range facts {
+		if f.ID == expired.ID {
+			t.Error("expected expired fact to be hidden")
+		}
+		if f.ID == current.ID && (f.ExpiresAt == nil || !f.ExpiresAt.Equal(future)) {
+			t.Errorf("unexpected expiry: %v", f.ExpiresAt)
+		}
+	}
+
+	n, err := store.PurgeExpiredFacts(ctx)
+	if err != nil {
+		t.Fatalf("PurgeExpiredFacts failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 purged fact, got %d", n)
+	}
+	if f, _ := store.GetFactByID(ctx, expired.ID); f != nil {
+		t.Error("expected expired fact to be deleted")
+	}
+	if f, _ := store.GetFactByID(ctx, permanent.ID); f == nil || f.ExpiresAt != nil {
+		t.Error("expected permanent fact to be kept without an expiry")
+	}
+	if tags, _ := store.ListTags(ctx); len(tags) != 0 {
+		t.Errorf("expected tags of purged facts to be removed, got %v", tags)
+	}
+}
+
 func TestInstance_Lifecycle(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
 	// Register
-	err := store.RegisterInstance("test-instance-id", 12345, "/test/dir")
+	err := store.RegisterInstance(ctx, "test-instance-id", 12345, "/test/dir")
 	if err != nil {
 		t.Fatalf("RegisterInstance failed: %v", err)
 	}
 
 	// Get
-	inst, err := store.GetInstance("test-instance-id")
+	inst, err := store.GetInstance(ctx, "test-instance-id")
 	if err != nil {
 		t.Fatalf("GetInstance failed: %v", err)
 	}
@@ -253,17 +623,17 @@ func TestInstance_Lifecycle(t *testing.T) {
 
 	// Heartbeat
 	time.Sleep(10 * time.Millisecond)
-	err = store.Heartbeat("test-instance-id")
+	err = store.Heartbeat(ctx, "test-instance-id")
 	if err != nil {
 		t.Fatalf("Heartbeat failed: %v", err)
 	}
-	updated, _ := store.GetInstance("test-instance-id")
+	updated, _ := store.GetInstance(ctx, "test-instance-id")
 	if !updated.LastHeartbeat.After(inst.LastHeartbeat) {
 		t.Error("expected heartbeat to update timestamp")
 	}
 
 	// List
-	instances, err := store.GetInstances()
+	instances, err := store.GetInstances(ctx)
 	if err != nil {
 		t.Fatalf("GetInstances failed: %v", err)
 	}
@@ -272,38 +642,39 @@ func TestInstance_Lifecycle(t *testing.T) {
 	}
 
 	// Unregister
-	err = store.UnregisterInstance("test-instance-id")
+	err = store.UnregisterInstance(ctx, "test-instance-id")
 	if err != nil {
 		t.Fatalf("UnregisterInstance failed: %v", err)
 	}
-	gone, _ := store.GetInstance("test-instance-id")
+	gone, _ := store.GetInstance(ctx, "test-instance-id")
 	if gone != nil {
 		t.Error("expected instance to be unregistered")
 	}
 }
 
 func TestInstance_Cleanup(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	_ = store.RegisterInstance("old-instance", 111, "/old")
-	_ = store.RegisterInstance("new-instance", 222, "/new")
+	_ = store.RegisterInstance(ctx, "old-instance", 111, "/old")
+	_ = store.RegisterInstance(ctx, "new-instance", 222, "/new")
 
 	// Manually make one instance stale by setting last_heartbeat to 10 minutes ago
 	staleTime := time.Now().Add(-10 * time.Minute)
 	_, _ = store.db.Exec("UPDATE instances SET last_heartbeat = ? WHERE id = 'old-instance'", staleTime)
 
-	err := store.CleanupStaleInstances(5 * time.Minute)
+	err := store.CleanupStaleInstances(ctx, 5*time.Minute)
 	if err != nil {
 		t.Fatalf("CleanupStaleInstances failed: %v", err)
 	}
 
-	old, _ := store.GetInstance("old-instance")
+	old, _ := store.GetInstance(ctx, "old-instance")
 	if old != nil {
 		t.Error("expected old instance to be cleaned up")
 	}
 
-	newInst, _ := store.GetInstance("new-instance")
+	newInst, _ := store.GetInstance(ctx, "new-instance")
 	if newInst == nil {
 		t.Error("expected new instance to remain")
 	}
@@ -312,15 +683,16 @@ func TestInstance_Cleanup(t *testing.T) {
 // Message tests
 
 func TestMessage_SendAndReceive(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
 	// Setup instances
-	_ = store.RegisterInstance("sender", 1, "/sender")
-	_ = store.RegisterInstance("receiver", 2, "/receiver")
+	_ = store.RegisterInstance(ctx, "sender", 1, "/sender")
+	_ = store.RegisterInstance(ctx, "receiver", 2, "/receiver")
 
 	// Send message
-	msg, err := store.SendMessage("sender", "receiver", "hello!")
+	msg, err := store.SendMessage(ctx, "sender", "receiver", "hello!")
 	if err != nil {
 		t.Fatalf("SendMessage failed: %v", err)
 	}
@@ -332,7 +704,7 @@ func TestMessage_SendAndReceive(t *testing.T) {
 	}
 
 	// Receive unread only
-	messages, err := store.GetMessages("receiver", true)
+	messages, err := store.GetMessages(ctx, "receiver", true)
 	if err != nil {
 		t.Fatalf("GetMessages failed: %v", err)
 	}
@@ -345,27 +717,28 @@ func TestMessage_SendAndReceive(t *testing.T) {
 }
 
 func TestMessage_MarkRead(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	_ = store.RegisterInstance("sender", 1, "/sender")
-	_ = store.RegisterInstance("receiver", 2, "/receiver")
+	_ = store.RegisterInstance(ctx, "sender", 1, "/sender")
+	_ = store.RegisterInstance(ctx, "receiver", 2, "/receiver")
 
-	msg, _ := store.SendMessage("sender", "receiver", "test message")
+	msg, _ := store.SendMessage(ctx, "sender", "receiver", "test message")
 
-	err := store.MarkMessageRead(msg.ID)
+	err := store.MarkMessageRead(ctx, msg.ID)
 	if err != nil {
 		t.Fatalf("MarkMessageRead failed: %v", err)
 	}
 
 	// Should not appear in unread
-	unread, _ := store.GetMessages("receiver", true)
+	unread, _ := store.GetMessages(ctx, "receiver", true)
 	if len(unread) != 0 {
 		t.Errorf("expected 0 unread messages, got %d", len(unread))
 	}
 
 	// Should appear in all messages
-	all, _ := store.GetMessages("receiver", false)
+	all, _ := store.GetMessages(ctx, "receiver", false)
 	if len(all) != 1 {
 		t.Errorf("expected 1 total message, got %d", len(all))
 	}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AgeBucket is a range of fact ages, by time since the fact was last updated
+type AgeBucket struct {
+	Label string
+	// MaxAge is the exclusive upper bound of the range; zero means no bound
+	MaxAge time.Duration
+}
+
+// AgeBuckets are the age ranges Stats counts facts in, youngest first
+var AgeBuckets = []AgeBucket{
+	{Label: "under a day", MaxAge: 24 * time.Hour},
+	{Label: "1-7 days", MaxAge: 7 * 24 * time.Hour},
+	{Label: "1-4 weeks", MaxAge: 30 * 24 * time.Hour},
+	{Label: "1-3 months", MaxAge: 90 * 24 * time.Hour},
+	{Label: "older"},
+}
+
+// DirCount is the number of facts stored from a directory
+type DirCount struct {
+	Dir   string `json:"dir"`
+	Count int    `json:"count"`
+}
+
+// AgeCount is the number of facts in an age bucket
+type AgeCount struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// FactStats summarizes the facts matching a query
+type FactStats struct {
+	Total  int `json:"total"`
+	Pinned int `json:"pinned"`
+	// Dirs and Tags are ordered by count, highest first
+	Dirs []DirCount `json:"dirs,omitempty"`
+	Tags []TagCount `json:"tags,omitempty"`
+	// Ages has one entry per AgeBuckets entry, in the same order
+	Ages []AgeCount `json:"ages"`
+	// MostRecalled holds the most recalled facts, and NeverRecalled the
+	// oldest of the NeverRecalledCount facts that were never recalled. Each
+	// lists up to StatsTopFacts facts.
+	MostRecalled       []Fact `json:"most_recalled,omitempty"`
+	NeverRecalled      []Fact `json:"never_recalled,omitempty"`
+	NeverRecalledCount int    `json:"never_recalled_count"`
+}
+
+// StatsTopFacts is the number of facts Stats lists as most and never recalled
+const StatsTopFacts = 5
+
+// Stats counts the facts matching q in total and per directory, tag and age
+// bucket. q.Limit and q.Cursor are ignored.
+func (s *SQLiteStore) Stats(ctx context.Context, q FactQuery) (*FactStats, error) {
+	if err := s.syncSearchIndex(ctx); err != nil {
+		return nil, err
+	}
+	from, args := keywordFrom(q)
+	stats := &FactStats{}
+
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(SUM(f.pinned), 0), COALESCE(SUM(f.recall_count = 0), 0)"+from, args...,
+	).Scan(&stats.Total, &stats.Pinned, &stats.NeverRecalledCount); err != nil {
+		return nil, err
+	}
+	var err error
+	if stats.MostRecalled, err = s.queryFacts(ctx,
+		"SELECT "+factColumns+from+fmt.Sprintf(" AND f.recall_count > 0 ORDER BY f.recall_count DESC, f.last_recalled_at DESC LIMIT %d", StatsTopFacts), args...,
+	); err != nil {
+		return nil, err
+	}
+	if stats.NeverRecalled, err = s.queryFacts(ctx,
+		"SELECT "+factColumns+from+fmt.Sprintf(" AND f.recall_count = 0 ORDER BY f.created_at, f.id LIMIT %d", StatsTopFacts), args...,
+	); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT f.source_dir, COUNT(*) AS n"+from+" GROUP BY f.source_dir ORDER BY n DESC, f.source_dir", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d DirCount
+		if err := rows.Scan(&d.Dir, &d.Count); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		stats.Dirs = append(stats.Dirs, d)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx,
+		"SELECT ft.tag, COUNT(*) AS n FROM fact_tags ft WHERE ft.fact_id IN (SELECT f.id"+from+") GROUP BY ft.tag ORDER BY n DESC, ft.tag",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		stats.Tags = append(stats.Tags, t)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, b := range AgeBuckets {
+		stats.Ages = append(stats.Ages, AgeCount{Label: b.Label})
+	}
+	rows, err = s.db.QueryContext(ctx, "SELECT f.updated_at"+from, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var updatedAt time.Time
+		if err := rows.Scan(&updatedAt); err != nil {
+			return nil, err
+		}
+		stats.Ages[ageBucket(now.Sub(updatedAt))].Count++
+	}
+	return stats, rows.Err()
+}
+
+// ageBucket returns the index of the AgeBuckets entry age falls in
+func ageBucket(age time.Duration) int {
+	for i, b := range AgeBuckets {
+		if b.MaxAge == 0 || age < b.MaxAge {
+			return i
+		}
+	}
+	return len(AgeBuckets) - 1
+}
+
+// queryFacts returns the facts selected by a query of factColumns
+func (s *SQLiteStore) queryFacts(ctx context.Context, query string, args ...interface{}) ([]Fact, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var facts []Fact
+	for rows.Next() {
+		var f Fact
+		if err := s.scanFact(rows, &f); err != nil {
+			return nil, err
+		}
+		facts = append(facts, f)
+	}
+	return facts, rows.Err()
+}
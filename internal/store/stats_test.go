@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(ctx, "first", []string{"arch"}, "/project")
+	second, _ := store.AddFact(ctx, "second", []string{"arch", "db"}, "/project")
+	_, _ = store.AddFact(ctx, "third", nil, "/other")
+	_, _ = store.SetPinned(ctx, second.ID, true)
+	old, _ := store.AddFact(ctx, "old", nil, "/other")
+	_, _ = store.db.Exec("UPDATE facts SET created_at = ?, updated_at = ? WHERE id = ?", time.Now().Add(-180*24*time.Hour), time.Now().Add(-180*24*time.Hour), old.ID)
+
+	stats, err := store.Stats(ctx, FactQuery{})
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Total != 4 || stats.Pinned != 1 {
+		t.Errorf("expected 4 facts with 1 pinned, got %d with %d", stats.Total, stats.Pinned)
+	}
+	if len(stats.Dirs) != 2 || stats.Dirs[0].Count != 2 {
+		t.Errorf("expected 2 directories with 2 facts each, got %v", stats.Dirs)
+	}
+	if len(stats.Tags) != 2 || stats.Tags[0] != (TagCount{Tag: "arch", Count: 2}) {
+		t.Errorf("expected arch to be the top tag, got %v", stats.Tags)
+	}
+	if len(stats.Ages) != len(AgeBuckets) || stats.Ages[0].Count != 3 || stats.Ages[len(stats.Ages)-1].Count != 1 {
+		t.Errorf("expected 3 new facts and 1 old one, got %v", stats.Ages)
+	}
+
+	if stats.NeverRecalledCount != 4 || len(stats.NeverRecalled) != 4 || stats.NeverRecalled[0].ID != old.ID {
+		t.Errorf("expected 4 never recalled facts, oldest first, got %d: %v", stats.NeverRecalledCount, stats.NeverRecalled)
+	}
+
+	_ = store.RecordRecalls(ctx, []int64{second.ID})
+	_ = store.RecordRecalls(ctx, []int64{second.ID, old.ID})
+	stats, _ = store.Stats(ctx, FactQuery{})
+	if len(stats.MostRecalled) != 2 || stats.MostRecalled[0].ID != second.ID || stats.MostRecalled[0].RecallCount != 2 {
+		t.Errorf("expected #%d to be the most recalled fact, got %v", second.ID, stats.MostRecalled)
+	}
+	if stats.NeverRecalledCount != 2 {
+		t.Errorf("expected 2 never recalled facts, got %d", stats.NeverRecalledCount)
+	}
+
+	local, _ := store.Stats(ctx, FactQuery{SourceDir: "/project"})
+	if local.Total != 2 || len(local.Dirs) != 1 || len(local.Tags) != 2 {
+		t.Errorf("expected stats of /project only, got %+v", local)
+	}
+}
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"context"
+	"strings"
 	"time"
 )
 
@@ -9,8 +11,181 @@ type Fact struct {
 	Content   string    `json:"content"`
 	Tags      []string  `json:"tags,omitempty"`
 	SourceDir string    `json:"source_dir"`
+	RepoID    string    `json:"repo_id,omitempty"`
+	RepoPath  string    `json:"repo_path,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// ExpiresAt is nil for facts that never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Pinned facts are always included in context, ahead of the rest
+	Pinned bool `json:"pinned,omitempty"`
+	// SupersededBy lists the facts that replace this one
+	SupersededBy []int64 `json:"superseded_by,omitempty"`
+	// Links lists the links from this fact to others, of every kind. Set
+	// only by ExportFacts, so that ImportFacts can restore them.
+	Links []FactLink `json:"links,omitempty"`
+	// Kind classifies the fact, and Details holds the structured fields of
+	// its kind, if any
+	Kind    FactKind     `json:"kind,omitempty"`
+	Details *FactDetails `json:"details,omitempty"`
+	// RecallCount is how many times the fact was returned by recall,
+	// get_context or GetFactByID, the last time at LastRecalledAt
+	RecallCount    int        `json:"recall_count,omitempty"`
+	LastRecalledAt *time.Time `json:"last_recalled_at,omitempty"`
+	// Provenance: the MCP client (or CLIClient) that stored the fact, the
+	// clauder instance it was connected to and the git commit checked out at
+	// the time. Empty for facts stored before provenance was recorded.
+	Client        string `json:"client,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+	InstanceID    string `json:"instance_id,omitempty"`
+	CommitSHA     string `json:"commit_sha,omitempty"`
+
+	// Set only by full-text searches and similarity checks
+	Score   float64 `json:"score,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// GlobalScope is the source_dir of facts that apply in every directory
+const GlobalScope = "*"
+
+// CLIClient is the client recorded for facts stored with the clauder CLI
+const CLIClient = "cli"
+
+// Provenance describes where f came from, e.g. "codex-mcp-client 0.4.0,
+// instance <id>, commit 1a2b3c4", or "" if that was not recorded
+func (f Fact) Provenance() string {
+	var parts []string
+	if f.Client != "" {
+		parts = append(parts, strings.TrimSpace(f.Client+" "+f.ClientVersion))
+	}
+	if f.InstanceID != "" {
+		parts = append(parts, "instance "+f.InstanceID)
+	}
+	if commit := f.CommitSHA; commit != "" {
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		parts = append(parts, "commit "+commit)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DirMode controls how FactQuery.SourceDir is matched
+type DirMode int
+
+const (
+	// DirExact matches facts stored from SourceDir only
+	DirExact DirMode = iota
+	// DirDescendants matches SourceDir and every directory beneath it
+	DirDescendants
+	// DirAncestors matches SourceDir and its ancestors up to ScopeRoot
+	DirAncestors
+)
+
+// SearchMode selects how text queries are ranked
+type SearchMode int
+
+const (
+	// SearchKeyword ranks full-text matches by BM25
+	SearchKeyword SearchMode = iota
+	// SearchSemantic ranks facts by embedding similarity to the query
+	SearchSemantic
+	// SearchHybrid blends BM25 and embedding similarity
+	SearchHybrid
+)
+
+// FactQuery describes a fact search. Zero values mean "no filter".
+// Use ParseQuery to build one from the recall query language.
+type FactQuery struct {
+	// Query is searched for as a literal phrase
+	Query       string
+	Kinds       []FactKind
+	Tags        []string
+	ExcludeTags []string
+	SourceDir   string
+	DirMode     DirMode
+	// ScopeRoot bounds DirAncestors: directories above it are not matched.
+	// Without a ScopeRoot only SourceDir itself is matched.
+	ScopeRoot string
+	// RepoID, together with a ScopeRoot naming its checkout, also matches
+	// facts recorded at the same repo-relative paths in other clones,
+	// worktrees or machines.
+	RepoID string
+	// Client matches facts stored by clients whose name starts with it,
+	// ignoring case, e.g. "codex" matches "codex-mcp-client"
+	Client string
+	Since  time.Time
+	Before time.Time
+	Limit  int
+	// Cursor resumes a search after the page that returned it as
+	// FactPage.NextCursor
+	Cursor string
+	// PinnedFirst orders pinned facts ahead of all others, so that a limit
+	// never drops them in favour of unpinned facts
+	PinnedFirst bool
+	// IncludeSuperseded also returns facts that another fact supersedes
+	IncludeSuperseded bool
+	// UnusedSince matches facts created before it that have not been
+	// recalled since
+	UnusedSince time.Time
+
+	// Mode selects keyword, semantic or hybrid ranking for text queries
+	Mode SearchMode
+
+	// RecencyHalfLife blends BM25 relevance with recency: a fact's score is
+	// halved once it is RecencyHalfLife old. Zero ranks by relevance only.
+	RecencyHalfLife time.Duration
+
+	// Sanitized FTS5 expressions, only ever built by ParseQuery
+	match   string
+	exclude string
+	// Plain search words for semantic ranking, set by ParseQuery
+	text string
+}
+
+// HasText reports whether the query performs a full-text match, i.e. whether
+// results carry a relevance score and snippet.
+func (q FactQuery) HasText() bool {
+	return q.match != "" || q.Query != ""
+}
+
+// ftsMatch returns the FTS5 expression of the query's text, if any
+func (q FactQuery) ftsMatch() string {
+	if q.match == "" && q.Query != "" {
+		// Sanitize FTS query to prevent operator injection
+		return sanitizeFTSQuery(q.Query)
+	}
+	return q.match
+}
+
+// FactEdit lists the changes EditFact makes to a fact
+type FactEdit struct {
+	// Content replaces the content unless empty
+	Content string
+	// Tags replaces the tags unless nil; an empty slice clears them
+	Tags []string
+	// SetKind replaces the kind and details with Kind and Details
+	SetKind bool
+	Kind    FactKind
+	Details *FactDetails
+}
+
+type FactRevision struct {
+	ID      int64    `json:"id"`
+	FactID  int64    `json:"fact_id"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags,omitempty"`
+	// Kind and Details are empty for revisions archived before kinds were
+	// recorded in the history
+	Kind       FactKind     `json:"kind,omitempty"`
+	Details    *FactDetails `json:"details,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ReplacedAt time.Time    `json:"replaced_at"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
 }
 
 type Instance struct {
@@ -30,25 +205,91 @@ type Message struct {
 	ReadAt       *time.Time `json:"read_at,omitempty"`
 }
 
+// Session records a run of the MCP server, which outlives its Instance
+type Session struct {
+	ID            int64      `json:"id"`
+	InstanceID    string     `json:"instance_id"`
+	Directory     string     `json:"directory"`
+	Client        string     `json:"client,omitempty"`
+	ClientVersion string     `json:"client_version,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+	// Summary is the handoff the session left for the next one in its
+	// directory, written at SummarizedAt
+	Summary      string     `json:"summary,omitempty"`
+	SummarizedAt *time.Time `json:"summarized_at,omitempty"`
+}
+
 type Store interface {
 	// Facts
-	AddFact(content string, tags []string, sourceDir string) (*Fact, error)
-	GetFacts(query string, tags []string, sourceDir string, limit int) ([]Fact, error)
-	GetFactByID(id int64) (*Fact, error)
-	DeleteFact(id int64) error
+	AddFact(ctx context.Context, content string, tags []string, sourceDir string) (*Fact, error)
+	InsertFact(ctx context.Context, f Fact) (*Fact, error)
+	GetFacts(ctx context.Context, query string, tags []string, sourceDir string, limit int) ([]Fact, error)
+	SearchFacts(ctx context.Context, q FactQuery) ([]Fact, error)
+	SearchFactsPage(ctx context.Context, q FactQuery) (*FactPage, error)
+	CountFacts(ctx context.Context, q FactQuery) (int, error)
+	Stats(ctx context.Context, q FactQuery) (*FactStats, error)
+	GetFactByID(ctx context.Context, id int64) (*Fact, error)
+	PeekFact(ctx context.Context, id int64) (*Fact, error)
+	UpdateFact(ctx context.Context, id int64, content string, tags []string) (*Fact, error)
+	EditFact(ctx context.Context, id int64, edit FactEdit) (*Fact, error)
+	GetFactRevisions(ctx context.Context, id int64) ([]FactRevision, error)
+	DeleteFact(ctx context.Context, id int64) error
+	SetPinned(ctx context.Context, id int64, pinned bool) (*Fact, error)
+	RecordRecalls(ctx context.Context, ids []int64) error
+	SetFactKind(ctx context.Context, id int64, kind FactKind, details *FactDetails) (*Fact, error)
+
+	// Duplicates
+	RememberFact(ctx context.Context, f Fact, mode DuplicateMode) (*Fact, []Fact, error)
+	FindSimilarFacts(ctx context.Context, f Fact, threshold float64) ([]Fact, error)
+	FindDuplicateGroups(ctx context.Context, threshold float64) ([][]Fact, error)
+	MergeFacts(ctx context.Context, keepID int64, duplicateIDs []int64) (*Fact, error)
+
+	// Links
+	LinkFacts(ctx context.Context, fromID, toID int64, kind LinkKind) (*FactLink, error)
+	UnlinkFacts(ctx context.Context, fromID, toID int64, kind LinkKind) (bool, error)
+	GetFactLinks(ctx context.Context, id int64) ([]FactLink, error)
+
+	// Export and import
+	ExportFacts(ctx context.Context, q FactQuery, fn func(Fact) error) error
+	ImportFacts(ctx context.Context, facts []Fact, strategy ImportStrategy) (*ImportStats, error)
+
+	// Backups
+	Backup(ctx context.Context, destPath string) error
+	Restore(ctx context.Context, path string) error
+	PurgeExpiredFacts(ctx context.Context) (int64, error)
+	GC(ctx context.Context, opts GCOptions) (*GCResult, error)
+
+	// Tags
+	ListTags(ctx context.Context) ([]TagCount, error)
+	RenameTag(ctx context.Context, oldName, newName string) (int64, error)
+	MergeTags(ctx context.Context, sources []string, target string) (int64, error)
+
+	// Repositories
+	RelocateFacts(ctx context.Context, oldDir, newDir string) (int64, error)
+	AssignRepo(ctx context.Context, root, repoID string) (int64, error)
 
 	// Instances
-	RegisterInstance(id string, pid int, directory string) error
-	Heartbeat(id string) error
-	UnregisterInstance(id string) error
-	GetInstances() ([]Instance, error)
-	GetInstance(id string) (*Instance, error)
-	CleanupStaleInstances(maxAge time.Duration) error
+	RegisterInstance(ctx context.Context, id string, pid int, directory string) error
+	Heartbeat(ctx context.Context, id string) error
+	UnregisterInstance(ctx context.Context, id string) error
+	GetInstances(ctx context.Context) ([]Instance, error)
+	GetInstance(ctx context.Context, id string) (*Instance, error)
+	CleanupStaleInstances(ctx context.Context, maxAge time.Duration) error
+
+	// Sessions
+	StartSession(ctx context.Context, instanceID, directory string) (*Session, error)
+	SetSessionClient(ctx context.Context, instanceID, client, version string) error
+	SummarizeSession(ctx context.Context, instanceID, summary string) error
+	EndSession(ctx context.Context, instanceID string) error
+	ListSessions(ctx context.Context, directory string, limit int) ([]Session, error)
+	LastSession(ctx context.Context, directory, exceptInstanceID string) (*Session, error)
 
 	// Messages
-	SendMessage(from, to, content string) (*Message, error)
-	GetMessages(toInstance string, unreadOnly bool) ([]Message, error)
-	MarkMessageRead(id int64) error
+	SendMessage(ctx context.Context, from, to, content string) (*Message, error)
+	GetMessages(ctx context.Context, toInstance string, unreadOnly bool) ([]Message, error)
+	ExportMessages(ctx context.Context, fn func(Message) error) error
+	MarkMessageRead(ctx context.Context, id int64) error
 
 	// Lifecycle
 	Close() error
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// factTagsColumn selects a fact's tags, in insertion order, as a JSON array.
+// It expects the facts table to be aliased as f.
+const factTagsColumn = "(SELECT json_group_array(tag) FROM (SELECT tag FROM fact_tags WHERE fact_id = f.id ORDER BY id))"
+
+// normalizeTag makes tag matching case- and whitespace-insensitive
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags normalizes tags, dropping empty and duplicate entries while
+// preserving order. It never returns nil.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+func insertTags(ctx context.Context, tx *sql.Tx, factID int64, tags []string) error {
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO fact_tags (fact_id, tag) VALUES (?, ?)", factID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTags returns every tag in use with the number of facts carrying it,
+// most used first.
+func (s *SQLiteStore) ListTags(ctx context.Context) ([]TagCount, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT tag, COUNT(*) AS n FROM fact_tags GROUP BY tag ORDER BY n DESC, tag ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tags []TagCount
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// RenameTag renames a tag on every fact. It fails if newName is already in
+// use; use MergeTags to combine two existing tags.
+func (s *SQLiteStore) RenameTag(ctx context.Context, oldName, newName string) (int64, error) {
+	oldName, newName = normalizeTag(oldName), normalizeTag(newName)
+	if oldName == "" || newName == "" {
+		return 0, fmt.Errorf("tag names cannot be empty")
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM fact_tags WHERE tag = ?)", newName).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists && newName != oldName {
+		return 0, fmt.Errorf("tag %q already exists (merge the tags instead)", newName)
+	}
+
+	result, err := s.db.ExecContext(ctx, "UPDATE fact_tags SET tag = ? WHERE tag = ?", newName, oldName)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MergeTags replaces every source tag with target, returning the number of
+// facts that gained the target tag.
+func (s *SQLiteStore) MergeTags(ctx context.Context, sources []string, target string) (int64, error) {
+	target = normalizeTag(target)
+	if target == "" {
+		return 0, fmt.Errorf("target tag cannot be empty")
+	}
+
+	var from []interface{}
+	for _, tag := range normalizeTags(sources) {
+		if tag != target {
+			from = append(from, tag)
+		}
+	}
+	if len(from) == 0 {
+		return 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(from)), ", ")
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	args := append([]interface{}{target}, from...)
+	result, err := tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO fact_tags (fact_id, tag) SELECT DISTINCT fact_id, ? FROM fact_tags WHERE tag IN ("+placeholders+")",
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fact_tags WHERE tag IN ("+placeholders+")", from...); err != nil {
+		return 0, err
+	}
+	return added, tx.Commit()
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags := normalizeTags([]string{" Arch ", "arch", "", "DB", "db "})
+	if len(tags) != 2 || tags[0] != "arch" || tags[1] != "db" {
+		t.Errorf("unexpected normalized tags: %v", tags)
+	}
+	if normalizeTags(nil) == nil {
+		t.Error("expected non-nil slice for nil input")
+	}
+}
+
+func TestGetFacts_TagsCaseInsensitive(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	fact, _ := store.AddFact(ctx, "mixed case tags", []string{"Architecture"}, "/project")
+	if fact.Tags[0] != "architecture" {
+		t.Errorf("expected normalized tag, got %v", fact.Tags)
+	}
+
+	facts, err := store.GetFacts(ctx, "", []string{"ARCHITECTURE"}, "", 10)
+	if err != nil {
+		t.Fatalf("GetFacts failed: %v", err)
+	}
+	if len(facts) != 1 {
+		t.Errorf("expected 1 fact, got %d", len(facts))
+	}
+}
+
+func TestListTags(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, _ = store.AddFact(ctx, "one", []string{"arch", "db"}, "/project")
+	_, _ = store.AddFact(ctx, "two", []string{"arch"}, "/project")
+
+	tags, err := store.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	if len(tags) != 2 {
+		t.Fatalf("expected 2 tags, got %d", len(tags))
+	}
+	if tags[0].Tag != "arch" || tags[0].Count != 2 {
+		t.Errorf("expected arch used twice first, got %+v", tags[0])
+	}
+	if tags[1].Tag != "db" || tags[1].Count != 1 {
+		t.Errorf("unexpected second tag: %+v", tags[1])
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	fact, _ := store.AddFact(ctx, "one", []string{"infra"}, "/project")
+	_, _ = store.AddFact(ctx, "two", []string{"ops"}, "/project")
+
+	n, err := store.RenameTag(ctx, "infra", "infrastructure")
+	if err != nil {
+		t.Fatalf("RenameTag failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 renamed fact, got %d", n)
+	}
+	found, _ := store.GetFactByID(ctx, fact.ID)
+	if len(found.Tags) != 1 || found.Tags[0] != "infrastructure" {
+		t.Errorf("unexpected tags after rename: %v", found.Tags)
+	}
+
+	if _, err := store.RenameTag(ctx, "infrastructure", "ops"); err == nil {
+		t.Error("expected error when renaming onto an existing tag")
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	both, _ := store.AddFact(ctx, "both", []string{"arch", "design"}, "/project")
+	_, _ = store.AddFact(ctx, "design only", []string{"design"}, "/project")
+	_, _ = store.AddFact(ctx, "target only", []string{"architecture"}, "/project")
+
+	n, err := store.MergeTags(ctx, []string{"arch", "design"}, "architecture")
+	if err != nil {
+		t.Fatalf("MergeTags failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 facts to gain the target tag, got %d", n)
+	}
+
+	tags, _ := store.ListTags(ctx)
+	if len(tags) != 1 || tags[0].Tag != "architecture" || tags[0].Count != 3 {
+		t.Errorf("unexpected tags after merge: %+v", tags)
+	}
+	found, _ := store.GetFactByID(ctx, both.ID)
+	if len(found.Tags) != 1 {
+		t.Errorf("expected a single merged tag, got %v", found.Tags)
+	}
+}
+
+func TestMigrateTags_FromLegacyJSON(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	result, err := store.db.Exec(`INSERT INTO facts (content, tags, source_dir) VALUES ('legacy', '["Arch","db","arch"]', '/project')`)
+	if err != nil {
+		t.Fatalf("failed to insert legacy fact: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	_, _ = store.db.Exec(`INSERT INTO facts (content, tags, source_dir) VALUES ('corrupted', 'not json', '/project')`)
+
+	applyMigrationStep(t, store, migrateFactTags)
+	// Running again must be a no-op
+	applyMigrationStep(t, store, migrateFactTags)
+
+	fact, _ := store.GetFactByID(ctx, id)
+	if len(fact.Tags) != 2 || fact.Tags[0] != "arch" || fact.Tags[1] != "db" {
+		t.Errorf("unexpected migrated tags: %v", fact.Tags)
+	}
+
+	facts, _ := store.GetFacts(ctx, "", []string{"db"}, "", 10)
+	if len(facts) != 1 {
+		t.Errorf("expected migrated tag to be searchable, got %d facts", len(facts))
+	}
+}
@@ -0,0 +1,307 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ImportStrategy controls how ImportFacts treats facts that already exist
+type ImportStrategy int
+
+const (
+	// ImportRemap stores every imported fact under a new ID
+	ImportRemap ImportStrategy = iota
+	// ImportSkipDuplicates skips facts whose content is already stored in
+	// the same directory, and stores the rest under new IDs
+	ImportSkipDuplicates
+	// ImportOverwrite keeps the exported IDs, replacing any fact already
+	// stored under the same ID
+	ImportOverwrite
+)
+
+// ParseImportStrategy parses "remap", "skip" or "overwrite"
+func ParseImportStrategy(s string) (ImportStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "remap":
+		return ImportRemap, nil
+	case "skip":
+		return ImportSkipDuplicates, nil
+	case "overwrite":
+		return ImportOverwrite, nil
+	}
+	return ImportRemap, fmt.Errorf("invalid import strategy %q (use remap, skip or overwrite)", s)
+}
+
+// ImportStats summarizes an import
+type ImportStats struct {
+	Imported    int
+	Skipped     int
+	Overwritten int
+	// IDs maps each exported fact ID to the ID it was stored under
+	IDs map[int64]int64
+}
+
+// ExportFacts calls fn for every fact matching q's filters, oldest first,
+// with its outgoing links. Unlike SearchFacts it ignores q.Limit and any text
+// query, and includes superseded facts.
+func (s *SQLiteStore) ExportFacts(ctx context.Context, q FactQuery, fn func(Fact) error) error {
+	if err := s.syncSearchIndex(ctx); err != nil {
+		return err
+	}
+	// Read the links up front: encrypted stores have a single connection,
+	// which the fact rows below hold until they are closed
+	links, err := s.linksByFact(ctx)
+	if err != nil {
+		return err
+	}
+	q.IncludeSuperseded = true
+	conditions, args := filterConditions(q)
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+factColumns+" FROM facts f WHERE "+strings.Join(conditions, " AND ")+" ORDER BY f.id",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var f Fact
+		if err := s.scanFact(rows, &f); err != nil {
+			return err
+		}
+		f.Links = links[f.ID]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportFacts stores exported facts, keeping their timestamps, tags, pin,
+// expiry and the links between them. All facts are imported in a
+// single transaction.
+func (s *SQLiteStore) ImportFacts(ctx context.Context, facts []Fact, strategy ImportStrategy) (*ImportStats, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stats := &ImportStats{IDs: make(map[int64]int64)}
+	for _, f := range facts {
+		if f.Content == "" || f.SourceDir == "" {
+			return nil, fmt.Errorf("fact #%d: content and source_dir are required", f.ID)
+		}
+		if f.CreatedAt.IsZero() {
+			f.CreatedAt = time.Now()
+		}
+		if f.UpdatedAt.IsZero() {
+			f.UpdatedAt = f.CreatedAt
+		}
+		// Times are compared as stored strings, so keep one time zone
+		f.CreatedAt, f.UpdatedAt = f.CreatedAt.Local(), f.UpdatedAt.Local()
+		if f.ExpiresAt != nil {
+			expiresAt := f.ExpiresAt.Local()
+			f.ExpiresAt = &expiresAt
+		}
+		f.Tags = normalizeTags(f.Tags)
+		// Exports from before fact kinds have none
+		if f.Kind == "" {
+			f.Kind = KindNote
+		}
+		if err := f.Details.Validate(f.Kind); err != nil {
+			return nil, fmt.Errorf("fact #%d: %w", f.ID, err)
+		}
+
+		switch strategy {
+		case ImportSkipDuplicates:
+			existingID, err := s.findFactByContent(ctx, tx, f.Content, f.SourceDir)
+			if err != nil {
+				return nil, err
+			}
+			if existingID != 0 {
+				stats.Skipped++
+				stats.IDs[f.ID] = existingID
+				continue
+			}
+		case ImportOverwrite:
+			if f.ID > 0 {
+				replaced, err := s.overwriteFact(ctx, tx, f)
+				if err != nil {
+					return nil, err
+				}
+				if replaced {
+					stats.Overwritten++
+					stats.IDs[f.ID] = f.ID
+					continue
+				}
+			}
+		}
+
+		id, err := s.importFact(ctx, tx, f, strategy == ImportOverwrite)
+		if err != nil {
+			return nil, err
+		}
+		stats.Imported++
+		stats.IDs[f.ID] = id
+	}
+
+	// Links to facts outside the import are dropped. Exports from before
+	// links were exported only have SupersededBy.
+	for _, f := range facts {
+		var links []FactLink
+		for _, fromID := range f.SupersededBy {
+			links = append(links, FactLink{FromID: fromID, ToID: f.ID, Kind: LinkSupersedes, CreatedAt: time.Now()})
+		}
+		for _, l := range f.Links {
+			links = append(links, FactLink{FromID: f.ID, ToID: l.ToID, Kind: l.Kind, CreatedAt: l.CreatedAt})
+		}
+		for _, l := range links {
+			from, okFrom := stats.IDs[l.FromID]
+			to, okTo := stats.IDs[l.ToID]
+			if !okFrom || !okTo || from == to {
+				continue
+			}
+			if _, err := ParseLinkKind(string(l.Kind)); err != nil {
+				return nil, fmt.Errorf("fact #%d: %w", f.ID, err)
+			}
+			if l.CreatedAt.IsZero() {
+				l.CreatedAt = time.Now()
+			}
+			if _, err := tx.ExecContext(ctx,
+				"INSERT OR IGNORE INTO fact_links (from_id, to_id, kind, created_at) VALUES (?, ?, ?, ?)",
+				from, to, string(l.Kind), l.CreatedAt.Local(),
+			); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// linksByFact returns every link, keyed by the fact it starts from
+func (s *SQLiteStore) linksByFact(ctx context.Context) (map[int64][]FactLink, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT from_id, to_id, kind, created_at FROM fact_links ORDER BY from_id, to_id")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	links := make(map[int64][]FactLink)
+	for rows.Next() {
+		var l FactLink
+		var kind string
+		if err := rows.Scan(&l.FromID, &l.ToID, &kind, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		l.Kind = LinkKind(kind)
+		links[l.FromID] = append(links[l.FromID], l)
+	}
+	return links, rows.Err()
+}
+
+// findFactByContent returns the ID of a fact with exactly this content in
+// sourceDir, or 0 if there is none
+func (s *SQLiteStore) findFactByContent(ctx context.Context, tx *sql.Tx, content, sourceDir string) (int64, error) {
+	if s.cipher == nil {
+		var id int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM facts WHERE content = ? AND source_dir = ? LIMIT 1", content, sourceDir).Scan(&id)
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return id, err
+	}
+
+	// Encrypted content can only be compared after decrypting it
+	rows, err := tx.QueryContext(ctx, "SELECT id, content FROM facts WHERE source_dir = ? ORDER BY id", sourceDir)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id int64
+		var stored string
+		if err := rows.Scan(&id, &stored); err != nil {
+			return 0, err
+		}
+		plaintext, err := s.cipher.open(stored)
+		if err != nil {
+			return 0, err
+		}
+		if plaintext == content {
+			return id, nil
+		}
+	}
+	return 0, rows.Err()
+}
+
+// importFact inserts f with its own timestamps, under its own ID if keepID
+func (s *SQLiteStore) importFact(ctx context.Context, tx *sql.Tx, f Fact, keepID bool) (int64, error) {
+	var id interface{}
+	if keepID && f.ID > 0 {
+		id = f.ID
+	}
+	sealed, err := s.cipher.seal(f.Content)
+	if err != nil {
+		return 0, err
+	}
+	details, err := s.sealDetails(f.Details)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO facts (id, content, kind, details, source_dir, repo_id, repo_path, client, client_version, instance_id, commit_sha, created_at, updated_at, expires_at, pinned, recall_count, last_recalled_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, sealed, string(f.Kind), details, f.SourceDir, f.RepoID, f.RepoPath, f.Client, f.ClientVersion, f.InstanceID, f.CommitSHA, f.CreatedAt, f.UpdatedAt, f.ExpiresAt, f.Pinned, f.RecallCount, f.LastRecalledAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return newID, insertTags(ctx, tx, newID, f.Tags)
+}
+
+// overwriteFact replaces the fact stored under f.ID, archiving its current
+// version. It reports false if no such fact exists.
+func (s *SQLiteStore) overwriteFact(ctx context.Context, tx *sql.Tx, f Fact) (bool, error) {
+	var current Fact
+	err := s.scanFact(tx.QueryRowContext(ctx, "SELECT "+factColumns+" FROM facts f WHERE f.id = ?", f.ID), &current)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.archiveRevision(ctx, tx, current); err != nil {
+		return false, err
+	}
+	sealed, err := s.cipher.seal(f.Content)
+	if err != nil {
+		return false, err
+	}
+	details, err := s.sealDetails(f.Details)
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE facts SET content = ?, kind = ?, details = ?, source_dir = ?, repo_id = ?, repo_path = ?, client = ?, client_version = ?, instance_id = ?, commit_sha = ?, created_at = ?, updated_at = ?, expires_at = ?, pinned = ? WHERE id = ?",
+		sealed, string(f.Kind), details, f.SourceDir, f.RepoID, f.RepoPath, f.Client, f.ClientVersion, f.InstanceID, f.CommitSHA, f.CreatedAt, f.UpdatedAt, f.ExpiresAt, f.Pinned, f.ID,
+	); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fact_tags WHERE fact_id = ?", f.ID); err != nil {
+		return false, err
+	}
+	return true, insertTags(ctx, tx, f.ID, f.Tags)
+}
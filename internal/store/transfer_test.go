@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func exportAll(t *testing.T, s *SQLiteStore, q FactQuery) []Fact {
+	ctx := context.Background()
+	t.Helper()
+	var facts []Fact
+	if err := s.ExportFacts(ctx, q, func(f Fact) error {
+		facts = append(facts, f)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportFacts failed: %v", err)
+	}
+	return facts
+}
+
+func TestExportFacts_Filters(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	a, _ := store.AddFact(ctx, "api fact", []string{"arch"}, "/src/api")
+	_, _ = store.AddFact(ctx, "web fact", []string{"arch"}, "/src/web")
+	_, _ = store.AddFact(ctx, "api note", nil, "/src/api/internal")
+
+	facts := exportAll(t, store, FactQuery{})
+	if len(facts) != 3 {
+		t.Fatalf("expected 3 facts, got %d", len(facts))
+	}
+	if facts[0].ID != a.ID {
+		t.Error("expected oldest fact first")
+	}
+
+	facts = exportAll(t, store, FactQuery{Tags: []string{"arch"}, SourceDir: "/src/api", DirMode: DirDescendants})
+	if len(facts) != 1 || facts[0].ID != a.ID {
+		t.Errorf("expected only the tagged api fact, got %v", facts)
+	}
+
+	facts = exportAll(t, store, FactQuery{Since: time.Now().Add(time.Hour)})
+	if len(facts) != 0 {
+		t.Errorf("expected no facts updated in the future, got %d", len(facts))
+	}
+}
+
+func TestImportFacts_Strategies(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	existing, _ := store.AddFact(ctx, "use pnpm", []string{"tooling"}, "/project")
+
+	created := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	exported := []Fact{
+		{ID: existing.ID, Content: "use pnpm", Tags: []string{"Tooling"}, SourceDir: "/project", CreatedAt: created, UpdatedAt: created},
+		{ID: 500, Content: "API is gRPC", Tags: []string{"arch"}, SourceDir: "/project", CreatedAt: created, UpdatedAt: created, Pinned: true},
+	}
+
+	// Remap
+	stats, err := store.ImportFacts(ctx, exported, ImportRemap)
+	if err != nil {
+		t.Fatalf("ImportFacts failed: %v", err)
+	}
+	if stats.Imported != 2 || stats.IDs[existing.ID] == existing.ID || stats.IDs[500] == 500 {
+		t.Errorf("expected every fact under a new ID, got %+v", stats)
+	}
+	imported, _ := store.GetFactByID(ctx, stats.IDs[500])
+	if !imported.CreatedAt.Equal(created) || !imported.Pinned || len(imported.Tags) != 1 {
+		t.Errorf("expected timestamps, pin and tags to be kept, got %+v", imported)
+	}
+
+	// Skip duplicates
+	stats, err = store.ImportFacts(ctx, exported, ImportSkipDuplicates)
+	if err != nil {
+		t.Fatalf("ImportFacts failed: %v", err)
+	}
+	if stats.Imported != 0 || stats.Skipped != 2 || stats.IDs[existing.ID] != existing.ID {
+		t.Errorf("expected both facts to be skipped, got %+v", stats)
+	}
+
+	// Overwrite
+	exported[0].Content = "use pnpm, never npm"
+	stats, err = store.ImportFacts(ctx, exported, ImportOverwrite)
+	if err != nil {
+		t.Fatalf("ImportFacts failed: %v", err)
+	}
+	if stats.Overwritten != 1 || stats.Imported != 1 || stats.IDs[500] != 500 {
+		t.Errorf("expected one overwrite and one insert under its own ID, got %+v", stats)
+	}
+	overwritten, _ := store.GetFactByID(ctx, existing.ID)
+	if overwritten.Content != "use pnpm, never npm" {
+		t.Errorf("expected overwritten content, got %q", overwritten.Content)
+	}
+	if revisions, _ := store.GetFactRevisions(ctx, existing.ID); len(revisions) != 1 || revisions[0].Content != "use pnpm" {
+		t.Errorf("expected the replaced version in history, got %v", revisions)
+	}
+	if f, _ := store.GetFactByID(ctx, 500); f == nil || f.Content != "API is gRPC" {
+		t.Error("expected fact imported under its exported ID")
+	}
+}
+
+func TestImportFacts_Invalid(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	_, err := store.ImportFacts(ctx, []Fact{{Content: "ok", SourceDir: "/p"}, {Content: "", SourceDir: "/p"}}, ImportRemap)
+	if err == nil {
+		t.Fatal("expected error for fact without content")
+	}
+	if facts := exportAll(t, store, FactQuery{}); len(facts) != 0 {
+		t.Errorf("expected failed import to store nothing, got %d", len(facts))
+	}
+}
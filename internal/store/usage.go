@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Facts that are recalled often rank higher: a fact's relevance score is
+// raised by up to usageBoost, half of it once recalled usageHalfCount times
+const (
+	usageBoost     = 0.25
+	usageHalfCount = 5
+)
+
+// usageFactorColumn computes usageFactor in SQL
+var usageFactorColumn = fmt.Sprintf("(1.0 + %g * f.recall_count / (f.recall_count + %d.0))", usageBoost, usageHalfCount)
+
+// usageFactor is the multiplier applied to the relevance score of a fact
+// recalled recallCount times
+func usageFactor(recallCount int) float64 {
+	n := float64(recallCount)
+	return 1 + usageBoost*n/(n+usageHalfCount)
+}
+
+// RecordRecalls counts a recall of each fact in ids, made when facts are
+// returned to an agent or user
+func (s *SQLiteStore) RecordRecalls(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []interface{}{time.Now()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	update := "UPDATE facts SET recall_count = recall_count + 1, last_recalled_at = ? WHERE id IN (" + placeholders(len(ids)) + ")"
+
+	if s.cipher == nil {
+		_, err := s.db.ExecContext(ctx, update, args...)
+		return err
+	}
+
+	// Recalls leave content unchanged, so the in-memory index of an
+	// encrypted database stays valid if it was before
+	s.index.mu.Lock()
+	defer s.index.mu.Unlock()
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	var before, after int64
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes()").Scan(&before); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, update, args...); err != nil {
+		return err
+	}
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes()").Scan(&after); err != nil {
+		return err
+	}
+	if s.index.ready && s.index.changes == before {
+		s.index.changes = after
+	}
+	return nil
+}
+
+// FactIDs returns the IDs of facts
+func FactIDs(facts []Fact) []int64 {
+	ids := make([]int64, len(facts))
+	for i, f := range facts {
+		ids[i] = f.ID
+	}
+	return ids
+}
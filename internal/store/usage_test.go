@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordRecalls(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	f, _ := store.AddFact(ctx, "deploys run on fridays", nil, "/project")
+	if f.RecallCount != 0 || f.LastRecalledAt != nil {
+		t.Fatalf("expected a new fact to be unrecalled, got %d", f.RecallCount)
+	}
+
+	_, _ = store.GetFactByID(ctx, f.ID)
+	_ = store.RecordRecalls(ctx, []int64{f.ID})
+	// Changing or peeking at a fact is not a recall
+	_, _ = store.SetPinned(ctx, f.ID, true)
+	_, _ = store.PeekFact(ctx, f.ID)
+
+	got, _ := store.PeekFact(ctx, f.ID)
+	if got.RecallCount != 2 || got.LastRecalledAt == nil {
+		t.Errorf("expected 2 recalls, got %d at %v", got.RecallCount, got.LastRecalledAt)
+	}
+	if !got.UpdatedAt.Equal(f.UpdatedAt) {
+		t.Error("expected recalls to leave the update time alone")
+	}
+	if facts, _ := store.GetFacts(ctx, "fridays", nil, "", 10); len(facts) != 1 {
+		t.Error("expected the fact to stay searchable after recalls")
+	}
+}
+
+func TestRecordRecalls_RanksUsedFactsHigher(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	unused, _ := store.AddFact(ctx, "the cache is redis", nil, "/project")
+	used, _ := store.AddFact(ctx, "the cache is redis too", nil, "/project")
+
+	facts, _ := store.SearchFacts(ctx, FactQuery{Query: "redis"})
+	if len(facts) != 2 || facts[0].ID != unused.ID {
+		t.Fatalf("expected the shorter fact first without usage, got %v", facts)
+	}
+
+	for i := 0; i < 10; i++ {
+		_ = store.RecordRecalls(ctx, []int64{used.ID})
+	}
+	for _, mode := range []SearchMode{SearchKeyword, SearchSemantic} {
+		facts, _ = store.SearchFacts(ctx, FactQuery{Query: "redis", Mode: mode})
+		if len(facts) != 2 || facts[0].ID != used.ID {
+			t.Errorf("expected the recalled fact first in mode %d, got %v", mode, facts)
+		}
+	}
+}
+
+func TestSearchFacts_UnusedSince(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	never, _ := store.AddFact(ctx, "never recalled", nil, "/project")
+	stale, _ := store.AddFact(ctx, "recalled long ago", nil, "/project")
+	recent, _ := store.AddFact(ctx, "recalled recently", nil, "/project")
+	_, _ = store.AddFact(ctx, "new and unrecalled", nil, "/project")
+
+	old := time.Now().Add(-200 * 24 * time.Hour)
+	for _, id := range []int64{never.ID, stale.ID, recent.ID} {
+		_, _ = store.db.Exec("UPDATE facts SET created_at = ? WHERE id = ?", old, id)
+	}
+	_, _ = store.db.Exec("UPDATE facts SET recall_count = 1, last_recalled_at = ? WHERE id = ?", old, stale.ID)
+	_ = store.RecordRecalls(ctx, []int64{recent.ID})
+
+	facts, err := store.SearchFacts(ctx, FactQuery{UnusedSince: time.Now().Add(-90 * 24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("SearchFacts failed: %v", err)
+	}
+	ids := map[int64]bool{}
+	for _, f := range facts {
+		ids[f.ID] = true
+	}
+	if len(facts) != 2 || !ids[never.ID] || !ids[stale.ID] {
+		t.Errorf("expected the never and long ago recalled facts, got %v", facts)
+	}
+}
+
+func TestRecordRecalls_KeepsEncryptedIndex(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	key, _ := GenerateKey()
+	if err := store.SetEncryptionKey(ctx, key, nil); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+	f, _ := store.AddFact(ctx, "encrypted and recalled", nil, "/project")
+	if facts, _ := store.GetFacts(ctx, "recalled", nil, "", 10); len(facts) != 1 {
+		t.Fatal("expected to find the encrypted fact")
+	}
+
+	changes := store.index.changes
+	if err := store.RecordRecalls(ctx, []int64{f.ID}); err != nil {
+		t.Fatalf("RecordRecalls failed: %v", err)
+	}
+	if !store.index.ready || store.index.changes == changes {
+		t.Error("expected the search index to stay in sync after a recall")
+	}
+}